@@ -1,8 +1,8 @@
 package analysis
 
 import (
+	"active/datastruct"
 	"active/utils"
-	"encoding/csv"
 	"fmt"
 	"gonum.org/v1/gonum/stat"
 	"gonum.org/v1/plot"
@@ -51,7 +51,7 @@ func generateCountryDelayMap(srcPath string) (map[string][]float64, error) {
 
 	res := make(map[string][]float64)
 	all := make([]float64, 0)
-	reader := csv.NewReader(file)
+	reader := datastruct.NewStatisticReader(file)
 
 	selected := map[string]struct{}{
 		"中国":   {},
@@ -81,6 +81,9 @@ func generateCountryDelayMap(srcPath string) (map[string][]float64, error) {
 		if err != nil {
 			return nil, fmt.Errorf("read csv error: %v", err)
 		}
+		if !datastruct.ValidStatisticRow(row) {
+			continue
+		}
 		country := row[2]
 		if _, ok := selected[country]; !ok {
 			continue
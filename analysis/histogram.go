@@ -1,7 +1,7 @@
 package analysis
 
 import (
-	"encoding/csv"
+	"active/datastruct"
 	"fmt"
 	"gonum.org/v1/plot"
 	"gonum.org/v1/plot/plotter"
@@ -65,7 +65,7 @@ func generateHistMap(srcPath string) (map[string][]float64, error) {
 	res := make(map[string][]float64)
 	all := make([]float64, 0)
 	syn := make([]float64, 0)
-	reader := csv.NewReader(file)
+	reader := datastruct.NewStatisticReader(file)
 	for {
 		row, err := reader.Read()
 		if err == io.EOF {
@@ -74,6 +74,9 @@ func generateHistMap(srcPath string) (map[string][]float64, error) {
 		if err != nil {
 			return nil, fmt.Errorf("read csv error: %v", err)
 		}
+		if !datastruct.ValidStatisticRow(row) {
+			continue
+		}
 		val, err := strconv.ParseInt(row[sharedParams.valCol], 10, 64)
 		if err != nil {
 			return nil, fmt.Errorf("parse int error: %v", err)
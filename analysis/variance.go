@@ -1,7 +1,7 @@
 package analysis
 
 import (
-	"encoding/csv"
+	"active/datastruct"
 	"fmt"
 	"gonum.org/v1/gonum/stat"
 	"gonum.org/v1/plot"
@@ -76,7 +76,7 @@ func getStratumLists(srcPath string) ([][]float64, error) {
 	res := make([][]float64, stratumLimit+2)
 	all := make([]float64, 0)
 	syn := make([]float64, 0)
-	reader := csv.NewReader(file)
+	reader := datastruct.NewStatisticReader(file)
 
 	for {
 		row, err := reader.Read()
@@ -86,6 +86,9 @@ func getStratumLists(srcPath string) ([][]float64, error) {
 		if err != nil {
 			return nil, fmt.Errorf("read file %s error: %v", srcPath, err)
 		}
+		if !datastruct.ValidStatisticRow(row) {
+			continue
+		}
 
 		valStr := row[sharedVarParams.valCol]
 		val, err := strconv.ParseInt(valStr, 10, 64)
@@ -1,17 +1,24 @@
 package async
 
 import (
+	"active/addr"
 	"active/datastruct"
+	"active/kod"
+	"active/packet"
 	"active/parser"
-	"active/utils"
+	"active/pcap"
+	"active/sockopt"
 	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"net"
+	"sync/atomic"
 	"time"
 )
 
-func readNetworkNTP(ctx context.Context, cidr string, conn *net.UDPConn, doneCh chan<- struct{}) {
+func readNetworkNTP(ctx context.Context, cidr string, conn *net.UDPConn, seen map[string]struct{},
+	replies *int64, doneCh chan<- struct{}) {
 	defer func() {
 		doneCh <- struct{}{}
 	}()
@@ -21,7 +28,9 @@ func readNetworkNTP(ctx context.Context, cidr string, conn *net.UDPConn, doneCh
 		return
 	}
 
-	buf := make([]byte, 128)
+	buf := make([]byte, maxDatagram)
+	oob := make([]byte, sockopt.OOBSize)
+	localAddr, _ := conn.LocalAddr().(*net.UDPAddr)
 
 	for {
 		select {
@@ -34,27 +43,99 @@ func readNetworkNTP(ctx context.Context, cidr string, conn *net.UDPConn, doneCh
 				fmt.Println(err)
 				continue
 			}
-			n, udpAddr, err := conn.ReadFromUDP(buf)
+			n, oobn, _, udpAddr, err := conn.ReadMsgUDP(buf, oob)
 			if err != nil {
+				collectClosed(conn, ipNet, seen)
 				continue
 			}
 			if !ipNet.Contains(udpAddr.IP) {
 				fmt.Println("IP out of range: " + udpAddr.IP.String())
 				continue
 			}
+			seen[udpAddr.IP.String()] = struct{}{}
+			atomic.AddInt64(replies, 1)
 			payload := &datastruct.RcvPayload{
 				Host:    udpAddr.IP.String(),
 				Port:    udpAddr.Port,
+				SendLen: parser.HeaderLength,
 				Len:     n,
 				RcvTime: time.Now(),
-				RcvData: buf[:n],
+				RcvData: append([]byte(nil), buf[:n]...),
 			}
+			payload.SetTTL(sockopt.ParseTTL(oob[:oobn]))
+			if payload.CheckKoD() {
+				kod.Record(payload.Host, payload.KissCode)
+			}
+			pcap.WriteUDP(udpAddr, localAddr, payload.TTL, payload.RcvData, payload.RcvTime)
 			if n < parser.HeaderLength {
 				payload.Err = errors.New(fmt.Sprintf("header length %d less than 48", n))
 			} else {
-				payload.SendTime = utils.ConvertTimestamp(buf[24:32])
+				origin := packet.Timestamp(binary.BigEndian.Uint64(buf[24:32]))
+				payload.SendData, payload.SendTime = requestOf(payload.Host, origin, payload.RcvTime)
+				datastruct.RecordAmplification(datastruct.NewAmplification(datastruct.ProbeMode3, payload))
 			}
 			dataCh <- payload
 		}
 	}
 }
+
+// collectClosed reports the hosts whose ICMP port unreachable messages are
+// waiting in the error queue of conn.
+func collectClosed(conn *net.UDPConn, ipNet *net.IPNet, seen map[string]struct{}) {
+	// The queue is read without blocking, but an expired deadline would refuse the read
+	err := conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	ips, err := sockopt.ReadErrQueue(conn)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, ip := range ips {
+		host := ip.String()
+		if _, ok := seen[host]; ok || !ipNet.Contains(ip) {
+			continue
+		}
+		seen[host] = struct{}{}
+		dataCh <- &datastruct.RcvPayload{
+			Host:    host,
+			Port:    123,
+			Status:  datastruct.StatusClosed,
+			RcvTime: time.Now(),
+		}
+	}
+}
+
+// FilteredHosts returns how many hosts of the last scan neither replied nor
+// were reported closed.
+func FilteredHosts() int {
+	return int(atomic.LoadInt64(&filtered))
+}
+
+// reportFiltered counts the hosts of the network that have neither replied
+// nor been reported closed. A large network being mostly silent, they are
+// only listed one by one when async.list_filtered is set.
+func reportFiltered(cidr string, seen map[string]struct{}) {
+	generator, err := addr.NewModuloGenerator(cidr)
+	if err != nil {
+		errCh <- err
+		return
+	}
+	atomic.AddInt64(&filtered, int64(generator.TotalNum()-len(seen)))
+	if !listFiltered {
+		return
+	}
+	for generator.HasNext() {
+		host := generator.NextHost()
+		if _, ok := seen[host]; ok {
+			continue
+		}
+		dataCh <- &datastruct.RcvPayload{
+			Host:   host,
+			Port:   123,
+			Status: datastruct.StatusFiltered,
+		}
+	}
+}
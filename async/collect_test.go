@@ -0,0 +1,97 @@
+package async
+
+import (
+	"active/datastruct"
+	"active/packet"
+	"active/parser"
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestReadNetworkNTPKeepsTrailer(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skipf("cannot listen on loopback: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	server, err := net.DialUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0}, conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = server.Close() }()
+
+	reply := &packet.Packet{
+		Version: 4, Mode: 4, Stratum: 2, TransmitTime: 0xE8000000_00000001,
+		Extensions: []packet.Extension{
+			{Type: 0x0104, Value: bytes.Repeat([]byte{0xAB}, 60)},
+			{Type: 0x0204, Value: bytes.Repeat([]byte{0xCD}, 100)},
+		},
+		MAC: &packet.MAC{KeyID: 7, Digest: bytes.Repeat([]byte{0x11}, 20)},
+	}
+	data := reply.Marshal()
+	if len(data) <= 128 {
+		t.Fatalf("reply of %d bytes does not exceed the old buffer", len(data))
+	}
+
+	dataCh = make(chan *datastruct.RcvPayload, 1)
+	checkInterval = 100 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	doneCh := make(chan struct{}, 1)
+	var replies int64
+	go readNetworkNTP(ctx, "127.0.0.1/32", conn, make(map[string]struct{}), &replies, doneCh)
+	defer func() {
+		cancel()
+		<-doneCh
+	}()
+
+	if _, err = server.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	var p *datastruct.RcvPayload
+	select {
+	case p = <-dataCh:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no payload read")
+	}
+	if p.Len != len(data) || !bytes.Equal(p.RcvData, data) {
+		t.Fatalf("read %d bytes, want %d", p.Len, len(data))
+	}
+	h, err := parser.ParseTrailer(p.RcvData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.TrailerErr != nil || len(h.Extensions) != 2 || h.MAC == nil || h.MAC.KeyID != 7 {
+		t.Errorf("ParseTrailer() = %d extensions, MAC %+v, error %v", len(h.Extensions), h.MAC, h.TrailerErr)
+	}
+}
+
+func TestReportFiltered(t *testing.T) {
+	seen := map[string]struct{}{"192.0.2.1": {}, "192.0.2.2": {}}
+	var tests = []struct {
+		list  bool
+		count int
+	}{
+		{false, 0},
+		{true, 2},
+	}
+	for _, tt := range tests {
+		filtered = 0
+		listFiltered = tt.list
+		dataCh = make(chan *datastruct.RcvPayload, 4)
+		reportFiltered("192.0.2.0/30", seen)
+		close(dataCh)
+		listed := 0
+		for p := range dataCh {
+			if p.Status != datastruct.StatusFiltered {
+				t.Errorf("%s reported %s", p.Host, p.Status)
+			}
+			listed++
+		}
+		if FilteredHosts() != 2 || listed != tt.count {
+			t.Errorf("list %v: %d filtered, %d listed, want 2 and %d", tt.list, FilteredHosts(), listed, tt.count)
+		}
+	}
+}
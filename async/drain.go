@@ -0,0 +1,46 @@
+package async
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// DrainedReplies returns how many replies of the last scan arrived after all
+// probes had been sent.
+func DrainedReplies() int {
+	return int(atomic.LoadInt64(&drained))
+}
+
+// drain keeps the reader listening once the writer has finished. It returns
+// as soon as the reply rate of the last window decays below drainMinRate, but
+// never before drainMinTime and never after the hard cap of timeout. The
+// result is the number of replies counted meanwhile.
+func drain(replies *int64) int64 {
+	start := time.Now()
+	first := atomic.LoadInt64(replies)
+	last := first
+	window := drainWindow
+	if window <= 0 {
+		window = defaultDrainWindow * time.Millisecond
+	}
+
+	for {
+		elapsed := time.Since(start)
+		if elapsed >= timeout {
+			break
+		}
+		if remaining := timeout - elapsed; remaining < window {
+			window = remaining
+		}
+		<-time.After(window)
+
+		cur := atomic.LoadInt64(replies)
+		rate := float64(cur-last) / window.Seconds()
+		last = cur
+		if time.Since(start) >= drainMinTime && rate < drainMinRate {
+			break
+		}
+	}
+
+	return atomic.LoadInt64(replies) - first
+}
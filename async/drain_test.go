@@ -0,0 +1,57 @@
+package async
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDrain(t *testing.T) {
+	savedTimeout, savedWindow, savedMinTime, savedMinRate := timeout, drainWindow, drainMinTime, drainMinRate
+	defer func() {
+		timeout, drainWindow, drainMinTime, drainMinRate = savedTimeout, savedWindow, savedMinTime, savedMinRate
+	}()
+	timeout = 300 * time.Millisecond
+	drainWindow = 20 * time.Millisecond
+	drainMinRate = 10
+
+	var tests = []struct {
+		name     string
+		interval time.Duration
+		minTime  time.Duration
+		min, max time.Duration
+	}{
+		{"early exit", 0, 0, 20 * time.Millisecond, 100 * time.Millisecond},
+		{"min time floor", 0, 150 * time.Millisecond, 150 * time.Millisecond, 300 * time.Millisecond},
+		{"timeout cap", 2 * time.Millisecond, 0, 300 * time.Millisecond, 400 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		drainMinTime = tt.minTime
+		var replies int64
+		stop := make(chan struct{})
+		if tt.interval > 0 {
+			go func() {
+				ticker := time.NewTicker(tt.interval)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-stop:
+						return
+					case <-ticker.C:
+						atomic.AddInt64(&replies, 1)
+					}
+				}
+			}()
+		}
+		start := time.Now()
+		n := drain(&replies)
+		elapsed := time.Since(start)
+		close(stop)
+		if elapsed < tt.min || elapsed >= tt.max {
+			t.Errorf("%s: drain() took %s, want between %s and %s", tt.name, elapsed, tt.min, tt.max)
+		}
+		if (n > 0) != (tt.interval > 0) {
+			t.Errorf("%s: drain() counted %d replies", tt.name, n)
+		}
+	}
+}
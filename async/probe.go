@@ -3,12 +3,21 @@ package async
 import (
 	"active/addr"
 	"active/datastruct"
+	"active/packet"
+	"active/pcap"
+	"active/sockopt"
 	"active/utils"
 	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"github.com/spf13/viper"
+	"hash/fnv"
 	"net"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
@@ -19,11 +28,19 @@ const (
 	timeoutKey           = "async.read.timeout"
 	haltTimeKey          = "async.send.halt_time"
 	partsKey             = "async.send.parts"
+	drainWindowKey       = "async.drain.window"
+	drainMinTimeKey      = "async.drain.min_time"
+	drainMinRateKey      = "async.drain.min_rate"
+	listFilteredKey      = "async.list_filtered"
 	defaultLocalPort     = 11123
 	defaultCheckInterval = 1000
 	defaultTimeout       = 5000
 	defaultHaltTime      = 0
 	defaultParts         = 1
+	defaultDrainWindow   = 500
+	defaultDrainMinTime  = 1000
+	defaultDrainMinRate  = 1.0
+	maxDatagram          = 1500
 )
 
 var (
@@ -32,9 +49,18 @@ var (
 	haltTime      time.Duration
 	parts         int
 	localPort     int
-	errCh         chan error
-	dataCh        chan *datastruct.RcvPayload
-	wg            *sync.WaitGroup
+	drainWindow   time.Duration
+	drainMinTime  time.Duration
+	drainMinRate  float64
+	listFiltered  bool
+	drained       int64
+	filtered      int64
+	// scanStart and scanKey let a reply tell which probe it answers, see tagOf
+	scanStart time.Time
+	scanKey   uint32
+	errCh     chan error
+	dataCh    chan *datastruct.RcvPayload
+	wg        *sync.WaitGroup
 )
 
 func init() {
@@ -46,6 +72,9 @@ func init() {
 	viper.SetDefault(timeoutKey, defaultTimeout)
 	viper.SetDefault(haltTimeKey, defaultHaltTime)
 	viper.SetDefault(partsKey, defaultParts)
+	viper.SetDefault(drainWindowKey, defaultDrainWindow)
+	viper.SetDefault(drainMinTimeKey, defaultDrainMinTime)
+	viper.SetDefault(drainMinRateKey, defaultDrainMinRate)
 	err := viper.ReadInConfig()
 	if err != nil {
 		fmt.Printf("err reading resource file: %v", err)
@@ -56,9 +85,17 @@ func init() {
 	timeout = time.Duration(viper.GetInt64(timeoutKey)) * time.Millisecond
 	haltTime = time.Duration(viper.GetInt64(haltTimeKey)) * time.Millisecond
 	parts = viper.GetInt(partsKey)
+	drainWindow = time.Duration(viper.GetInt64(drainWindowKey)) * time.Millisecond
+	drainMinTime = time.Duration(viper.GetInt64(drainMinTimeKey)) * time.Millisecond
+	drainMinRate = viper.GetFloat64(drainMinRateKey)
+	listFiltered = viper.GetBool(listFilteredKey)
 }
 
 func DialNetworkNTP(cidr string) <-chan *datastruct.RcvPayload {
+	atomic.StoreInt64(&drained, 0)
+	atomic.StoreInt64(&filtered, 0)
+	scanStart = time.Now()
+	scanKey = randomKey()
 	errCh = make(chan error)
 	finishCh := make(chan struct{})
 	go func(finishCh <-chan struct{}, errCh <-chan error) {
@@ -105,16 +142,28 @@ func singleWriteRead(cidr string, index int) {
 		return
 	}
 
+	err = sockopt.EnableRecvErr(conn)
+	if err != nil {
+		errCh <- err
+	}
+	err = sockopt.EnableRecvTTL(conn)
+	if err != nil {
+		errCh <- err
+	}
+
+	seen := make(map[string]struct{})
+	var replies int64
 	doneCh := make(chan struct{})
 	go writeNetWorkNTP(cidr, conn, doneCh)
-	go readNetworkNTP(ctx, cidr, conn, doneCh)
+	go readNetworkNTP(ctx, cidr, conn, seen, &replies, doneCh)
 
 	go func() {
 		<-doneCh
-		time.After(timeout)
+		atomic.AddInt64(&drained, drain(&replies))
 		cancel()
 		<-doneCh
 		close(doneCh)
+		reportFiltered(cidr, seen)
 		wg.Done()
 		<-time.After(time.Second)
 		_ = conn.Close()
@@ -146,9 +195,52 @@ func probeNext(host string, conn *net.UDPConn) {
 		return
 	}
 
-	_, err = conn.WriteToUDP(utils.VariableData(), remoteAddr)
+	data := probeData(host, time.Now())
+	_, err = conn.WriteToUDP(data, remoteAddr)
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		// The ICMP error of an earlier probe fails this write, so send it again
+		_, err = conn.WriteToUDP(data, remoteAddr)
+	}
 	if err != nil {
 		errCh <- err
 		return
 	}
+	localAddr, _ := conn.LocalAddr().(*net.UDPAddr)
+	pcap.WriteUDP(localAddr, remoteAddr, 0, data, time.Now())
+}
+
+// probeData returns the request to host sent at t. The low 16 bits of its
+// transmit timestamp, some 15 microseconds, carry a tag of the host so that
+// the origin timestamp of a reply tells whether it answers our probe without
+// remembering every probe of the scan.
+func probeData(host string, t time.Time) []byte {
+	p := utils.FixedPacket()
+	p.TransmitTime = packet.NewTimestamp(t)&^0xFFFF | packet.Timestamp(tagOf(host))
+	return p.Marshal()
+}
+
+// requestOf rebuilds the request of host a reply with the given origin
+// timestamp answers, with the time it was sent. A genuine origin carries the
+// tag of the host and lies between the start of the scan and the reply. Any
+// other origin gets the request rebuilt with the tag at the start of the scan,
+// which the origin test then tells apart, and an unknown send time.
+func requestOf(host string, origin packet.Timestamp, rcvTime time.Time) ([]byte, time.Time) {
+	start := packet.NewTimestamp(scanStart) &^ 0xFFFF
+	sendTime := origin.TimeNear(rcvTime)
+	if uint16(origin) == tagOf(host) && !sendTime.Before(start.TimeNear(rcvTime)) && !sendTime.After(rcvTime) {
+		return probeData(host, sendTime), sendTime
+	}
+	return probeData(host, scanStart), time.Time{}
+}
+
+func tagOf(host string) uint16 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(host))
+	return uint16(h.Sum32() ^ scanKey)
+}
+
+func randomKey() uint32 {
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	return binary.BigEndian.Uint32(b[:])
 }
@@ -0,0 +1,47 @@
+package async
+
+import (
+	"active/datastruct"
+	"active/packet"
+	"testing"
+	"time"
+)
+
+func TestRequestOf(t *testing.T) {
+	scanStart = time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	scanKey = 0x5EED1234
+	host := "192.0.2.1"
+	rcvTime := scanStart.Add(40 * time.Millisecond)
+	sent, _ := packet.Unmarshal(probeData(host, scanStart.Add(10*time.Millisecond)))
+	tagged := func(t time.Time) packet.Timestamp {
+		return packet.NewTimestamp(t)&^0xFFFF | packet.Timestamp(tagOf(host))
+	}
+
+	var tests = []struct {
+		name   string
+		origin packet.Timestamp
+		bogus  bool
+	}{
+		{"our probe", sent.TransmitTime, false},
+		{"probe of another host", sent.TransmitTime ^ 1, true},
+		{"before the scan", tagged(scanStart.Add(-time.Second)), true},
+		{"after the reply", tagged(rcvTime.Add(time.Second)), true},
+		{"zero", 0, true},
+	}
+	for _, tt := range tests {
+		request, sendTime := requestOf(host, tt.origin, rcvTime)
+		if sendTime.IsZero() != tt.bogus {
+			t.Errorf("%s: send time %v", tt.name, sendTime)
+		}
+		reply := &packet.Packet{
+			Version: 4, Mode: 4, Stratum: 2, RefID: 0xC0000201, OriginTime: tt.origin,
+			RefTime:      packet.NewTimestamp(scanStart.Add(-time.Minute)),
+			ReceiveTime:  packet.NewTimestamp(scanStart.Add(20 * time.Millisecond)),
+			TransmitTime: packet.NewTimestamp(scanStart.Add(21 * time.Millisecond)),
+		}
+		flags := datastruct.CheckPacket(reply, request, sendTime, rcvTime)
+		if bogus := flags&datastruct.FlagBogusOrigin != 0; bogus != tt.bogus {
+			t.Errorf("%s: CheckPacket() = %s", tt.name, flags)
+		}
+	}
+}
@@ -22,4 +22,8 @@ var (
 func init() {
 	asyncCmd.Flags().IntVarP(&nPrintedHosts, "print", "p", 3,
 		"The number of hosts you want to print out the results, no more than 16.")
+	asyncCmd.Flags().BoolVar(&versionMatrix, "versions", false,
+		"Probe every responsive host again with version 1 to 4 requests and report the versions answered.")
+	asyncCmd.Flags().BoolVar(&validOnly, "valid-only", false,
+		"Discard the replies failing the RFC 5905 sanity tests from the output.")
 }
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var (
+	keysPath string
+	keyID    int
+	authCmd  = &cobra.Command{
+		Use:   "auth <cidr>",
+		Short: "Send symmetric-key authenticated requests and classify how hosts handle them",
+		Long: "Use the 'ntpdtc auth' command to send mode 3 packets carrying a key ID and a MAC computed " +
+			"with a key from an ntp.keys file to the specified CIDR address, verify the MAC of each " +
+			"reply and record whether the host accepts, rejects (crypto-NAK) or ignores authentication.",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := executeAuth(cmd, args)
+			if err != nil {
+				handleError(cmd, args, err)
+			}
+		},
+	}
+)
+
+func init() {
+	authCmd.Flags().StringVarP(&keysPath, "keys", "k", "",
+		"Path of the ntp.keys file.")
+	authCmd.Flags().IntVarP(&keyID, "key-id", "K", 0,
+		"Key ID to sign requests with. Setting it to 0 means using the smallest ID in the key file.")
+	authCmd.Flags().IntVarP(&nGoroutines, "grnum", "g", 0,
+		"Num of goroutines. Setting it to 0 means using the value in the configuration file.")
+	authCmd.Flags().IntVarP(&nPrintedHosts, "print", "p", 3,
+		"The number of hosts you want to print out the results, no more than 16.")
+	_ = authCmd.MarkFlagRequired("keys")
+}
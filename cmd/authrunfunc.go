@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"active/datastruct"
+	"active/kod"
+	"active/output"
+	"active/parser"
+	"active/udpdetect"
+	"active/utils"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"time"
+)
+
+func executeAuth(cmd *cobra.Command, args []string) error {
+	if nPrintedHosts > npLimit {
+		nPrintedHosts = npLimit
+	}
+	cmdName := cmd.Name()
+	if args == nil || len(args) == 0 {
+		return errors.New("command `auth` missing arguments")
+	}
+	address := args[0]
+	key, err := selectKey(keysPath, uint32(keyID))
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(os.Stdout, "Ready to run `%s`.\n    address: %s\n    key: %d (%s)\n    "+
+		"num of printed hosts: %d\n\n", cmdName, address, key.ID, key.Type, nPrintedHosts)
+
+	probe := udpdetect.Probe{Requests: [][]byte{utils.FixedDataWithKey(key)}, Type: datastruct.ProbeAuth}
+	var dataCh <-chan *datastruct.RcvPayload
+	startTime := time.Now()
+	if nGoroutines <= 0 {
+		dataCh = udpdetect.DialNetwork(address, probe)
+	} else {
+		dataCh = udpdetect.DialNetworkWithBatchSize(address, nGoroutines, probe)
+	}
+	if dataCh == nil {
+		return errors.New("dataCh is nil")
+	}
+
+	info := "auth_" + address
+	seqNum := 0
+	now := time.Now()
+	statuses := make(map[datastruct.Status]int)
+	outcomes := make(map[parser.AuthOutcome]int)
+	status := output.NewStatusWriter(info, now)
+	for p, ok := <-dataCh; ok; p, ok = <-dataCh {
+		if p.Err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, p.Err)
+			continue
+		}
+		statuses[p.Status]++
+		if p.Status != datastruct.StatusOpen && p.Status != datastruct.StatusKoD {
+			status.WriteString(p.Lines())
+			continue
+		}
+		res := parser.ParseAuth(p.RcvData, key)
+		outcomes[res.Outcome]++
+		seqNum++
+		payloadStr, parsedStr := p.Lines(), res.Lines()
+		if header, err := parser.ParseHeader(p.RcvData); err == nil {
+			parsedStr = header.Lines() + parsedStr
+		}
+		output.WriteToFile(payloadStr, parsedStr, info, seqNum, p.RcvTime, now)
+		if seqNum <= nPrintedHosts {
+			_, _ = fmt.Fprintf(os.Stdout, "[Host %d]\n", seqNum)
+			_, _ = fmt.Fprint(os.Stdout, payloadStr)
+			_, _ = fmt.Fprintln(os.Stdout, "[parsed]")
+			_, _ = fmt.Fprint(os.Stdout, parsedStr)
+		}
+	}
+	if err := status.Close(); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+	}
+	output.WriteAmplificationToFile(datastruct.DrainAmplification(), info, now)
+
+	_, _ = fmt.Fprintf(os.Stdout, "%d hosts answered authenticated requests in %s\n%s%s", seqNum,
+		utils.DurationToStr(startTime, time.Now()), statusLines(statuses), kod.Lines())
+	for _, o := range []struct {
+		name    string
+		outcome parser.AuthOutcome
+	}{
+		{"accepts:", parser.AuthAccepted},
+		{"rejects:", parser.AuthRejected},
+		{"invalid MAC:", parser.AuthInvalid},
+		{"ignores:", parser.AuthIgnored},
+	} {
+		_, _ = fmt.Fprintf(os.Stdout, "    %-18s %d\n", o.name, outcomes[o.outcome])
+	}
+
+	return nil
+}
+
+// selectKey loads the key file and picks the key with the given ID, or the
+// smallest ID when id is 0.
+func selectKey(path string, id uint32) (*utils.Key, error) {
+	keys, err := utils.LoadKeys(path)
+	if err != nil {
+		return nil, err
+	}
+	if id != 0 {
+		key, ok := keys[id]
+		if !ok {
+			return nil, fmt.Errorf("key %d not found in %s", id, path)
+		}
+		return key, nil
+	}
+	var res *utils.Key
+	for _, key := range keys {
+		if res == nil || key.ID < res.ID {
+			res = key
+		}
+	}
+	if res == nil {
+		return nil, fmt.Errorf("no key in %s", path)
+	}
+	return res, nil
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"active/control"
+	"active/datastruct"
+	"active/output"
+	"active/parser"
+	"active/utils"
+	"bytes"
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"time"
+)
+
+func executeReadVar(cmd *cobra.Command, args []string) error {
+	cmdName := cmd.Name()
+	if args == nil || len(args) == 0 {
+		return fmt.Errorf("command `%s` missing arguments", cmdName)
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("%d arguments in command `%s`, expecting 1", len(args), cmdName)
+	}
+	host := args[0]
+	_, _ = fmt.Fprintf(os.Stdout, "Ready to run `%s`.\n    host: %s\n\n", cmdName, host)
+
+	startTime := time.Now()
+	status, vars := control.ReadSystemVariables(host)
+	output.WriteAmplificationToFile(datastruct.DrainAmplification(), host+"_control", time.Now())
+
+	res, err := control.Response(status)
+	if err != nil {
+		return err
+	}
+	rs, err := parser.ParseReadStat(res)
+	if err != nil {
+		return err
+	}
+	printControl(status, rs.Lines(), host)
+
+	res, err = control.Response(vars)
+	if err != nil {
+		return err
+	}
+	sv, err := parser.ParseSystemVariables(res)
+	if err != nil {
+		return err
+	}
+	printControl(vars, sv.Lines(), host)
+
+	_, _ = fmt.Fprintf(os.Stdout, "Mode 6 queries were completed in %s\n",
+		utils.DurationToStr(startTime, time.Now()))
+
+	return nil
+}
+
+func executePeers(cmd *cobra.Command, args []string) error {
+	cmdName := cmd.Name()
+	if args == nil || len(args) == 0 {
+		return fmt.Errorf("command `%s` missing arguments", cmdName)
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("%d arguments in command `%s`, expecting 1", len(args), cmdName)
+	}
+	host := args[0]
+	_, _ = fmt.Fprintf(os.Stdout, "Ready to run `%s`.\n    host: %s\n\n", cmdName, host)
+
+	startTime := time.Now()
+	graph, payloads, err := control.WalkPeers(host)
+	output.WriteAmplificationToFile(datastruct.DrainAmplification(), host+"_control", time.Now())
+	if graph == nil {
+		return err
+	}
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+	}
+
+	raw := new(bytes.Buffer)
+	for _, p := range payloads {
+		if p.Err == nil {
+			raw.WriteString(p.Lines())
+		}
+	}
+	parsed := graph.Lines()
+	_, _ = fmt.Fprintf(os.Stdout, "[parsed]\n%s", parsed)
+	output.WriteControlToFile(raw.String(), parsed, host)
+	if edgePath != "" {
+		err = output.AppendToFile(edgePath, graph.EdgeLines())
+		if err != nil {
+			return err
+		}
+	}
+
+	_, _ = fmt.Fprintf(os.Stdout, "%d associations walked in %s\n",
+		len(payloads)-2, utils.DurationToStr(startTime, time.Now()))
+
+	return nil
+}
+
+func executeMRUList(cmd *cobra.Command, args []string) error {
+	cmdName := cmd.Name()
+	if args == nil || len(args) == 0 {
+		return fmt.Errorf("command `%s` missing arguments", cmdName)
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("%d arguments in command `%s`, expecting 1", len(args), cmdName)
+	}
+	host := args[0]
+	_, _ = fmt.Fprintf(os.Stdout, "Ready to run `%s`.\n    host: %s\n\n", cmdName, host)
+
+	startTime := time.Now()
+	stats, err := control.ReadMRU(host)
+	output.WriteAmplificationToFile(datastruct.DrainAmplification(), host+"_mru", time.Now())
+	if stats.Entries == 0 {
+		return err
+	}
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+	}
+
+	parsed := stats.Lines()
+	_, _ = fmt.Fprintf(os.Stdout, "[parsed]\n%s", parsed)
+	output.WriteControlToFile("", parsed, host+"_mru")
+
+	_, _ = fmt.Fprintf(os.Stdout, "MRU list retrieved in %s\n", utils.DurationToStr(startTime, time.Now()))
+
+	return nil
+}
+
+func printControl(p *datastruct.ControlPayload, parsed, host string) {
+	raw := p.Lines()
+	_, _ = fmt.Fprintf(os.Stdout, "%s[parsed]\n%s", raw, parsed)
+	output.WriteControlToFile(raw, parsed, host)
+}
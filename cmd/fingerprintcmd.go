@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var (
+	extraProbes    bool
+	rulesPath      string
+	fingerprintCmd = &cobra.Command{
+		Use:   "fingerprint <cidr>",
+		Short: "Classify the NTP implementation of each host with fingerprinting rules",
+		Long: "Use the 'ntpdtc fingerprint' command to send mode 3 packets to the specified CIDR address " +
+			"and classify each responder as ntpd, NTPsec, chrony, OpenNTPD, Windows w32time, busybox, " +
+			"Meinberg or an embedded stack from its header fields, optionally adding the results of " +
+			"version, mode 6, malformed and interleaved request probes, with a confidence score.",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := executeFingerprint(cmd, args)
+			if err != nil {
+				handleError(cmd, args, err)
+			}
+		},
+	}
+)
+
+func init() {
+	fingerprintCmd.Flags().BoolVarP(&extraProbes, "extra", "x", false,
+		"Also probe every responder with versions 1 to 4, mode 6 READVAR, malformed and interleaved requests.")
+	fingerprintCmd.Flags().StringVarP(&rulesPath, "rules", "r", "",
+		"Path of a JSON rules file. Leaving it empty means using the configured or the built-in rules.")
+	fingerprintCmd.Flags().IntVarP(&nGoroutines, "grnum", "g", 0,
+		"Num of goroutines. Setting it to 0 means using the value in the configuration file.")
+	fingerprintCmd.Flags().IntVarP(&nPrintedHosts, "print", "p", 3,
+		"The number of hosts you want to print out the results, no more than 16.")
+}
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"active/control"
+	"active/datastruct"
+	"active/fingerprint"
+	"active/malformed"
+	"active/output"
+	"active/parser"
+	"active/udpdetect"
+	"active/utils"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+func executeFingerprint(cmd *cobra.Command, args []string) error {
+	if nPrintedHosts > npLimit {
+		nPrintedHosts = npLimit
+	}
+	cmdName := cmd.Name()
+	if args == nil || len(args) == 0 {
+		return errors.New("command `fingerprint` missing arguments")
+	}
+	address := args[0]
+	rules, err := fingerprint.LoadRules(rulesPath)
+	if err != nil {
+		return err
+	}
+	variants, err := malformed.Variants()
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(os.Stdout, "Ready to run `%s`.\n    address: %s\n    extra probes: %t\n    "+
+		"num of printed hosts: %d\n\n", cmdName, address, extraProbes, nPrintedHosts)
+
+	request := utils.FixedData()
+	startTime := time.Now()
+	payloads, err := collectOpen(address, request)
+	if err != nil {
+		return err
+	}
+
+	headers := make([]*parser.Header, len(payloads))
+	for i, p := range payloads {
+		headers[i], _ = parser.ParseHeader(p.RcvData)
+	}
+	observations := make([]*fingerprint.Observation, len(payloads))
+	wg := new(sync.WaitGroup)
+	sem := make(chan struct{}, probeBatchSize())
+	for i, p := range payloads {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, p *datastruct.RcvPayload) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			observations[i] = observe(p, headers[i], request, variants)
+		}(i, p)
+	}
+	wg.Wait()
+
+	info := "fingerprint_" + address
+	now := time.Now()
+	counts := make(map[string]int)
+	for i, o := range observations {
+		res := rules.Classify(o)
+		counts[res.Best.Name]++
+		payloadStr, parsedStr := payloads[i].Lines(), res.Lines()
+		if headers[i] != nil {
+			refID := parser.ClassifyRefID(headers[i].Packet, refIDHints(o.Host, res.Best.Name))
+			parsedStr = fmt.Sprintf("Reference ID:    %s\n", refID) + parsedStr
+		}
+		output.WriteToFile(payloadStr, parsedStr, info, i+1, payloads[i].RcvTime, now)
+		if i < nPrintedHosts {
+			_, _ = fmt.Fprintf(os.Stdout, "[Host %d]\n", i+1)
+			_, _ = fmt.Fprint(os.Stdout, payloadStr)
+			_, _ = fmt.Fprintln(os.Stdout, "[parsed]")
+			_, _ = fmt.Fprint(os.Stdout, parsedStr)
+		}
+	}
+	output.WriteAmplificationToFile(datastruct.DrainAmplification(), info, now)
+
+	_, _ = fmt.Fprintf(os.Stdout, "%d hosts fingerprinted in %s\n", len(observations),
+		utils.DurationToStr(startTime, time.Now()))
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return counts[names[i]] > counts[names[j]] || counts[names[i]] == counts[names[j]] && names[i] < names[j]
+	})
+	for _, name := range names {
+		_, _ = fmt.Fprintf(os.Stdout, "    %-18s %d\n", name+":", counts[name])
+	}
+	return nil
+}
+
+// collectOpen sends request to every address of the CIDR and returns the
+// replies of the hosts that answered.
+func collectOpen(address string, request []byte) ([]*datastruct.RcvPayload, error) {
+	probe := udpdetect.Probe{Requests: [][]byte{request}}
+	var dataCh <-chan *datastruct.RcvPayload
+	if nGoroutines <= 0 {
+		dataCh = udpdetect.DialNetwork(address, probe)
+	} else {
+		dataCh = udpdetect.DialNetworkWithBatchSize(address, nGoroutines, probe)
+	}
+	if dataCh == nil {
+		return nil, errors.New("dataCh is nil")
+	}
+	payloads := make([]*datastruct.RcvPayload, 0)
+	for p, ok := <-dataCh; ok; p, ok = <-dataCh {
+		if p.Err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, p.Err)
+			continue
+		}
+		if p.Status == datastruct.StatusOpen {
+			payloads = append(payloads, p)
+		}
+	}
+	return payloads, nil
+}
+
+// observe extracts the features of a reply and its parsed header, adding the
+// version matrix, the mode 6 system variables, the answers to malformed
+// requests and the timestamp handling of the host when extra probes are
+// enabled.
+func observe(p *datastruct.RcvPayload, header *parser.Header, request []byte,
+	variants []malformed.Variant) *fingerprint.Observation {
+	o := fingerprint.Observe(p.Host, request, header, p.Len)
+	if !extraProbes {
+		return o
+	}
+	m, _ := probeVersions(p.Host)
+	o.AddVersionMatrix(m)
+	var sv *parser.SystemVariables
+	if res, err := control.Response(control.Dial(p.Host, parser.ReadVarOpcode, 0, nil)); err == nil {
+		sv, _ = parser.ParseSystemVariables(res)
+	}
+	o.AddSystemVariables(sv)
+	o.AddMalformed(malformed.Probe(p.Host, variants))
+	o.AddTimestamps(udpdetect.ProbeTimestamps(p.Host))
+	return o
+}
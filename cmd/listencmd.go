@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var (
+	ifName         string
+	listenDuration int
+	listenCmd      = &cobra.Command{
+		Use:   "listen",
+		Short: "Passively collect broadcast and multicast NTP packets",
+		Long: "Use the 'ntpdtc listen' command to bind UDP port 123, join the NTP multicast groups " +
+			"224.0.1.1 and ff0x::101 and report the time sources heard on the local network. " +
+			"Nothing is sent.",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := executeListen(cmd, args)
+			if err != nil {
+				handleError(cmd, args, err)
+			}
+		},
+	}
+)
+
+func init() {
+	listenCmd.Flags().StringVarP(&ifName, "interface", "i", "",
+		"The interface to join the multicast groups on, the default one if empty.")
+	listenCmd.Flags().IntVarP(&listenDuration, "duration", "d", 60,
+		"How many seconds to listen for.")
+	listenCmd.Flags().IntVarP(&nPrintedHosts, "print", "p", 3,
+		"The number of hosts you want to print out the results, no more than 16.")
+}
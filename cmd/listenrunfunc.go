@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"active/listen"
+	"active/output"
+	"active/parser"
+	"active/utils"
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// device sums up the packets heard from one time source.
+type device struct {
+	host    string
+	packets int
+	modes   map[string]int
+	stratum string
+}
+
+func executeListen(cmd *cobra.Command, args []string) error {
+	if nPrintedHosts > npLimit {
+		nPrintedHosts = npLimit
+	}
+	cmdName := cmd.Name()
+	if len(args) > 0 {
+		return fmt.Errorf("%d arguments in command `%s`, expecting 0", len(args), cmdName)
+	}
+	name := ifName
+	if name == "" {
+		name = "default"
+	}
+	_, _ = fmt.Fprintf(os.Stdout, "Ready to run `%s`.\n    interface: %s\n    duration: %ds\n\n",
+		cmdName, name, listenDuration)
+
+	startTime := time.Now()
+	dataCh, err := listen.Listen(ifName, time.Duration(listenDuration)*time.Second)
+	if err != nil {
+		return err
+	}
+
+	info := "listen_" + name
+	now := time.Now()
+	devices := make(map[string]*device)
+	order := make([]string, 0)
+	total := 0
+	for p, ok := <-dataCh; ok; p, ok = <-dataCh {
+		header, err := parser.ParseHeader(p.RcvData)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			continue
+		}
+		total++
+		d, seen := devices[p.Host]
+		if !seen {
+			d = &device{host: p.Host, modes: make(map[string]int)}
+			devices[p.Host] = d
+			order = append(order, p.Host)
+		}
+		d.packets++
+		d.modes[header.Mode]++
+		d.stratum = header.Stratum
+		if seen {
+			continue
+		}
+		// Only the first packet of every device is written out
+		payloadStr, headerStr := p.Lines(), header.Lines()
+		output.WriteToFile(payloadStr, headerStr, info, len(order), p.RcvTime, now)
+		if len(order) <= nPrintedHosts {
+			_, _ = fmt.Fprintf(os.Stdout, "[Host %d]\n", len(order))
+			_, _ = fmt.Fprint(os.Stdout, payloadStr)
+			_, _ = fmt.Fprintln(os.Stdout, "[parsed]")
+			_, _ = fmt.Fprint(os.Stdout, headerStr)
+		}
+	}
+
+	_, _ = fmt.Fprintf(os.Stdout, "%d packets from %d devices heard in %s\n",
+		total, len(order), utils.DurationToStr(startTime, time.Now()))
+	for _, host := range order {
+		d := devices[host]
+		modes := make([]string, 0, len(d.modes))
+		for m, n := range d.modes {
+			modes = append(modes, fmt.Sprintf("%s x%d", m, n))
+		}
+		sort.Strings(modes)
+		_, _ = fmt.Fprintf(os.Stdout, "    %-40s %-6d %s, stratum %s\n", host, d.packets, strings.Join(modes, ", "), d.stratum)
+	}
+
+	return nil
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var (
+	compliancePath string
+	malformedCmd   = &cobra.Command{
+		Use:   "malformed <cidr>",
+		Short: "Send malformed requests to responders and record how each one handles them",
+		Long: "Use the 'ntpdtc malformed' command to find the hosts of the specified CIDR address " +
+			"answering mode 3, then send them crafted variants of the request (bad version, reserved " +
+			"mode, truncated or oversized packet, leap indicator, unknown extension field, zero transmit " +
+			"timestamp) and record which ones each host answers compared with a compliant server.",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := executeMalformed(cmd, args)
+			if err != nil {
+				handleError(cmd, args, err)
+			}
+		},
+	}
+)
+
+func init() {
+	malformedCmd.Flags().StringVarP(&compliancePath, "csv", "c", "",
+		"Path of a CSV file to write the compliance report to.")
+	malformedCmd.Flags().IntVarP(&nGoroutines, "grnum", "g", 0,
+		"Num of goroutines. Setting it to 0 means using the value in the configuration file.")
+	malformedCmd.Flags().IntVarP(&nPrintedHosts, "print", "p", 3,
+		"The number of hosts you want to print out the results, no more than 16.")
+}
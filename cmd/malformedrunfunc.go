@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"active/datastruct"
+	"active/malformed"
+	"active/output"
+	"active/utils"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"sync"
+	"time"
+)
+
+func executeMalformed(cmd *cobra.Command, args []string) error {
+	if nPrintedHosts > npLimit {
+		nPrintedHosts = npLimit
+	}
+	cmdName := cmd.Name()
+	if args == nil || len(args) == 0 {
+		return errors.New("command `malformed` missing arguments")
+	}
+	address := args[0]
+	variants, err := malformed.Variants()
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(os.Stdout, "Ready to run `%s`.\n    address: %s\n    variants: %d\n    "+
+		"num of printed hosts: %d\n\n", cmdName, address, len(variants), nPrintedHosts)
+
+	startTime := time.Now()
+	payloads, err := collectOpen(address, utils.FixedData())
+	if err != nil {
+		return err
+	}
+
+	reports := make([]*malformed.Report, len(payloads))
+	wg := new(sync.WaitGroup)
+	sem := make(chan struct{}, probeBatchSize())
+	for i, p := range payloads {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, host string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			reports[i] = malformed.Probe(host, variants)
+		}(i, p.Host)
+	}
+	wg.Wait()
+
+	info := "malformed_" + address
+	now := time.Now()
+	answered := make(map[string]int)
+	violations := make(map[string]int)
+	csvBuf := bytes.NewBufferString(malformed.CSVHeader(variants))
+	for i, r := range reports {
+		for _, a := range r.Answers {
+			if a.Answered() {
+				answered[a.Variant]++
+			}
+			if !a.Compliant() {
+				violations[a.Variant]++
+			}
+		}
+		csvBuf.WriteString(r.CSVLine())
+		parsed := r.Lines()
+		output.WriteToFile(payloads[i].Lines(), parsed, info, i+1, payloads[i].RcvTime, now)
+		if i < nPrintedHosts {
+			_, _ = fmt.Fprint(os.Stdout, parsed)
+		}
+	}
+	output.WriteAmplificationToFile(datastruct.DrainAmplification(), info, now)
+	if compliancePath != "" {
+		err = os.WriteFile(compliancePath, csvBuf.Bytes(), 0644)
+		if err != nil {
+			return fmt.Errorf("error writing compliance report %s: %v", compliancePath, err)
+		}
+	}
+
+	_, _ = fmt.Fprintf(os.Stdout, "%d hosts probed with malformed requests in %s\n", len(reports),
+		utils.DurationToStr(startTime, time.Now()))
+	_, _ = fmt.Fprintf(os.Stdout, "    %-18s %8s %10s\n", "variant", "answered", "violations")
+	for _, v := range variants {
+		_, _ = fmt.Fprintf(os.Stdout, "    %-18s %8d %10d\n", v.Name, answered[v.Name], violations[v.Name])
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var (
+	monlistCmd = &cobra.Command{
+		Use:   "monlist <cidr>",
+		Short: "Send mode 7 monitor list requests and measure the amplification",
+		Long: "Use the 'ntpdtc monlist' command to send MON_GETLIST_1 requests to the specified CIDR " +
+			"address, trying both ntpd implementation numbers. Every packet of a response is counted " +
+			"and hosts answering with more bytes than requested are flagged as reflectors.",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := executeMonlist(cmd, args)
+			if err != nil {
+				handleError(cmd, args, err)
+			}
+		},
+	}
+)
+
+func init() {
+	monlistCmd.Flags().IntVarP(&nGoroutines, "grnum", "g", 0,
+		"Num of goroutines. Setting it to 0 means using the value in the configuration file.")
+	monlistCmd.Flags().IntVarP(&nPrintedHosts, "print", "p", 3,
+		"The number of hosts you want to print out the results, no more than 16.")
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"active/datastruct"
+	"active/output"
+	"active/parser"
+	"active/udpdetect"
+	"active/utils"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"time"
+)
+
+func executeMonlist(cmd *cobra.Command, args []string) error {
+	if nPrintedHosts > npLimit {
+		nPrintedHosts = npLimit
+	}
+	cmdName := cmd.Name()
+	if args == nil || len(args) == 0 {
+		return errors.New("command `monlist` missing arguments")
+	}
+	address := args[0]
+	_, _ = fmt.Fprintf(os.Stdout, "Ready to run `%s`.\n    address: %s\n    "+
+		"num of printed hosts: %d\n\n", cmdName, address, nPrintedHosts)
+
+	probe := udpdetect.Probe{
+		Requests: [][]byte{
+			parser.NewMonlistRequest(parser.ImplXNTPD, parser.MonGetList1),
+			parser.NewMonlistRequest(parser.ImplXNTPDOld, parser.MonGetList1),
+		},
+		Multi: true,
+		Type:  datastruct.ProbeMonlist,
+	}
+	var dataCh <-chan *datastruct.RcvPayload
+	startTime := time.Now()
+	if nGoroutines <= 0 {
+		dataCh = udpdetect.DialNetwork(address, probe)
+	} else {
+		dataCh = udpdetect.DialNetworkWithBatchSize(address, nGoroutines, probe)
+	}
+	if dataCh == nil {
+		return errors.New("dataCh is nil")
+	}
+
+	info := "monlist_" + address
+	seqNum, reflectors := 0, 0
+	sent, received := 0, 0
+	maxFactor := 0.0
+	now := time.Now()
+	statuses := make(map[datastruct.Status]int)
+	status := output.NewStatusWriter(info, now)
+	for p, ok := <-dataCh; ok; p, ok = <-dataCh {
+		if p.Err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, p.Err)
+			continue
+		}
+		statuses[p.Status]++
+		if p.Status == datastruct.StatusClosed || p.Status == datastruct.StatusFiltered {
+			status.WriteString(p.Lines())
+			continue
+		}
+		if p.Status != datastruct.StatusOpen {
+			continue
+		}
+		m, err := parser.ParseMonlist(p.Datagrams, p.SendLen)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			continue
+		}
+		seqNum++
+		sent += p.SendLen
+		received += p.Len
+		if m.IsReflector() {
+			reflectors++
+			if m.Amplification() > maxFactor {
+				maxFactor = m.Amplification()
+			}
+		}
+		payloadStr, parsedStr := p.Lines(), m.Lines()
+		output.WriteToFile(payloadStr, parsedStr, info, seqNum, p.RcvTime, now)
+		if seqNum <= nPrintedHosts {
+			_, _ = fmt.Fprintf(os.Stdout, "[Host %d]\n", seqNum)
+			_, _ = fmt.Fprint(os.Stdout, payloadStr)
+			_, _ = fmt.Fprintln(os.Stdout, "[parsed]")
+			_, _ = fmt.Fprint(os.Stdout, parsedStr)
+		}
+	}
+	if err := status.Close(); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+	}
+	output.WriteAmplificationToFile(datastruct.DrainAmplification(), info, now)
+
+	_, _ = fmt.Fprintf(os.Stdout, "%d hosts answered mode 7 in %s\n%s    %-18s %d\n    %-18s %d / %d\n    %-18s %.2f\n",
+		seqNum, utils.DurationToStr(startTime, time.Now()), statusLines(statuses),
+		"reflectors:", reflectors, "bytes out / in:", sent, received, "max amplification:", maxFactor)
+
+	return nil
+}
@@ -0,0 +1,19 @@
+package cmd
+
+import "github.com/spf13/cobra"
+
+var (
+	mruListCmd = &cobra.Command{
+		Use:   "mrulist <host>",
+		Short: "Retrieve the mode 6 MRU list of a host and summarize its clients",
+		Long: "Use the 'ntpdtc mrulist' command to page through the list of recently seen clients " +
+			"of the specified remote host. Only aggregate statistics are kept: the number of clients, " +
+			"a per-/24 histogram and the distribution of modes and versions.",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := executeMRUList(cmd, args)
+			if err != nil {
+				handleError(cmd, args, err)
+			}
+		},
+	}
+)
@@ -0,0 +1,25 @@
+package cmd
+
+import "github.com/spf13/cobra"
+
+var (
+	edgePath string
+	peersCmd = &cobra.Command{
+		Use:   "peers <host>",
+		Short: "Walk the mode 6 associations of a host and build its peer graph",
+		Long: "Use the 'ntpdtc peers' command to list the associations of the specified remote host " +
+			"with READSTAT, then read the variables of each one to find its upstream peers, their " +
+			"reference IDs, stratum, reach and selection state.",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := executePeers(cmd, args)
+			if err != nil {
+				handleError(cmd, args, err)
+			}
+		},
+	}
+)
+
+func init() {
+	peersCmd.Flags().StringVarP(&edgePath, "edges", "e", "",
+		"Append the edges of the peer graph to the given CSV file.")
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var (
+	maxBurst      int
+	burstInterval int
+	rateLimitCmd  = &cobra.Command{
+		Use:   "ratelimit <host>...",
+		Short: "Send growing bursts to hosts and estimate their rate-limiting policy",
+		Long: "Use the 'ntpdtc ratelimit' command to send bursts of 1, 2, 4, ... mode 3 packets within " +
+			"the same interval to each specified host, detect when it starts dropping packets or " +
+			"returning RATE Kiss-o'-Death, and estimate its burst allowance and minimum interval.",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := executeRateLimit(cmd, args)
+			if err != nil {
+				handleError(cmd, args, err)
+			}
+		},
+	}
+)
+
+func init() {
+	rateLimitCmd.Flags().IntVarP(&maxBurst, "burst", "b", 0,
+		"Largest burst size. Setting it to 0 means using the value in the configuration file.")
+	rateLimitCmd.Flags().IntVarP(&burstInterval, "interval", "i", 0,
+		"Interval in milliseconds each burst is spread over. Setting it to 0 means using the value "+
+			"in the configuration file.")
+}
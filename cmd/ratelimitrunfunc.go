@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"active/datastruct"
+	"active/output"
+	"active/udpdetect"
+	"active/utils"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"time"
+)
+
+func executeRateLimit(cmd *cobra.Command, args []string) error {
+	cmdName := cmd.Name()
+	if args == nil || len(args) == 0 {
+		return errors.New("command `ratelimit` missing arguments")
+	}
+	if maxBurst <= 0 {
+		maxBurst = udpdetect.MaxBurst
+	}
+	interval := udpdetect.BurstInterval
+	if burstInterval > 0 {
+		interval = time.Millisecond * time.Duration(burstInterval)
+	}
+	_, _ = fmt.Fprintf(os.Stdout, "Ready to run `%s`.\n    hosts: %d\n    max burst: %d\n    "+
+		"interval: %s\n\n", cmdName, len(args), maxBurst, interval)
+
+	startTime := time.Now()
+	now := time.Now()
+	limited := 0
+	for i, host := range args {
+		report := udpdetect.CharacterizeRateLimit(host, maxBurst, interval)
+		if report.Limited() {
+			limited++
+		}
+		lines := report.Lines()
+		_, _ = fmt.Fprint(os.Stdout, lines)
+		output.WriteToFile("", lines, "ratelimit", i+1, time.Now(), now)
+	}
+	output.WriteAmplificationToFile(datastruct.DrainAmplification(), "ratelimit", now)
+
+	_, _ = fmt.Fprintf(os.Stdout, "%d of %d hosts rate limited, characterized in %s\n",
+		limited, len(args), utils.DurationToStr(startTime, time.Now()))
+	return nil
+}
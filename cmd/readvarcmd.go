@@ -0,0 +1,18 @@
+package cmd
+
+import "github.com/spf13/cobra"
+
+var (
+	readVarCmd = &cobra.Command{
+		Use:   "readvar <host>",
+		Short: "Send mode 6 READSTAT and READVAR requests and parse the system variables",
+		Long: "Use the 'ntpdtc readvar' command to query the control interface of the specified " +
+			"remote host, which may reveal the daemon version, processor and operating system.",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := executeReadVar(cmd, args)
+			if err != nil {
+				handleError(cmd, args, err)
+			}
+		},
+	}
+)
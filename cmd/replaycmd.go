@@ -0,0 +1,29 @@
+package cmd
+
+import "github.com/spf13/cobra"
+
+var (
+	statisticPath string
+	replayCmd     = &cobra.Command{
+		Use:   "replay <file>",
+		Short: "Re-analyse the NTP and NTS-KE payloads of a capture file",
+		Long: "Use the 'ntpdtc replay' command to extract NTP replies and NTS-KE exchanges from a pcap or " +
+			"pcapng file and run them through the parsers and writers as if they came from a live scan. " +
+			"NTS-KE records are decrypted with the TLS key log stored in the capture.",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := executeReplay(cmd, args)
+			if err != nil {
+				handleError(cmd, args, err)
+			}
+		},
+	}
+)
+
+func init() {
+	replayCmd.Flags().IntVarP(&nPrintedHosts, "print", "p", 3,
+		"The number of hosts you want to print out the results, no more than 16.")
+	replayCmd.Flags().StringVarP(&statisticPath, "statistic", "s", "",
+		"Also write the statistics of every reply to this file, as JSON lines if it ends with .json, otherwise as CSV.")
+	replayCmd.Flags().BoolVar(&validOnly, "valid-only", false,
+		"Discard the replies failing the RFC 5905 sanity tests from the output.")
+}
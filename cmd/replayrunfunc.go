@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"active/datastruct"
+	"active/output"
+	"active/parser"
+	"active/replay"
+	"active/utils"
+	"bufio"
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func executeReplay(cmd *cobra.Command, args []string) error {
+	if nPrintedHosts > npLimit {
+		nPrintedHosts = npLimit
+	}
+	cmdName := cmd.Name()
+	if len(args) != 1 {
+		return fmt.Errorf("%d arguments in command `%s`, expecting 1", len(args), cmdName)
+	}
+	path := args[0]
+	_, _ = fmt.Fprintf(os.Stdout, "Ready to run `%s`.\n    file: %s\n    num of printed hosts: %d\n\n",
+		cmdName, path, nPrintedHosts)
+
+	startTime := time.Now()
+	res, err := replay.ReadCapture(path)
+	if err != nil {
+		return err
+	}
+	for _, e := range res.Errors {
+		_, _ = fmt.Fprintln(os.Stderr, e)
+	}
+
+	dataCh := make(chan *datastruct.RcvPayload, len(res.NTP))
+	for _, p := range res.NTP {
+		// The capture holds no probe of ours, its exchanges are recorded as they are
+		if p.Err == nil && p.Status == datastruct.StatusOpen {
+			datastruct.RecordAmplification(datastruct.NewAmplification(datastruct.ProbeMode3, p))
+		}
+		dataCh <- p
+	}
+	close(dataCh)
+	count, _, _ := printResult(dataCh, "replay_"+filepath.Base(path))
+
+	if statisticPath != "" {
+		err = writeStatistics(res.NTP, statisticPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, p := range res.NTS {
+		parsed, err := parser.ParseNTSResponse(p.RcvData)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "parse NTS-KE response of %s failed: %v\n", p.Host, err)
+			continue
+		}
+		raw := p.Lines()
+		_, _ = fmt.Fprintf(os.Stdout, "%s[parsed]\n%s", raw, parsed.Lines())
+		output.WriteNTSToFile(raw, parsed.Lines(), p.Host)
+	}
+
+	_, _ = fmt.Fprintf(os.Stdout, "%d hosts and %d NTS-KE exchanges replayed in %s (%d packets skipped)\n",
+		count, len(res.NTS), utils.DurationToStr(startTime, time.Now()), res.Skipped)
+
+	return nil
+}
+
+func writeStatistics(payloads []*datastruct.RcvPayload, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating statistic file %s: %v", path, err)
+	}
+	defer func() { _ = file.Close() }()
+	writer := bufio.NewWriter(file)
+	jsonOutput := strings.HasSuffix(path, ".json")
+
+	for _, p := range payloads {
+		if p.Err != nil || p.Status != datastruct.StatusOpen {
+			continue
+		}
+		header, err := parser.ParseHeaderWithHints(p.RcvData, refIDHints(p.Host, ""))
+		if err != nil || (validOnly && !p.Valid()) {
+			continue
+		}
+		s := datastruct.NewStatistic(p, header.Packet, header.RefIDClass.Kind)
+		if jsonOutput {
+			err = s.WriteToJSON(writer)
+		} else {
+			err = s.WriteToCSV(writer)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		return fmt.Errorf("error flushing writer: %v", err)
+	}
+	return nil
+}
@@ -1,25 +1,53 @@
 package cmd
 
 import (
+	"active/pcap"
 	"fmt"
 	"github.com/spf13/cobra"
 	"os"
 )
 
 var (
-	rootCmd = &cobra.Command{
+	pcapPath string
+	rootCmd  = &cobra.Command{
 		Use:   "ntpdtc",
 		Short: "Ntpdtc is a tool used to detect NTP devices.",
 		Long: "Ntpdtc is a tool used to detect NTP devices. The attributes that can be detected include " +
 			"the OS version, running service and version, NTP reference clock information, etc.",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if pcapPath == "" {
+				return nil
+			}
+			return pcap.Open(pcapPath)
+		},
+		PersistentPostRun: func(cmd *cobra.Command, args []string) {
+			err := pcap.Close()
+			if err != nil {
+				_, _ = fmt.Fprintln(os.Stderr, err)
+			}
+		},
 	}
 )
 
 func init() {
+	rootCmd.PersistentFlags().StringVar(&pcapPath, "pcap", "",
+		"Write every probe and reply to the given pcapng file, NTS-KE connections come with their TLS key log.")
 	rootCmd.AddCommand(timeSyncCmd)
 	rootCmd.AddCommand(asyncCmd)
 	rootCmd.AddCommand(ntsCmd)
 	rootCmd.AddCommand(ntsAlgoCmd)
+	rootCmd.AddCommand(replayCmd)
+	rootCmd.AddCommand(readVarCmd)
+	rootCmd.AddCommand(peersCmd)
+	rootCmd.AddCommand(mruListCmd)
+	rootCmd.AddCommand(monlistCmd)
+	rootCmd.AddCommand(symmetricCmd)
+	rootCmd.AddCommand(listenCmd)
+	rootCmd.AddCommand(rateLimitCmd)
+	rootCmd.AddCommand(authCmd)
+	rootCmd.AddCommand(fingerprintCmd)
+	rootCmd.AddCommand(malformedCmd)
+	rootCmd.AddCommand(timestampsCmd)
 }
 
 func Execute() {
@@ -31,5 +59,6 @@ func Execute() {
 
 func handleError(cmd *cobra.Command, args []string, err error) {
 	_, _ = fmt.Fprintf(os.Stderr, "execute %s args:%v error:%v\n", cmd.Name(), args, err)
+	_ = pcap.Close()
 	os.Exit(1)
 }
@@ -3,13 +3,16 @@ package cmd
 import (
 	"active/async"
 	"active/datastruct"
+	"active/kod"
 	"active/output"
 	"active/parser"
 	"active/udpdetect"
 	"active/utils"
+	"bytes"
 	"errors"
 	"fmt"
 	"github.com/spf13/cobra"
+	"net"
 	"os"
 	"strconv"
 	"time"
@@ -47,10 +50,14 @@ func executeTimeSync(cmd *cobra.Command, args []string) error {
 	if dataCh == nil {
 		return errors.New("dataCh is nil")
 	}
-	count := printResult(dataCh, "timesync_"+address)
+	count, statuses, hosts := printResult(dataCh, "timesync_"+address)
 
-	_, _ = fmt.Fprintf(os.Stdout, "%d hosts detected in %s\n",
-		count, utils.DurationToStr(startTime, time.Now()))
+	_, _ = fmt.Fprintf(os.Stdout, "%d hosts detected in %s\n%s%s",
+		count, utils.DurationToStr(startTime, time.Now()), statusLines(statuses), kod.Lines())
+
+	if versionMatrix {
+		printVersionMatrices(hosts, "versions_"+address)
+	}
 
 	return nil
 }
@@ -68,23 +75,36 @@ func executeAsync(cmd *cobra.Command, args []string) error {
 		"num of printed hosts: %d\n\n", cmdName, address, nPrintedHosts)
 
 	startTime := time.Now()
-	dataCh := async.DialNetworkNTP(address, 1)
+	dataCh := async.DialNetworkNTP(address)
 
 	if dataCh == nil {
 		return errors.New("dataCh is nil")
 	}
 
-	count := printResult(dataCh, "async_"+address)
+	count, statuses, hosts := printResult(dataCh, "async_"+address)
+	// Silent hosts are counted by the scan, listing them is optional
+	statuses[datastruct.StatusFiltered] = async.FilteredHosts()
 
-	_, _ = fmt.Fprintf(os.Stdout, "%d hosts detected in %s\n",
-		count, utils.DurationToStr(startTime, time.Now()))
+	_, _ = fmt.Fprintf(os.Stdout, "%d hosts detected in %s\n%s    %-18s %d\n%s",
+		count, utils.DurationToStr(startTime, time.Now()), statusLines(statuses),
+		"replies in drain:", async.DrainedReplies(), kod.Lines())
+
+	if versionMatrix {
+		printVersionMatrices(hosts, "versions_"+address)
+	}
 
 	return nil
 }
 
-func printResult(dataCh <-chan *datastruct.RcvPayload, cmd string) int {
+func printResult(dataCh <-chan *datastruct.RcvPayload, cmd string) (int, map[datastruct.Status]int, []string) {
 	seqNum := 0
 	now := time.Now()
+	statuses := make(map[datastruct.Status]int)
+	status := output.NewStatusWriter(cmd, now)
+	hosts := make([]string, 0)
+	sanity := datastruct.NewSanityChecker()
+	discarded := 0
+	pending := parser.NewPendingRefIDs()
 
 	for p, ok := <-dataCh; ok; p, ok = <-dataCh {
 		err := p.Err
@@ -92,11 +112,32 @@ func printResult(dataCh <-chan *datastruct.RcvPayload, cmd string) int {
 			_, _ = fmt.Fprint(os.Stderr, err)
 			continue
 		}
-		header, err := parser.ParseHeader(p.RcvData)
+		statuses[p.Status]++
+		if p.Status == datastruct.StatusClosed || p.Status == datastruct.StatusFiltered {
+			status.WriteString(p.Lines())
+			continue
+		}
+		// A kiss is a reply too, but it fails the sanity tests by design
+		valid := true
+		if p.Status == datastruct.StatusOpen {
+			// Responders of our own scans resolve the IPv6 hashes of their clients,
+			// those found later in the scan are resolved once it is over
+			parser.AddIPv6Server(p.Host)
+			sanity.Check(p)
+			valid = !validOnly || p.Valid()
+		}
+		header, err := parser.ParseHeaderWithHints(p.RcvData, refIDHints(p.Host, ""))
+		if err == nil {
+			pending.Add(header.RefIDClass)
+		}
 		if err != nil {
 			_, _ = fmt.Fprint(os.Stderr, err)
+		} else if !valid {
+			discarded++
+			output.WriteToFile(p.Lines(), header.Lines(), cmd+"_discarded", discarded, p.RcvTime, now)
 		} else {
 			seqNum++
+			hosts = append(hosts, p.Host)
 			payloadStr, headerStr := p.Lines(), header.Lines()
 			output.WriteToFile(payloadStr, headerStr, cmd, seqNum, p.RcvTime, now)
 			if seqNum <= nPrintedHosts {
@@ -108,7 +149,34 @@ func printResult(dataCh <-chan *datastruct.RcvPayload, cmd string) int {
 		}
 	}
 
-	return seqNum
+	if lines := pending.Lines(); lines != "" {
+		_, _ = fmt.Fprintf(os.Stdout, "Reference IDs resolved after the scan:\n%s", lines)
+		output.WriteToFile("", lines, cmd+"_refid", 1, time.Now(), now)
+	}
+	if discarded > 0 {
+		_, _ = fmt.Fprintf(os.Stdout, "%d replies failing the sanity tests discarded\n", discarded)
+	}
+	if err := status.Close(); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+	}
+	output.WriteAmplificationToFile(datastruct.DrainAmplification(), cmd, now)
+
+	return seqNum, statuses, hosts
+}
+
+// refIDHints tells the reference ID parser the address family of the host
+// and its implementation when known.
+func refIDHints(host, implementation string) parser.RefIDHints {
+	ip := net.ParseIP(host)
+	return parser.RefIDHints{IPv6: ip != nil && ip.To4() == nil, Implementation: implementation}
+}
+
+func statusLines(statuses map[datastruct.Status]int) string {
+	buf := new(bytes.Buffer)
+	for _, s := range []datastruct.Status{datastruct.StatusOpen, datastruct.StatusKoD, datastruct.StatusClosed, datastruct.StatusFiltered} {
+		buf.WriteString(fmt.Sprintf("    %-18s %d\n", s.String()+":", statuses[s]))
+	}
+	return buf.String()
 }
 
 //TODO: 优化 WriteToFile，创建文件时先输出本次扫描相关信息
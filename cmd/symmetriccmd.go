@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var (
+	symmetricCmd = &cobra.Command{
+		Use:   "symmetric <cidr>",
+		Short: "Send symmetric active packets and flag hosts accepting unauthenticated peers",
+		Long: "Use the 'ntpdtc symmetric' command to send mode 1 (symmetric active) packets to the " +
+			"specified CIDR address and record whether each host answers in symmetric passive mode, " +
+			"ignores the packet or sends a Kiss-o'-Death.",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := executeSymmetric(cmd, args)
+			if err != nil {
+				handleError(cmd, args, err)
+			}
+		},
+	}
+)
+
+func init() {
+	symmetricCmd.Flags().IntVarP(&nGoroutines, "grnum", "g", 0,
+		"Num of goroutines. Setting it to 0 means using the value in the configuration file.")
+	symmetricCmd.Flags().IntVarP(&nPrintedHosts, "print", "p", 3,
+		"The number of hosts you want to print out the results, no more than 16.")
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"active/datastruct"
+	"active/kod"
+	"active/output"
+	"active/parser"
+	"active/udpdetect"
+	"active/utils"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"time"
+)
+
+func executeSymmetric(cmd *cobra.Command, args []string) error {
+	if nPrintedHosts > npLimit {
+		nPrintedHosts = npLimit
+	}
+	cmdName := cmd.Name()
+	if args == nil || len(args) == 0 {
+		return errors.New("command `symmetric` missing arguments")
+	}
+	address := args[0]
+	_, _ = fmt.Fprintf(os.Stdout, "Ready to run `%s`.\n    address: %s\n    "+
+		"num of printed hosts: %d\n\n", cmdName, address, nPrintedHosts)
+
+	probe := udpdetect.Probe{Requests: [][]byte{utils.FixedDataWithMode(4, 1)}, Type: datastruct.ProbeMode1}
+	var dataCh <-chan *datastruct.RcvPayload
+	startTime := time.Now()
+	if nGoroutines <= 0 {
+		dataCh = udpdetect.DialNetwork(address, probe)
+	} else {
+		dataCh = udpdetect.DialNetworkWithBatchSize(address, nGoroutines, probe)
+	}
+	if dataCh == nil {
+		return errors.New("dataCh is nil")
+	}
+
+	info := "symmetric_" + address
+	seqNum := 0
+	now := time.Now()
+	statuses := make(map[datastruct.Status]int)
+	outcomes := make(map[parser.SymmetricOutcome]int)
+	status := output.NewStatusWriter(info, now)
+	for p, ok := <-dataCh; ok; p, ok = <-dataCh {
+		if p.Err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, p.Err)
+			continue
+		}
+		statuses[p.Status]++
+		if p.Status != datastruct.StatusOpen && p.Status != datastruct.StatusKoD {
+			if p.Status == datastruct.StatusFiltered {
+				outcomes[parser.SymmetricIgnored]++
+			}
+			status.WriteString(p.Lines())
+			continue
+		}
+		res := parser.ParseSymmetric(p.RcvData)
+		outcomes[res.Outcome]++
+		seqNum++
+		payloadStr, parsedStr := p.Lines(), res.Lines()
+		if header, err := parser.ParseHeader(p.RcvData); err == nil {
+			parsedStr = header.Lines() + parsedStr
+		}
+		output.WriteToFile(payloadStr, parsedStr, info, seqNum, p.RcvTime, now)
+		if seqNum <= nPrintedHosts {
+			_, _ = fmt.Fprintf(os.Stdout, "[Host %d]\n", seqNum)
+			_, _ = fmt.Fprint(os.Stdout, payloadStr)
+			_, _ = fmt.Fprintln(os.Stdout, "[parsed]")
+			_, _ = fmt.Fprint(os.Stdout, parsedStr)
+		}
+	}
+	if err := status.Close(); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+	}
+	output.WriteAmplificationToFile(datastruct.DrainAmplification(), info, now)
+
+	_, _ = fmt.Fprintf(os.Stdout, "%d hosts answered mode 1 in %s\n%s%s", seqNum,
+		utils.DurationToStr(startTime, time.Now()), statusLines(statuses), kod.Lines())
+	for _, o := range []struct {
+		name    string
+		outcome parser.SymmetricOutcome
+	}{
+		{"symmetric passive:", parser.SymmetricPassive},
+		{"kiss-o'-death:", parser.SymmetricKoD},
+		{"other mode:", parser.SymmetricOther},
+		{"ignored:", parser.SymmetricIgnored},
+	} {
+		_, _ = fmt.Fprintf(os.Stdout, "    %-18s %d\n", o.name, outcomes[o.outcome])
+	}
+
+	return nil
+}
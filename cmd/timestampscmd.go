@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var (
+	timestampsCmd = &cobra.Command{
+		Use:   "timestamps <cidr>",
+		Short: "Check origin timestamp echo, interleaved mode and timestamp leaks of responders",
+		Long: "Use the 'ntpdtc timestamps' command to find the hosts of the specified CIDR address " +
+			"answering mode 3, then check whether each one echoes the transmit timestamp as origin, " +
+			"answers interleaved requests in interleaved mode and leaks the timestamps of previous clients.",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := executeTimestamps(cmd, args)
+			if err != nil {
+				handleError(cmd, args, err)
+			}
+		},
+	}
+)
+
+func init() {
+	timestampsCmd.Flags().IntVarP(&nGoroutines, "grnum", "g", 0,
+		"Num of goroutines. Setting it to 0 means using the value in the configuration file.")
+	timestampsCmd.Flags().IntVarP(&nPrintedHosts, "print", "p", 3,
+		"The number of hosts you want to print out the results, no more than 16.")
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"active/datastruct"
+	"active/output"
+	"active/udpdetect"
+	"active/utils"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"sync"
+	"time"
+)
+
+func executeTimestamps(cmd *cobra.Command, args []string) error {
+	if nPrintedHosts > npLimit {
+		nPrintedHosts = npLimit
+	}
+	cmdName := cmd.Name()
+	if args == nil || len(args) == 0 {
+		return errors.New("command `timestamps` missing arguments")
+	}
+	address := args[0]
+	_, _ = fmt.Fprintf(os.Stdout, "Ready to run `%s`.\n    address: %s\n    num of printed hosts: %d\n\n",
+		cmdName, address, nPrintedHosts)
+
+	startTime := time.Now()
+	payloads, err := collectOpen(address, utils.FixedData())
+	if err != nil {
+		return err
+	}
+
+	reports := make([]*datastruct.TimestampReport, len(payloads))
+	wg := new(sync.WaitGroup)
+	sem := make(chan struct{}, probeBatchSize())
+	for i, p := range payloads {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, host string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			reports[i] = udpdetect.ProbeTimestamps(host)
+		}(i, p.Host)
+	}
+	wg.Wait()
+
+	info := "timestamps_" + address
+	now := time.Now()
+	echoes := make(map[string]int)
+	modes := make(map[string]int)
+	leaks := 0
+	for i, r := range reports {
+		if r.Err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, r.Err)
+		} else {
+			echoes[r.Echo]++
+			modes[r.Interleaved]++
+		}
+		if r.Leak {
+			leaks++
+		}
+		parsed := r.Lines()
+		output.WriteToFile(payloads[i].Lines(), parsed, info, i+1, payloads[i].RcvTime, now)
+		if i < nPrintedHosts {
+			_, _ = fmt.Fprint(os.Stdout, parsed)
+		}
+	}
+	output.WriteAmplificationToFile(datastruct.DrainAmplification(), info, now)
+
+	_, _ = fmt.Fprintf(os.Stdout, "%d hosts checked in %s\n", len(reports), utils.DurationToStr(startTime, time.Now()))
+	_, _ = fmt.Fprintln(os.Stdout, "Origin timestamp of basic replies:")
+	for _, k := range []string{datastruct.OriginEcho, datastruct.OriginZero, datastruct.OriginOther} {
+		_, _ = fmt.Fprintf(os.Stdout, "    %-18s %d\n", k+":", echoes[k])
+	}
+	_, _ = fmt.Fprintln(os.Stdout, "Replies to interleaved requests:")
+	for _, k := range []string{datastruct.OriginInterleaved, datastruct.OriginBasic, datastruct.OriginOther,
+		datastruct.OriginNone} {
+		_, _ = fmt.Fprintf(os.Stdout, "    %-18s %d\n", k+":", modes[k])
+	}
+	_, _ = fmt.Fprintf(os.Stdout, "Timestamp leaks:        %d\n", leaks)
+	return nil
+}
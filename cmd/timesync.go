@@ -7,6 +7,8 @@ import (
 var (
 	nGoroutines   int
 	nPrintedHosts int
+	versionMatrix bool
+	validOnly     bool
 	timeSyncCmd   = &cobra.Command{
 		Use:   "timesync <cidr>",
 		Short: "Send time synchronization requests and parse responses",
@@ -26,4 +28,8 @@ func init() {
 		"Num of goroutines. Setting it to 0 means using the value in the configuration file.")
 	timeSyncCmd.Flags().IntVarP(&nPrintedHosts, "print", "p", 3,
 		"The number of hosts you want to print out the results, no more than 16.")
+	timeSyncCmd.Flags().BoolVar(&versionMatrix, "versions", false,
+		"Probe every responsive host again with version 1 to 4 requests and report the versions answered.")
+	timeSyncCmd.Flags().BoolVar(&validOnly, "valid-only", false,
+		"Discard the replies failing the RFC 5905 sanity tests from the output.")
 }
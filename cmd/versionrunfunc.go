@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"active/datastruct"
+	"active/output"
+	"active/parser"
+	"active/udpdetect"
+	"active/utils"
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	versionBatchSize = 64
+)
+
+// probeBatchSize is how many hosts are probed at once after a scan: the
+// number of goroutines given with -g, or versionBatchSize when it is unset.
+func probeBatchSize() int {
+	if nGoroutines <= 0 {
+		return versionBatchSize
+	}
+	return nGoroutines
+}
+
+// printVersionMatrices probes every responsive host with requests of
+// versions 1 to 4 and reports which versions each one answers.
+func printVersionMatrices(hosts []string, info string) {
+	_, _ = fmt.Fprintf(os.Stdout, "\nProbing %d hosts with versions 1 to 4.\n", len(hosts))
+	now := time.Now()
+	matrices := make([]*parser.VersionMatrix, len(hosts))
+	raws := make([]string, len(hosts))
+	wg := new(sync.WaitGroup)
+	sem := make(chan struct{}, probeBatchSize())
+	for i, host := range hosts {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, host string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			matrices[i], raws[i] = probeVersions(host)
+		}(i, host)
+	}
+	wg.Wait()
+
+	keys := make(map[string]int)
+	for i, m := range matrices {
+		keys[m.Key()]++
+		parsed := m.Lines()
+		output.WriteToFile(raws[i], parsed, info, i+1, now, now)
+		if i < nPrintedHosts {
+			_, _ = fmt.Fprint(os.Stdout, parsed)
+		}
+	}
+	output.WriteAmplificationToFile(datastruct.DrainAmplification(), info, now)
+
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return keys[sorted[i]] > keys[sorted[j]] || keys[sorted[i]] == keys[sorted[j]] && sorted[i] < sorted[j]
+	})
+	_, _ = fmt.Fprintln(os.Stdout, "Versions answered to v1 v2 v3 v4 requests:")
+	for _, k := range sorted {
+		_, _ = fmt.Fprintf(os.Stdout, "    %-18s %d\n", k+":", keys[k])
+	}
+}
+
+func probeVersions(host string) (*parser.VersionMatrix, string) {
+	replies := make(map[int][]byte)
+	raw := new(bytes.Buffer)
+	for v := 1; v <= 4; v++ {
+		p := udpdetect.DialHost(host, udpdetect.Probe{Requests: [][]byte{utils.FixedDataWithVersion(v)},
+			Type: datastruct.ProbeVersion})
+		if p.Err != nil || (p.Status != datastruct.StatusOpen && p.Status != datastruct.StatusKoD) {
+			continue
+		}
+		replies[v] = p.RcvData
+		raw.WriteString(fmt.Sprintf("[v%d request]\n", v))
+		raw.WriteString(p.Lines())
+	}
+	return parser.NewVersionMatrix(host, replies), raw.String()
+}
@@ -0,0 +1,210 @@
+package control
+
+import (
+	"active/datastruct"
+	"active/parser"
+	"active/pcap"
+	"encoding/binary"
+	"fmt"
+	"github.com/spf13/viper"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	configPath           = "../resource/"
+	timeoutKey           = "control.timeout"
+	mruFragsKey          = "control.mru.frags"
+	mruMaxEntriesKey     = "control.mru.max_entries"
+	mruMaxBytesKey       = "control.mru.max_bytes"
+	defaultTimeout       = 3000
+	defaultMRUFrags      = 16
+	defaultMRUMaxEntries = 10000
+	defaultMRUMaxBytes   = 1 << 20
+	maxDatagram          = 1024
+)
+
+var (
+	timeout       time.Duration
+	mruFrags      int
+	mruMaxEntries int
+	mruMaxBytes   int
+	sequence      uint32
+)
+
+func init() {
+	viper.AddConfigPath(configPath)
+	viper.SetConfigType("yaml")
+	viper.SetConfigName("properties")
+	viper.SetDefault(timeoutKey, defaultTimeout)
+	viper.SetDefault(mruFragsKey, defaultMRUFrags)
+	viper.SetDefault(mruMaxEntriesKey, defaultMRUMaxEntries)
+	viper.SetDefault(mruMaxBytesKey, defaultMRUMaxBytes)
+	err := viper.ReadInConfig()
+	if err != nil {
+		fmt.Printf("error reading resource file: %v", err)
+	}
+	timeout = time.Duration(viper.GetInt64(timeoutKey)) * time.Millisecond
+	if timeout == 0 {
+		timeout = defaultTimeout * time.Millisecond
+	}
+	mruFrags = viper.GetInt(mruFragsKey)
+	mruMaxEntries = viper.GetInt(mruMaxEntriesKey)
+	mruMaxBytes = viper.GetInt(mruMaxBytesKey)
+}
+
+// ReadSystemVariables sends READSTAT and READVAR for association 0 to host.
+func ReadSystemVariables(host string) (status, vars *datastruct.ControlPayload) {
+	status = Dial(host, parser.ReadStatOpcode, 0, nil)
+	vars = Dial(host, parser.ReadVarOpcode, 0, nil)
+	return status, vars
+}
+
+// Dial sends a single mode 6 request to port 123 of host and collects every
+// fragment of the response.
+func Dial(host string, opcode, assocID int, data []byte) *datastruct.ControlPayload {
+	payload := &datastruct.ControlPayload{Host: host, Port: 123, Opcode: opcode, AssocID: assocID}
+	udpAddr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(host, "123"))
+	if err != nil {
+		payload.Err = err
+		return payload
+	}
+	conn, err := net.DialUDP("udp", nil, udpAddr)
+	if err != nil {
+		payload.Err = err
+		return payload
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	exchange(conn, payload, data)
+	return payload
+}
+
+func query(conn *net.UDPConn, host string, opcode, assocID int, data []byte) *datastruct.ControlPayload {
+	payload := &datastruct.ControlPayload{Host: host, Port: 123, Opcode: opcode, AssocID: assocID}
+	exchange(conn, payload, data)
+	return payload
+}
+
+func exchange(conn *net.UDPConn, payload *datastruct.ControlPayload, data []byte) {
+	seq := int(atomic.AddUint32(&sequence, 1) & 0xFFFF)
+	req := parser.NewControlRequest(payload.Opcode, seq, payload.AssocID, data)
+	localAddr, _ := conn.LocalAddr().(*net.UDPAddr)
+	remoteAddr, _ := conn.RemoteAddr().(*net.UDPAddr)
+
+	payload.SendTime = time.Now()
+	payload.SendLen = len(req)
+	_, err := conn.Write(req)
+	if err != nil {
+		payload.Err = err
+		return
+	}
+	pcap.WriteUDP(localAddr, remoteAddr, 0, req, payload.SendTime)
+
+	err = conn.SetReadDeadline(payload.SendTime.Add(timeout))
+	if err != nil {
+		payload.Err = err
+		return
+	}
+	for {
+		buf := make([]byte, maxDatagram)
+		n, err := conn.Read(buf)
+		if err != nil {
+			if len(payload.Fragments) == 0 {
+				payload.Err = err
+			}
+			return
+		}
+		pcap.WriteUDP(remoteAddr, localAddr, 0, buf[:n], time.Now())
+		if !matches(buf[:n], payload.Opcode, seq) {
+			continue
+		}
+		payload.RcvTime = time.Now()
+		payload.Len += n
+		payload.Fragments = append(payload.Fragments, buf[:n])
+		if len(payload.Fragments) == 1 {
+			// Recorded once every fragment is in
+			defer func() {
+				datastruct.RecordAmplification(datastruct.NewControlAmplification(probeType(payload.Opcode), payload))
+			}()
+		}
+		if buf[1]&0x40 != 0 || parser.ControlComplete(payload.Fragments) {
+			return
+		}
+	}
+}
+
+// probeType is the amplification probe type of a mode 6 opcode.
+func probeType(opcode int) string {
+	switch opcode {
+	case parser.ReadStatOpcode:
+		return datastruct.ProbeReadStat
+	case parser.ReadVarOpcode:
+		return datastruct.ProbeReadVar
+	case parser.ReadMRUOpcode, parser.ReqNonceOpcode:
+		return datastruct.ProbeMRUList
+	default:
+		return datastruct.ProbeControl
+	}
+}
+
+// matches tells whether the datagram is a response to the request with the
+// given opcode and sequence number.
+func matches(data []byte, opcode, seq int) bool {
+	if len(data) < parser.ControlHeaderLength || data[0]&0x07 != 6 || data[1]&0x80 == 0 {
+		return false
+	}
+	return int(data[1]&0x1F) == opcode && int(binary.BigEndian.Uint16(data[2:4])) == seq
+}
+
+// WalkPeers lists the associations of host with READSTAT and reads the
+// variables of each one, building the graph of its upstream peers. The raw
+// payloads of every exchange are returned along with the graph.
+func WalkPeers(host string) (*parser.PeerGraph, []*datastruct.ControlPayload, error) {
+	status, vars := ReadSystemVariables(host)
+	payloads := []*datastruct.ControlPayload{status, vars}
+	res, err := Response(status)
+	if err != nil {
+		return nil, payloads, err
+	}
+	rs, err := parser.ParseReadStat(res)
+	if err != nil {
+		return nil, payloads, err
+	}
+
+	graph := &parser.PeerGraph{Host: host}
+	res, err = Response(vars)
+	if err == nil {
+		graph.System, _ = parser.ParseSystemVariables(res)
+	}
+	// An association that fails does not stop the walk, the first error is reported
+	var walkErr error
+	for _, a := range rs.Associations {
+		p := Dial(host, parser.ReadVarOpcode, a.ID, nil)
+		payloads = append(payloads, p)
+		res, err = Response(p)
+		if err == nil {
+			var pv *parser.PeerVariables
+			pv, err = parser.ParsePeerVariables(res)
+			if err == nil {
+				graph.Peers = append(graph.Peers, pv)
+				continue
+			}
+		}
+		if walkErr == nil {
+			walkErr = fmt.Errorf("association %d: %v", a.ID, err)
+		}
+	}
+	return graph, payloads, walkErr
+}
+
+// Response assembles the fragments of an exchange into the control response,
+// or returns the error the exchange failed with.
+func Response(p *datastruct.ControlPayload) (*parser.ControlResponse, error) {
+	if p.Err != nil {
+		return nil, p.Err
+	}
+	return parser.ParseControlResponse(p.Fragments)
+}
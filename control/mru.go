@@ -0,0 +1,99 @@
+package control
+
+import (
+	"active/datastruct"
+	"active/parser"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// ReadMRU retrieves the MRU list of host page by page, the way `ntpq -c
+// mrulist` does, until the server ends the list or the entry or byte budget
+// is spent. Only the aggregate statistics are kept.
+func ReadMRU(host string) (*parser.MRUStats, error) {
+	stats := parser.NewMRUStats()
+	udpAddr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(host, "123"))
+	if err != nil {
+		return stats, err
+	}
+	conn, err := net.DialUDP("udp", nil, udpAddr)
+	if err != nil {
+		return stats, err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	nonce, err := requestNonce(conn, host, stats)
+	if err != nil {
+		return stats, err
+	}
+	var last *parser.MRUEntry
+	retried := false
+	for stats.Entries < mruMaxEntries && stats.Bytes < mruMaxBytes {
+		data := fmt.Sprintf("nonce=%s, frags=%d", nonce, mruFrags)
+		if last != nil {
+			// Resume after the newest entry already received
+			data += ", addr.0=" + last.Addr
+			if last.Last != "" {
+				data += ", last.0=" + last.Last
+			}
+		}
+		p := query(conn, host, parser.ReadMRUOpcode, 0, []byte(data))
+		count(stats, p)
+		res, err := Response(p)
+		if err != nil {
+			if res != nil && res.ErrCode == parser.UnknownVariableError && !retried {
+				// The nonce has expired, ask for a new one once
+				retried = true
+				nonce, err = requestNonce(conn, host, stats)
+				if err == nil {
+					continue
+				}
+			}
+			return stats, err
+		}
+		retried = false
+		page, err := parser.ParseMRUPage(res)
+		if err != nil {
+			return stats, err
+		}
+		added := 0
+		for i := range page.Entries {
+			if stats.Add(page.Entries[i]) {
+				added++
+			}
+		}
+		if page.Nonce != "" {
+			nonce = page.Nonce
+		}
+		if page.Done {
+			stats.Complete = true
+			return stats, nil
+		}
+		if added == 0 {
+			return stats, errors.New("MRU page brought no new entry")
+		}
+		last = &page.Entries[len(page.Entries)-1]
+	}
+	return stats, nil
+}
+
+func requestNonce(conn *net.UDPConn, host string, stats *parser.MRUStats) (string, error) {
+	p := query(conn, host, parser.ReqNonceOpcode, 0, nil)
+	count(stats, p)
+	res, err := Response(p)
+	if err != nil {
+		return "", err
+	}
+	return parser.ParseNonce(res)
+}
+
+// count adds the traffic of one exchange to the statistics.
+func count(stats *parser.MRUStats, p *datastruct.ControlPayload) {
+	stats.Requests++
+	stats.SendBytes += p.SendLen
+	stats.Responses += len(p.Fragments)
+	stats.Bytes += p.Len
+}
@@ -0,0 +1,215 @@
+package datastruct
+
+import (
+	"active/utils"
+	"bufio"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Probe types recorded in amplification results
+const (
+	ProbeMode3       = "mode3"
+	ProbeMode1       = "mode1"
+	ProbeAuth        = "mode3_auth"
+	ProbeVersion     = "mode3_version"
+	ProbeMalformed   = "mode3_malformed"
+	ProbeBurst       = "mode3_burst"
+	ProbeInterleaved = "mode3_interleaved"
+	ProbeMonlist     = "mode7_monlist"
+	ProbeControl     = "mode6"
+	ProbeReadStat    = "mode6_readstat"
+	ProbeReadVar     = "mode6_readvar"
+	ProbeMRUList     = "mode6_mrulist"
+)
+
+var (
+	ampMu      sync.Mutex
+	ampRecords []*Amplification
+	ampIndex   = make(map[ampKey]*Amplification)
+)
+
+type ampKey struct {
+	ip    string
+	probe string
+}
+
+// Amplification holds what one probe type cost and brought back for one host.
+// ISP is the closest ip2region offers to an ASN, which it does not provide, so
+// the risk table groups networks by ISP name.
+type Amplification struct {
+	IP          string `json:"ip"`
+	Country     string `json:"country"`
+	ISP         string `json:"isp"`
+	Probe       string `json:"probe"`
+	SendPackets int    `json:"send_packets"`
+	SendBytes   int    `json:"send_bytes"`
+	RcvPackets  int    `json:"rcv_packets"`
+	RcvBytes    int    `json:"rcv_bytes"`
+}
+
+type riskKey struct {
+	country string
+	isp     string
+	probe   string
+}
+
+type riskRow struct {
+	hosts      int
+	reflectors int
+	maxFactor  float64
+	sumFactor  float64
+	rcvBytes   int
+}
+
+// RiskTable aggregates amplification results per country, ISP and probe type.
+type RiskTable struct {
+	rows map[riskKey]*riskRow
+}
+
+func NewAmplification(probe string, p *RcvPayload) *Amplification {
+	return &Amplification{
+		IP:          p.Host,
+		Probe:       probe,
+		SendPackets: 1,
+		SendBytes:   p.SendLen,
+		RcvPackets:  p.Packets(),
+		RcvBytes:    p.Len,
+	}
+}
+
+func NewControlAmplification(probe string, p *ControlPayload) *Amplification {
+	return &Amplification{
+		IP:          p.Host,
+		Probe:       probe,
+		SendPackets: 1,
+		SendBytes:   p.SendLen,
+		RcvPackets:  len(p.Fragments),
+		RcvBytes:    p.Len,
+	}
+}
+
+// RecordAmplification keeps the amplification of a probe the scanner sent
+// until the command drains it. Exchanges of one probe type with the same host
+// add up to a single record.
+func RecordAmplification(a *Amplification) {
+	ampMu.Lock()
+	defer ampMu.Unlock()
+	key := ampKey{ip: a.IP, probe: a.Probe}
+	if r, ok := ampIndex[key]; ok {
+		r.SendPackets += a.SendPackets
+		r.SendBytes += a.SendBytes
+		r.RcvPackets += a.RcvPackets
+		r.RcvBytes += a.RcvBytes
+		return
+	}
+	ampIndex[key] = a
+	ampRecords = append(ampRecords, a)
+}
+
+// DrainAmplification returns the amplification recorded since the last call
+// and forgets it.
+func DrainAmplification() []*Amplification {
+	ampMu.Lock()
+	defer ampMu.Unlock()
+	res := ampRecords
+	ampRecords = nil
+	ampIndex = make(map[ampKey]*Amplification)
+	return res
+}
+
+// Locate fills the country and the ISP of the host, left empty until the
+// results are written so that probing costs no lookup.
+func (a *Amplification) Locate() {
+	if a.Country == "" {
+		a.Country = utils.CountryOf(a.IP)
+	}
+	if a.ISP == "" {
+		a.ISP = utils.ISPOf(a.IP)
+	}
+}
+
+// BandwidthFactor returns the ratio of received to sent bytes.
+func (a *Amplification) BandwidthFactor() float64 {
+	if a.SendBytes == 0 {
+		return 0
+	}
+	return float64(a.RcvBytes) / float64(a.SendBytes)
+}
+
+// PacketFactor returns the ratio of received to sent packets.
+func (a *Amplification) PacketFactor() float64 {
+	if a.SendPackets == 0 {
+		return 0
+	}
+	return float64(a.RcvPackets) / float64(a.SendPackets)
+}
+
+// IsReflector tells whether the host sends back more bytes than it receives.
+func (a *Amplification) IsReflector() bool {
+	return a.BandwidthFactor() > 1
+}
+
+func (a *Amplification) WriteToCSV(writer *bufio.Writer) error {
+	_, err := writer.WriteString(fmt.Sprintf("%s,%s,%s,%s,%d,%d,%d,%d,%.3f,%.3f\n",
+		a.IP, a.Country, a.ISP, a.Probe, a.SendPackets, a.SendBytes, a.RcvPackets, a.RcvBytes,
+		a.BandwidthFactor(), a.PacketFactor()))
+	if err != nil {
+		return fmt.Errorf("error writing amplification to CSV: %v", err)
+	}
+	return nil
+}
+
+func NewRiskTable() *RiskTable {
+	return &RiskTable{rows: make(map[riskKey]*riskRow)}
+}
+
+func (t *RiskTable) Add(a *Amplification) {
+	key := riskKey{country: a.Country, isp: a.ISP, probe: a.Probe}
+	row, ok := t.rows[key]
+	if !ok {
+		row = new(riskRow)
+		t.rows[key] = row
+	}
+	factor := a.BandwidthFactor()
+	row.hosts++
+	row.sumFactor += factor
+	row.rcvBytes += a.RcvBytes
+	if a.IsReflector() {
+		row.reflectors++
+	}
+	if factor > row.maxFactor {
+		row.maxFactor = factor
+	}
+}
+
+// WriteToCSV writes one line per country, ISP and probe type: hosts,
+// reflectors, mean and max bandwidth factor and received bytes, the rows
+// with the most reflectors first.
+func (t *RiskTable) WriteToCSV(writer *bufio.Writer) error {
+	keys := make([]riskKey, 0, len(t.rows))
+	for k := range t.rows {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ri, rj := t.rows[keys[i]], t.rows[keys[j]]
+		if ri.reflectors != rj.reflectors {
+			return ri.reflectors > rj.reflectors
+		}
+		if ri.maxFactor != rj.maxFactor {
+			return ri.maxFactor > rj.maxFactor
+		}
+		return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+	})
+	for _, k := range keys {
+		row := t.rows[k]
+		_, err := writer.WriteString(fmt.Sprintf("%s,%s,%s,%d,%d,%.3f,%.3f,%d\n",
+			k.country, k.isp, k.probe, row.hosts, row.reflectors,
+			row.sumFactor/float64(row.hosts), row.maxFactor, row.rcvBytes))
+		if err != nil {
+			return fmt.Errorf("error writing risk table to CSV: %v", err)
+		}
+	}
+	return nil
+}
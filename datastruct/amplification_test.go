@@ -0,0 +1,61 @@
+package datastruct
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRiskTable(t *testing.T) {
+	var tests = []struct {
+		a         *Amplification
+		factor    float64
+		reflector bool
+	}{
+		{&Amplification{IP: "192.0.2.1", Country: "A", ISP: "X", Probe: ProbeMonlist,
+			SendPackets: 1, SendBytes: 48, RcvPackets: 100, RcvBytes: 44000}, 44000.0 / 48, true},
+		{&Amplification{IP: "192.0.2.2", Country: "A", ISP: "X", Probe: ProbeMonlist,
+			SendPackets: 1, SendBytes: 48, RcvPackets: 1, RcvBytes: 8}, 8.0 / 48, false},
+		{&Amplification{IP: "192.0.2.3", Country: "B", ISP: "Y", Probe: ProbeMode3,
+			SendPackets: 1, SendBytes: 48, RcvPackets: 1, RcvBytes: 48}, 1, false},
+	}
+	table := NewRiskTable()
+	for _, test := range tests {
+		if test.a.BandwidthFactor() != test.factor || test.a.IsReflector() != test.reflector {
+			t.Errorf("Amplification(%s) = %f, reflector %v", test.a.IP, test.a.BandwidthFactor(), test.a.IsReflector())
+		}
+		table.Add(test.a)
+	}
+	buf := new(bytes.Buffer)
+	writer := bufio.NewWriter(buf)
+	if err := table.WriteToCSV(writer); err != nil {
+		t.Fatal(err)
+	}
+	_ = writer.Flush()
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "A,X,mode7_monlist,2,1,") {
+		t.Errorf("RiskTable.WriteToCSV() = %q", buf.String())
+	}
+}
+
+func TestRecordAmplification(t *testing.T) {
+	DrainAmplification()
+	RecordAmplification(&Amplification{IP: "192.0.2.1", Probe: ProbeMRUList,
+		SendPackets: 1, SendBytes: 40, RcvPackets: 2, RcvBytes: 900})
+	RecordAmplification(&Amplification{IP: "192.0.2.1", Probe: ProbeMRUList,
+		SendPackets: 1, SendBytes: 60, RcvPackets: 3, RcvBytes: 1400})
+	RecordAmplification(&Amplification{IP: "192.0.2.1", Probe: ProbeReadVar,
+		SendPackets: 1, SendBytes: 12, RcvPackets: 1, RcvBytes: 400})
+
+	records := DrainAmplification()
+	if len(records) != 2 {
+		t.Fatalf("DrainAmplification() returned %d records, want 2", len(records))
+	}
+	if mru := records[0]; mru.SendPackets != 2 || mru.SendBytes != 100 || mru.RcvPackets != 5 || mru.RcvBytes != 2300 {
+		t.Errorf("MRU exchanges recorded as %+v", *mru)
+	}
+	if records = DrainAmplification(); len(records) != 0 {
+		t.Errorf("second DrainAmplification() returned %d records", len(records))
+	}
+}
@@ -0,0 +1,40 @@
+package datastruct
+
+import (
+	"active/utils"
+	"bytes"
+	"fmt"
+	"time"
+)
+
+type ControlPayload struct {
+	Host      string
+	Port      int
+	Opcode    int
+	AssocID   int
+	Err       error
+	SendLen   int
+	Len       int
+	SendTime  time.Time
+	RcvTime   time.Time
+	Fragments [][]byte
+}
+
+func (p *ControlPayload) Print() {
+	if p.Err != nil {
+		fmt.Println(p.Err)
+	} else {
+		fmt.Print(p.Lines())
+	}
+}
+
+func (p *ControlPayload) Lines() string {
+	buf := new(bytes.Buffer)
+	buf.WriteString(fmt.Sprintf("%d bytes in %d fragments received from %s:%d (%s):\n",
+		p.Len, len(p.Fragments), p.Host, p.Port, utils.RegionOf(p.Host)))
+	for i, f := range p.Fragments {
+		buf.WriteString(fmt.Sprintf("[fragment %d]\n", i+1))
+		buf.WriteString(utils.PrintBytes(f, 16))
+	}
+	return buf.String()
+}
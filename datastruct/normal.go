@@ -1,6 +1,7 @@
 package datastruct
 
 import (
+	"active/packet"
 	"active/utils"
 	"bytes"
 	"fmt"
@@ -8,14 +9,77 @@ import (
 	"time"
 )
 
+type Status int
+
+const (
+	StatusOpen Status = iota
+	StatusClosed
+	StatusFiltered
+	StatusKoD
+)
+
 type RcvPayload struct {
-	Host     string
-	Port     int
-	Err      error
-	Len      int
+	Host       string
+	Port       int
+	Status     Status
+	Err        error
+	Len        int
+	TTL        int
+	InitialTTL int
+	Hops       int
+	KissCode   string
+	// SendLen counts the bytes of every request sent to the host, SendData being the last one
+	SendLen  int
 	SendTime time.Time
+	SendData []byte
 	RcvTime  time.Time
 	RcvData  []byte
+	// Sanity holds the sanity tests the reply failed, set by SanityChecker
+	Sanity        SanityFlag
+	sanityChecked bool
+	// Datagrams holds every datagram of a multi-packet response, RcvData being the first one
+	Datagrams [][]byte
+}
+
+// SetTTL records the received TTL along with the inferred initial TTL and hop count.
+func (p *RcvPayload) SetTTL(ttl int) {
+	p.TTL = ttl
+	p.InitialTTL, p.Hops = utils.InferInitialTTL(ttl)
+}
+
+// CheckKoD marks the payload as a Kiss-o'-Death if its data is one.
+func (p *RcvPayload) CheckKoD() bool {
+	code, ok := utils.KissCode(p.RcvData)
+	if ok {
+		p.Status = StatusKoD
+		p.KissCode = code
+	}
+	return ok
+}
+
+// Valid tells whether the reply passed every sanity test, an unchecked
+// reply counting as valid.
+func (p *RcvPayload) Valid() bool {
+	return p.Sanity == 0
+}
+
+// Packets returns the number of datagrams received.
+func (p *RcvPayload) Packets() int {
+	if len(p.Datagrams) > 0 {
+		return len(p.Datagrams)
+	}
+	if p.RcvData != nil {
+		return 1
+	}
+	return 0
+}
+
+// Amplification returns the ratio of received to sent bytes.
+func (p *RcvPayload) Amplification() float64 {
+	if p.SendLen == 0 {
+		return 0
+	}
+	return float64(p.Len) / float64(p.SendLen)
 }
 
 func (p *RcvPayload) Print() {
@@ -26,20 +90,62 @@ func (p *RcvPayload) Print() {
 	}
 }
 
+func (s Status) String() string {
+	switch s {
+	case StatusOpen:
+		return "open"
+	case StatusClosed:
+		return "closed"
+	case StatusFiltered:
+		return "filtered/no-reply"
+	case StatusKoD:
+		return "kiss-o'-death"
+	default:
+		return "unknown"
+	}
+}
+
 func (p *RcvPayload) Lines() string {
-	s := fmt.Sprintf("%d bytes received from %s:%d (%s):\n", p.Len, p.Host, p.Port, utils.RegionOf(p.Host))
-	buf := bytes.NewBufferString(s)
-	buf.WriteString(utils.PrintBytes(p.RcvData, 16))
-	// T2 - T1
-	sendDelay := utils.CalculateDelay(p.RcvData[32:40], p.SendTime)
-	// T4 - T3
-	rcvDelay := -utils.CalculateDelay(p.RcvData[40:48], p.RcvTime)
-	avgDelay := (sendDelay + rcvDelay) / 2
-	offset := (sendDelay - rcvDelay) / 2
-	buf.WriteString(fmt.Sprintf("Send delay:    %s\n", durationToStr(sendDelay)))
-	buf.WriteString(fmt.Sprintf("Receive delay: %s\n", durationToStr(rcvDelay)))
-	buf.WriteString(fmt.Sprintf("Average delay: %s\n", durationToStr(avgDelay)))
-	buf.WriteString(fmt.Sprintf("Offset:        %s\n", durationToStr(offset)))
+	if p.Status == StatusKoD {
+		return fmt.Sprintf("%s:%d (%s): %s %s (%s)\n", p.Host, p.Port, utils.RegionOf(p.Host),
+			p.Status, p.KissCode, utils.KissCodeMeaning(p.KissCode))
+	}
+	if p.Status != StatusOpen {
+		// Most hosts of a scan never answer, a region lookup for each would cost more than the scan
+		return fmt.Sprintf("%s:%d: %s\n", p.Host, p.Port, p.Status)
+	}
+	buf := new(bytes.Buffer)
+	if p.Packets() > 1 {
+		buf.WriteString(fmt.Sprintf("%d bytes in %d packets received from %s:%d (%s):\n",
+			p.Len, p.Packets(), p.Host, p.Port, utils.RegionOf(p.Host)))
+		for i, d := range p.Datagrams {
+			buf.WriteString(fmt.Sprintf("[packet %d]\n", i+1))
+			buf.WriteString(utils.PrintBytes(d, 16))
+		}
+		buf.WriteString(fmt.Sprintf("Amplification: %.2f (%d bytes sent)\n", p.Amplification(), p.SendLen))
+	} else {
+		buf.WriteString(fmt.Sprintf("%d bytes received from %s:%d (%s):\n", p.Len, p.Host, p.Port, utils.RegionOf(p.Host)))
+		buf.WriteString(utils.PrintBytes(p.RcvData, 16))
+	}
+	// Only a server reply carries timestamps to compare
+	if pkt, _ := packet.Unmarshal(p.RcvData); pkt != nil && pkt.Mode == 4 {
+		// T2 - T1
+		sendDelay := pkt.ReceiveTime.TimeNear(p.RcvTime).Sub(p.SendTime)
+		// T4 - T3
+		rcvDelay := p.RcvTime.Sub(pkt.TransmitTime.TimeNear(p.RcvTime))
+		avgDelay := (sendDelay + rcvDelay) / 2
+		offset := (sendDelay - rcvDelay) / 2
+		buf.WriteString(fmt.Sprintf("Send delay:    %s\n", durationToStr(sendDelay)))
+		buf.WriteString(fmt.Sprintf("Receive delay: %s\n", durationToStr(rcvDelay)))
+		buf.WriteString(fmt.Sprintf("Average delay: %s\n", durationToStr(avgDelay)))
+		buf.WriteString(fmt.Sprintf("Offset:        %s\n", durationToStr(offset)))
+		if p.sanityChecked {
+			buf.WriteString(fmt.Sprintf("Sanity:        %s\n", p.Sanity))
+		}
+	}
+	if p.TTL > 0 {
+		buf.WriteString(fmt.Sprintf("TTL:           %d (initial %d, %d hops)\n", p.TTL, p.InitialTTL, p.Hops))
+	}
 	return buf.String()
 }
 
@@ -0,0 +1,110 @@
+package datastruct
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// BurstResult is what a host answered to one burst of requests.
+type BurstResult struct {
+	Size     int
+	Interval time.Duration
+	Replies  int
+	KoD      int
+	SendLen  int
+	RcvLen   int
+}
+
+// RateReport characterizes the rate limiting of one host from bursts of
+// growing size sent within the same interval.
+type RateReport struct {
+	Host   string
+	Err    error
+	Bursts []BurstResult
+}
+
+// Dropped returns the number of requests of the burst left unanswered.
+func (b BurstResult) Dropped() int {
+	return b.Size - b.Replies - b.KoD
+}
+
+// Degraded tells whether part of the burst was dropped or kissed.
+func (b BurstResult) Degraded() bool {
+	return b.Replies < b.Size
+}
+
+// Limited tells whether any burst triggered the rate limiting.
+func (r *RateReport) Limited() bool {
+	for _, b := range r.Bursts {
+		if b.Degraded() {
+			return true
+		}
+	}
+	return false
+}
+
+// BurstAllowance returns the largest burst answered in full before the first
+// degraded one.
+func (r *RateReport) BurstAllowance() int {
+	allowance := 0
+	for _, b := range r.Bursts {
+		if b.Degraded() {
+			break
+		}
+		allowance = b.Size
+	}
+	return allowance
+}
+
+// MinInterval estimates the shortest spacing between requests the host
+// accepts, the spacing of the densest burst answered in full. It is 0 when
+// no limit was reached.
+func (r *RateReport) MinInterval() time.Duration {
+	allowance := r.BurstAllowance()
+	if !r.Limited() || allowance == 0 {
+		return 0
+	}
+	for _, b := range r.Bursts {
+		if b.Size == allowance {
+			return b.Interval / time.Duration(b.Size)
+		}
+	}
+	return 0
+}
+
+// Signal returns how the host signals its limit: "RATE" if any kiss came
+// back, "drop" if requests were only dropped, empty if never limited.
+func (r *RateReport) Signal() string {
+	signal := ""
+	for _, b := range r.Bursts {
+		if b.KoD > 0 {
+			return "RATE"
+		}
+		if b.Dropped() > 0 {
+			signal = "drop"
+		}
+	}
+	return signal
+}
+
+func (r *RateReport) Lines() string {
+	buf := new(bytes.Buffer)
+	buf.WriteString(fmt.Sprintf("Rate limiting of %s:\n", r.Host))
+	if r.Err != nil {
+		buf.WriteString(fmt.Sprintf("    %v\n\n\n", r.Err))
+		return buf.String()
+	}
+	for _, b := range r.Bursts {
+		buf.WriteString(fmt.Sprintf("    burst %3d in %s: %3d replies, %3d KoD, %3d dropped\n",
+			b.Size, b.Interval, b.Replies, b.KoD, b.Dropped()))
+	}
+	if !r.Limited() {
+		buf.WriteString("Limited:         no\n\n\n")
+		return buf.String()
+	}
+	buf.WriteString(fmt.Sprintf("Limited:         yes (%s)\n", r.Signal()))
+	buf.WriteString(fmt.Sprintf("Burst Allowance: %d\n", r.BurstAllowance()))
+	buf.WriteString(fmt.Sprintf("Min Interval:    %s\n\n\n", r.MinInterval()))
+	return buf.String()
+}
@@ -0,0 +1,47 @@
+package datastruct
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateReport(t *testing.T) {
+	var tests = []struct {
+		bursts      []BurstResult
+		allowance   int
+		minInterval time.Duration
+		signal      string
+	}{
+		{
+			[]BurstResult{{Size: 1, Interval: time.Second, Replies: 1}, {Size: 2, Interval: time.Second, Replies: 2}},
+			2, 0, "",
+		},
+		{
+			[]BurstResult{
+				{Size: 1, Interval: time.Second, Replies: 1},
+				{Size: 2, Interval: time.Second, Replies: 2},
+				{Size: 4, Interval: time.Second, Replies: 2, KoD: 2},
+			},
+			2, 500 * time.Millisecond, "RATE",
+		},
+		{
+			[]BurstResult{
+				{Size: 1, Interval: time.Second, Replies: 1},
+				{Size: 2, Interval: time.Second, Replies: 1},
+			},
+			1, time.Second, "drop",
+		},
+	}
+	for _, tt := range tests {
+		r := &RateReport{Host: "192.0.2.1", Bursts: tt.bursts}
+		if got := r.BurstAllowance(); got != tt.allowance {
+			t.Errorf("BurstAllowance() = %d, want %d", got, tt.allowance)
+		}
+		if got := r.MinInterval(); got != tt.minInterval {
+			t.Errorf("MinInterval() = %s, want %s", got, tt.minInterval)
+		}
+		if got := r.Signal(); got != tt.signal {
+			t.Errorf("Signal() = %q, want %q", got, tt.signal)
+		}
+	}
+}
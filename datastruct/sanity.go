@@ -0,0 +1,156 @@
+package datastruct
+
+import (
+	"active/packet"
+	"fmt"
+	"github.com/spf13/viper"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SanityFlag is a set of RFC 5905 packet sanity tests a reply failed.
+type SanityFlag int
+
+const (
+	// FlagDuplicate marks a reply whose transmit timestamp was already seen from the host
+	FlagDuplicate SanityFlag = 1 << iota
+	// FlagBogusOrigin marks a reply whose origin timestamp is not the transmit timestamp of the request
+	FlagBogusOrigin
+	// FlagZeroTransmit marks a reply without transmit timestamp
+	FlagZeroTransmit
+	// FlagInvalidStratum marks a reply of stratum 0 or of stratum 16 and above
+	FlagInvalidStratum
+	// FlagUnsynchronized marks a reply whose leap indicator is 3
+	FlagUnsynchronized
+	// FlagRootDistance marks a reply whose root distance exceeds MAXDIST
+	FlagRootDistance
+	// FlagFutureReference marks a reply whose reference time is after its transmit time
+	FlagFutureReference
+	// FlagTimeTravel marks a reply whose timestamps give a negative delay or processing time,
+	// or whose transmit time is further from our clock than the allowed skew
+	FlagTimeTravel
+	// FlagEraJump marks a reply whose transmit timestamp only makes sense in another NTP era
+	FlagEraJump
+)
+
+const (
+	configPath     = "../resource/"
+	maxSkewKey     = "sanity.max_skew"
+	defaultMaxSkew = 3600000
+	// maxDist is MAXDIST of RFC 5905, the largest root distance a server may
+	// be selected with.
+	maxDist = time.Second
+)
+
+var (
+	// MaxSkew is how far the transmit time of a reply may lie from our clock
+	MaxSkew   time.Duration
+	flagNames = []string{
+		"duplicate", "bogus_origin", "zero_transmit", "invalid_stratum",
+		"unsynchronized", "root_distance", "future_reference", "time_travel", "era_jump",
+	}
+)
+
+func init() {
+	viper.AddConfigPath(configPath)
+	viper.SetConfigType("yaml")
+	viper.SetConfigName("properties")
+	viper.SetDefault(maxSkewKey, defaultMaxSkew)
+	err := viper.ReadInConfig()
+	if err != nil {
+		fmt.Printf("error reading resource file: %v", err)
+	}
+	MaxSkew = time.Duration(viper.GetInt64(maxSkewKey)) * time.Millisecond
+}
+
+// Names returns the names of the failed tests, in the order of the flags.
+func (f SanityFlag) Names() []string {
+	res := make([]string, 0)
+	for i, name := range flagNames {
+		if f&(1<<i) != 0 {
+			res = append(res, name)
+		}
+	}
+	return res
+}
+
+// String joins the names of the failed tests with '|', "ok" meaning none.
+func (f SanityFlag) String() string {
+	if f == 0 {
+		return "ok"
+	}
+	return strings.Join(f.Names(), "|")
+}
+
+// SanityChecker runs the sanity tests on the replies of one scan, remembering
+// the last transmit timestamp of every host to catch duplicates.
+type SanityChecker struct {
+	mu   sync.Mutex
+	last map[string]packet.Timestamp
+}
+
+func NewSanityChecker() *SanityChecker {
+	return &SanityChecker{last: make(map[string]packet.Timestamp)}
+}
+
+// Check runs the sanity tests on the server reply of the payload and records
+// the flags in it.
+func (c *SanityChecker) Check(p *RcvPayload) SanityFlag {
+	pkt, _ := packet.Unmarshal(p.RcvData)
+	if pkt == nil {
+		return 0
+	}
+	flags := CheckPacket(pkt, p.SendData, p.SendTime, p.RcvTime)
+	c.mu.Lock()
+	if last, ok := c.last[p.Host]; ok && !pkt.TransmitTime.IsZero() && last == pkt.TransmitTime {
+		flags |= FlagDuplicate
+	}
+	c.last[p.Host] = pkt.TransmitTime
+	c.mu.Unlock()
+	p.Sanity = flags
+	p.sanityChecked = true
+	return flags
+}
+
+// CheckPacket runs the tests that need no memory of earlier replies. The
+// origin test is skipped when the request is unknown.
+func CheckPacket(p *packet.Packet, request []byte, sendTime, rcvTime time.Time) SanityFlag {
+	var flags SanityFlag
+	if req, _ := packet.Unmarshal(request); req != nil && p.OriginTime != req.TransmitTime {
+		flags |= FlagBogusOrigin
+	}
+	if p.Stratum == 0 || p.Stratum >= 16 {
+		flags |= FlagInvalidStratum
+	}
+	if p.Leap == 3 {
+		flags |= FlagUnsynchronized
+	}
+	if p.RootDelay.Duration()/2+p.RootDisp.Duration() > maxDist {
+		flags |= FlagRootDistance
+	}
+	// The tests against the transmit timestamp make no sense without one
+	if p.TransmitTime.IsZero() {
+		return flags | FlagZeroTransmit
+	}
+	// Timestamps are read in the era of our clock to survive the 2036 rollover
+	pivot := rcvTime
+	if pivot.IsZero() {
+		pivot = time.Now()
+	}
+	if p.TransmitTime.EraJump(pivot) {
+		flags |= FlagEraJump
+	}
+	xmt := p.TransmitTime.TimeNear(pivot)
+	if p.RefTime.TimeNear(pivot).After(xmt) {
+		flags |= FlagFutureReference
+	}
+	processing := xmt.Sub(p.ReceiveTime.TimeNear(pivot))
+	if processing < 0 || (!sendTime.IsZero() && !rcvTime.IsZero() && rcvTime.Sub(sendTime) < processing) {
+		flags |= FlagTimeTravel
+	}
+	if skew := xmt.Sub(rcvTime); !rcvTime.IsZero() && (skew > MaxSkew || skew < -MaxSkew) {
+		flags |= FlagTimeTravel
+	}
+	return flags
+}
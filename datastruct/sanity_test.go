@@ -0,0 +1,117 @@
+package datastruct
+
+import (
+	"active/packet"
+	"testing"
+	"time"
+)
+
+func sane() *packet.Packet {
+	return &packet.Packet{
+		Version: 4, Mode: 4, Stratum: 2, Poll: 6, Precision: -20,
+		RootDelay: 0x00000800, RootDisp: 0x00000400, RefID: 0xC0000201,
+		RefTime: 0xE8000000_00000000, OriginTime: 0xFFFFFFFF_FFFFFF00,
+		ReceiveTime: 0xE8000010_00000000, TransmitTime: 0xE8000010_00100000,
+	}
+}
+
+func TestCheckPacket(t *testing.T) {
+	request := (&packet.Packet{Version: 3, Mode: 3, TransmitTime: 0xFFFFFFFF_FFFFFF00}).Marshal()
+	sendTime := time.Date(2023, 5, 5, 22, 22, 0, 0, time.UTC)
+	rcvTime := sendTime.Add(30 * time.Millisecond)
+
+	var tests = []struct {
+		name   string
+		modify func(p *packet.Packet)
+		flags  SanityFlag
+	}{
+		{"sane", func(p *packet.Packet) {}, 0},
+		{"bogus origin", func(p *packet.Packet) { p.OriginTime = 0 }, FlagBogusOrigin},
+		{"zero transmit", func(p *packet.Packet) { p.TransmitTime = 0 }, FlagZeroTransmit},
+		{"stratum 0", func(p *packet.Packet) { p.Stratum = 0; p.RefID = 0 }, FlagInvalidStratum},
+		{"stratum 16", func(p *packet.Packet) { p.Stratum = 16 }, FlagInvalidStratum},
+		{"unsynchronized", func(p *packet.Packet) { p.Leap = 3 }, FlagUnsynchronized},
+		{"root dispersion", func(p *packet.Packet) { p.RootDisp = 0x00010001 }, FlagRootDistance},
+		{"root delay", func(p *packet.Packet) { p.RootDelay = 0x00020002 }, FlagRootDistance},
+		{"future reference", func(p *packet.Packet) { p.RefTime = 0xE8000011_00000000 }, FlagFutureReference},
+		{"transmit before receive", func(p *packet.Packet) { p.ReceiveTime = 0xE8000010_00200000 }, FlagTimeTravel},
+		{"processing longer than round trip", func(p *packet.Packet) { p.TransmitTime = 0xE8000011_00000000 }, FlagTimeTravel},
+		{"clock years behind", func(p *packet.Packet) {
+			p.RefTime, p.ReceiveTime, p.TransmitTime = 0xBA000000_00000000, 0xBA000010_00000000, 0xBA000010_00100000
+		}, FlagTimeTravel},
+		{"clock hours ahead", func(p *packet.Packet) {
+			p.ReceiveTime, p.TransmitTime = 0xE8001C30_00000000, 0xE8001C30_00100000
+		}, FlagTimeTravel},
+	}
+	for _, tt := range tests {
+		p := sane()
+		tt.modify(p)
+		if got := CheckPacket(p, request, sendTime, rcvTime); got != tt.flags {
+			t.Errorf("%s: CheckPacket() = %s, want %s", tt.name, got, tt.flags)
+		}
+	}
+
+	// Without the request the origin cannot be checked
+	p := sane()
+	p.OriginTime = 0
+	if got := CheckPacket(p, nil, time.Time{}, time.Time{}); got != 0 {
+		t.Errorf("unknown request: CheckPacket() = %s, want ok", got)
+	}
+}
+
+func TestSanityChecker(t *testing.T) {
+	data := sane().Marshal()
+	c := NewSanityChecker()
+	first := &RcvPayload{Host: "192.0.2.1", RcvData: data}
+	second := &RcvPayload{Host: "192.0.2.1", RcvData: data}
+	other := &RcvPayload{Host: "192.0.2.2", RcvData: data}
+
+	if got := c.Check(first); got != 0 || !first.Valid() {
+		t.Errorf("first reply: %s", got)
+	}
+	if got := c.Check(second); got != FlagDuplicate || second.Valid() {
+		t.Errorf("second reply: %s, want duplicate", got)
+	}
+	if got := c.Check(other); got != 0 {
+		t.Errorf("reply of another host: %s", got)
+	}
+}
+
+func TestSanityFlagString(t *testing.T) {
+	var tests = []struct {
+		flags SanityFlag
+		str   string
+	}{
+		{0, "ok"},
+		{FlagDuplicate, "duplicate"},
+		{FlagZeroTransmit | FlagTimeTravel, "zero_transmit|time_travel"},
+	}
+	for _, tt := range tests {
+		if got := tt.flags.String(); got != tt.str {
+			t.Errorf("SanityFlag(%d).String() = %q, want %q", int(tt.flags), got, tt.str)
+		}
+	}
+}
+
+func TestCheckPacketRollover(t *testing.T) {
+	rollover := time.Date(2036, 2, 7, 6, 28, 16, 0, time.UTC)
+
+	// Received just before the rollover and sent just after it
+	straddling := sane()
+	straddling.RefTime = 0xFFFFFF00_00000000
+	straddling.ReceiveTime = 0xFFFFFFFF_F0000000
+	straddling.TransmitTime = 0x00000000_10000000
+	if got := CheckPacket(straddling, nil, rollover.Add(-time.Second), rollover.Add(time.Second)); got != 0 {
+		t.Errorf("reply across the rollover: CheckPacket() = %s, want ok", got)
+	}
+
+	// A clock wrapped to the start of an era is read in the next one
+	wrapped := sane()
+	wrapped.RefTime = 0x00000001_00000000
+	wrapped.ReceiveTime = 0x00000002_00000000
+	wrapped.TransmitTime = 0x00000002_00100000
+	sendTime := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	if got := CheckPacket(wrapped, nil, sendTime, sendTime.Add(30*time.Millisecond)); got != FlagTimeTravel|FlagEraJump {
+		t.Errorf("wrapped clock: CheckPacket() = %s, want time_travel|era_jump", got)
+	}
+}
@@ -1,70 +1,129 @@
 package datastruct
 
 import (
+	"active/packet"
 	"active/utils"
 	"bufio"
-	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"io"
+)
+
+const (
+	// sanityCol is the column of the statistic CSV holding the sanity tests
+	// the reply failed.
+	sanityCol = 15
+	// statusCol is the column holding whether the host was open, closed or
+	// filtered.
+	statusCol = 16
 )
 
 type Statistic struct {
-	Domain         string
-	IP             string
-	Country        string
-	Stratum        int
-	Poll           int
-	Precision      int
-	Delay          int
-	Offset         int
-	ProcessingTime int
-	RefCountry     string
-	RootDelay      int
-	RootDisp       int
+	Domain         string `json:"domain"`
+	IP             string `json:"ip"`
+	Country        string `json:"country"`
+	Stratum        int    `json:"stratum"`
+	Poll           int    `json:"poll"`
+	Precision      int    `json:"precision"`
+	Delay          int    `json:"delay"`
+	Offset         int    `json:"offset"`
+	ProcessingTime int    `json:"processing_time"`
+	RefCountry     string `json:"ref_country"`
+	RootDelay      int    `json:"root_delay"`
+	RootDisp       int    `json:"root_disp"`
+	TTL            int    `json:"ttl"`
+	InitialTTL     int    `json:"initial_ttl"`
+	Hops           int    `json:"hops"`
+	Sanity         string `json:"sanity,omitempty"`
+	Status         string `json:"status"`
 }
 
-func NewStatistic(p *RcvPayload) *Statistic {
+// NewStatistic summarizes the payload and pkt, its reply as decoded by the
+// parser, nil when the host did not answer. The reference ID kind comes from
+// the parser too, which knows the IPv6 servers whose hashes it may be: only
+// an address has a country, a stratum 1 source is written as is.
+func NewStatistic(p *RcvPayload, pkt *packet.Packet, refID packet.RefIDKind) *Statistic {
 	res := new(Statistic)
 
 	res.IP = p.Host
+	res.Status = p.Status.String()
+	// Hosts that did not answer have nothing more to tell, nor are they worth a region lookup
+	if p.Status != StatusOpen && p.Status != StatusKoD {
+		return res
+	}
 	res.Country = utils.CountryOf(p.Host)
+	res.TTL = p.TTL
+	res.InitialTTL = p.InitialTTL
+	res.Hops = p.Hops
+	if p.sanityChecked {
+		res.Sanity = p.Sanity.String()
+	}
 
-	data := p.RcvData
-	stratum := data[1]
+	if pkt == nil {
+		return res
+	}
 
-	res.Stratum = int(stratum)
-	res.Poll = int(int8(data[2]))
-	res.Precision = int(int8(data[3]))
-	res.RootDelay = int(binary.BigEndian.Uint32(data[4:8]))
-	res.RootDisp = int(binary.BigEndian.Uint32(data[8:12]))
+	res.Stratum = int(pkt.Stratum)
+	res.Poll = int(pkt.Poll)
+	res.Precision = int(pkt.Precision)
+	res.RootDelay = int(pkt.RootDelay)
+	res.RootDisp = int(pkt.RootDisp)
 
-	sendDelay := utils.CalculateDelay(data[32:40], p.SendTime)
-	rcvDelay := -utils.CalculateDelay(data[40:48], p.RcvTime)
+	sendDelay := pkt.ReceiveTime.TimeNear(p.RcvTime).Sub(p.SendTime)
+	rcvDelay := p.RcvTime.Sub(pkt.TransmitTime.TimeNear(p.RcvTime))
 	avgDelay := (sendDelay + rcvDelay) / 2
 	offset := (sendDelay - rcvDelay) / 2
 	res.Delay = int(avgDelay.Microseconds())
 	res.Offset = int(offset.Microseconds())
-	res.ProcessingTime = int(binary.BigEndian.Uint64(data[40:48]) - binary.BigEndian.Uint64(data[32:40]))
+	res.ProcessingTime = int(pkt.TransmitTime - pkt.ReceiveTime)
 
-	if stratum == 1 {
-		if data[15] == 0x00 {
-			res.RefCountry = string(data[12:15])
-		} else {
-			res.RefCountry = string(data[12:16])
-		}
-	} else {
-		ipStr := fmt.Sprintf("%d.%d.%d.%d", data[12], data[13], data[14], data[15])
-		res.RefCountry = utils.CountryOf(ipStr)
+	switch refID {
+	case packet.RefIDSource:
+		res.RefCountry = pkt.RefID.ASCII()
+	case packet.RefIDAddress:
+		res.RefCountry = utils.CountryOf(pkt.RefID.IP().String())
 	}
 
 	return res
 }
 
 func (s *Statistic) WriteToCSV(writer *bufio.Writer) error {
-	_, err := writer.WriteString(fmt.Sprintf("%s,%s,%s,%d,%d,%d,%d,%d,%d,%s,%d,%d\n",
+	_, err := writer.WriteString(fmt.Sprintf("%s,%s,%s,%d,%d,%d,%d,%d,%d,%s,%d,%d,%d,%d,%d,%s,%s\n",
 		s.Domain, s.IP, s.Country, s.Stratum, s.Poll, s.Precision, s.Delay, s.Offset,
-		s.ProcessingTime, s.RefCountry, s.RootDelay, s.RootDisp))
+		s.ProcessingTime, s.RefCountry, s.RootDelay, s.RootDisp, s.TTL, s.InitialTTL, s.Hops, s.Sanity, s.Status))
 	if err != nil {
 		return fmt.Errorf("error writing statistic to CSV: %v", err)
 	}
 	return nil
 }
+
+func (s *Statistic) WriteToJSON(writer *bufio.Writer) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("error marshalling statistic: %v", err)
+	}
+	_, err = writer.Write(append(data, '\n'))
+	if err != nil {
+		return fmt.Errorf("error writing statistic to JSON: %v", err)
+	}
+	return nil
+}
+
+// NewStatisticReader reads a statistic CSV, whose rows written before the
+// sanity and status columns have fewer fields.
+func NewStatisticReader(r io.Reader) *csv.Reader {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	return reader
+}
+
+// ValidStatisticRow tells whether a statistic row is an answer that passed the
+// sanity tests. Rows written before the sanity or status columns existed, or
+// never checked, count as valid.
+func ValidStatisticRow(row []string) bool {
+	if len(row) > statusCol && row[statusCol] != "" && row[statusCol] != StatusOpen.String() {
+		return false
+	}
+	return len(row) <= sanityCol || row[sanityCol] == "" || row[sanityCol] == "ok"
+}
@@ -0,0 +1,58 @@
+package datastruct
+
+import (
+	"active/packet"
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestStatisticStatus(t *testing.T) {
+	var tests = []struct {
+		status Status
+		line   string
+	}{
+		{StatusClosed, ",192.0.2.1,,0,0,0,0,0,0,,0,0,0,0,0,,closed\n"},
+		{StatusFiltered, ",192.0.2.1,,0,0,0,0,0,0,,0,0,0,0,0,,filtered/no-reply\n"},
+	}
+	for _, tt := range tests {
+		p := &RcvPayload{Host: "192.0.2.1", Port: 123, Status: tt.status}
+		buf := new(bytes.Buffer)
+		writer := bufio.NewWriter(buf)
+		if err := NewStatistic(p, nil, packet.RefIDZero).WriteToCSV(writer); err != nil {
+			t.Fatal(err)
+		}
+		_ = writer.Flush()
+		if buf.String() != tt.line {
+			t.Errorf("%s: WriteToCSV() = %q, want %q", tt.status, buf.String(), tt.line)
+		}
+		if line := p.Lines(); line != "192.0.2.1:123: "+tt.status.String()+"\n" {
+			t.Errorf("%s: Lines() = %q", tt.status, line)
+		}
+	}
+}
+
+func TestValidStatisticRow(t *testing.T) {
+	data := "d,192.0.2.1,A,2,6,-20,100,5,10,B,8,4,50,64,14\n" +
+		"d,192.0.2.2,A,2,6,-20,100,5,10,B,8,4,50,64,14,ok\n" +
+		"d,192.0.2.3,A,2,6,-20,100,5,10,B,8,4,50,64,14,duplicate\n" +
+		"d,192.0.2.4,A,2,6,-20,100,5,10,B,8,4,50,64,14,ok,open\n" +
+		"d,192.0.2.5,A,2,6,-20,100,5,10,B,8,4,50,64,14,,\n" +
+		"d,192.0.2.6,,0,0,0,0,0,0,,0,0,0,0,0,,filtered/no-reply\n" +
+		"d,192.0.2.7,A,0,6,-20,0,0,0,,0,0,50,64,14,invalid_stratum,kiss-o'-death\n"
+	want := []bool{true, true, false, true, true, false, false}
+
+	rows, err := NewStatisticReader(strings.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("read %d rows, want %d", len(rows), len(want))
+	}
+	for i, row := range rows {
+		if got := ValidStatisticRow(row); got != want[i] {
+			t.Errorf("%s: ValidStatisticRow() = %v, want %v", row[1], got, want[i])
+		}
+	}
+}
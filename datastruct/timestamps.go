@@ -0,0 +1,59 @@
+package datastruct
+
+import (
+	"bytes"
+	"fmt"
+)
+
+const (
+	OriginEcho  = "echo"
+	OriginZero  = "zero"
+	OriginOther = "other"
+	OriginNone  = "no reply"
+	// OriginInterleaved means the origin holds the receive timestamp of the
+	// request, the answer of a server in interleaved mode, and OriginBasic
+	// that it holds the transmit timestamp of an interleaved request
+	OriginInterleaved = "interleaved"
+	OriginBasic       = "basic"
+)
+
+// TimestampReport records how a host handles the timestamps of requests:
+// whether it echoes the transmit timestamp as origin, whether it answers
+// interleaved requests in interleaved mode, and whether a reply to one
+// client carries a timestamp sent by another.
+type TimestampReport struct {
+	Host        string
+	Err         error
+	Echo        string
+	Interleaved string
+	Leak        bool
+	LeakedField string
+	KissCode    string
+}
+
+// SupportsInterleaved tells whether the host answered the interleaved
+// request in interleaved mode.
+func (r *TimestampReport) SupportsInterleaved() bool {
+	return r.Interleaved == OriginInterleaved
+}
+
+func (r *TimestampReport) Lines() string {
+	buf := new(bytes.Buffer)
+	buf.WriteString(fmt.Sprintf("Timestamp handling of %s:\n", r.Host))
+	if r.Err != nil {
+		buf.WriteString(fmt.Sprintf("    %v\n\n\n", r.Err))
+		return buf.String()
+	}
+	buf.WriteString(fmt.Sprintf("Origin Echo:     %s\n", r.Echo))
+	buf.WriteString(fmt.Sprintf("Interleaved:     %s\n", r.Interleaved))
+	if r.Leak {
+		buf.WriteString(fmt.Sprintf("Timestamp Leak:  yes (%s of another client)\n", r.LeakedField))
+	} else {
+		buf.WriteString("Timestamp Leak:  no\n")
+	}
+	if r.KissCode != "" {
+		buf.WriteString(fmt.Sprintf("Kiss Code:       %s\n", r.KissCode))
+	}
+	buf.WriteString("\n\n")
+	return buf.String()
+}
@@ -4,6 +4,7 @@ import (
 	"active/datastruct"
 	"active/nts"
 	"active/output"
+	"active/packet"
 	"active/parser"
 	"active/tcp"
 	"active/udpdetect"
@@ -13,6 +14,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -20,6 +22,8 @@ import (
 
 var (
 	numDetected int
+	jsonOutput  bool
+	sanity      = datastruct.NewSanityChecker()
 )
 
 type extraWork func(string, string) error
@@ -35,7 +39,7 @@ func OutputDNS(src, dst string) error {
 func DetectAfterDNS(src, dst string) error {
 	visited := make(map[string]struct{})
 	detectWork := func(domain, ip string) error {
-		netAddr := net24(ip)
+		netAddr := utils.NetworkOf(ip)
 		if _, ok := visited[netAddr]; ok {
 			return nil
 		}
@@ -49,7 +53,7 @@ func AsyncDetectAfterDNS(src, dst string) error {
 	visited := make(map[string]struct{})
 	var mu sync.RWMutex
 	asyncDetectWork := func(domain, ip string) error {
-		netAddr := net24(ip)
+		netAddr := utils.NetworkOf(ip)
 		mu.RLock()
 		_, ok := visited[netAddr]
 		mu.RUnlock()
@@ -76,6 +80,7 @@ func DetectStatisticAfterNTS(src, ipDst, staDst string) error {
 		return fmt.Errorf("error creating dstFile %s: %v", staDst, err)
 	}
 	defer closeFunc(file, staDst)
+	jsonOutput = strings.HasSuffix(staDst, ".json")
 	writer := bufio.NewWriter(file)
 	defer func(writer *bufio.Writer) {
 		err := writer.Flush()
@@ -85,7 +90,7 @@ func DetectStatisticAfterNTS(src, ipDst, staDst string) error {
 	}(writer)
 
 	detectWork := func(domain, ip string) error {
-		netAddr := net24(ip)
+		netAddr := utils.NetworkOf(ip)
 		if _, ok := visited[netAddr]; ok {
 			return nil
 		}
@@ -93,9 +98,13 @@ func DetectStatisticAfterNTS(src, ipDst, staDst string) error {
 		return detect(domain, ip, writer)
 	}
 
+	datastruct.DrainAmplification()
 	err = commonDNS(src, ipDst, detectWork)
 	fmt.Printf("%d networks detected\n", len(visited))
-	return err
+	if err != nil {
+		return err
+	}
+	return writeAmplification(staDst)
 }
 
 func AsyncDetectStatisticAfterDNS(src, ipDst, staDst string) error {
@@ -109,6 +118,7 @@ func AsyncDetectStatisticAfterDNS(src, ipDst, staDst string) error {
 		return fmt.Errorf("error creating dstFile %s: %v", staDst, err)
 	}
 	defer closeFunc(file, staDst)
+	jsonOutput = strings.HasSuffix(staDst, ".json")
 	writer := bufio.NewWriter(file)
 	defer func(writer *bufio.Writer) {
 		err := writer.Flush()
@@ -118,7 +128,7 @@ func AsyncDetectStatisticAfterDNS(src, ipDst, staDst string) error {
 	}(writer)
 	var mu sync.RWMutex
 	asyncDetectWork := func(domain, ip string) error {
-		netAddr := net24(ip)
+		netAddr := utils.NetworkOf(ip)
 		mu.RLock()
 		_, ok := visited[netAddr]
 		mu.RUnlock()
@@ -131,9 +141,13 @@ func AsyncDetectStatisticAfterDNS(src, ipDst, staDst string) error {
 
 		return asyncDetect(domain, ip, writer)
 	}
+	datastruct.DrainAmplification()
 	err = commonDNS(src, ipDst, asyncDetectWork)
 	fmt.Printf("%d networks detected\n", len(visited))
-	return err
+	if err != nil {
+		return err
+	}
+	return writeAmplification(staDst)
 }
 
 func TLSAfterDNS(src, dst string) error {
@@ -269,20 +283,25 @@ func detect(domain, ip string, writer *bufio.Writer) error {
 	for p, ok := <-dataCh; ok; p, ok = <-dataCh {
 		err := p.Err
 		if err != nil {
-			return err
+			// A host denied by a kiss code or unreachable does not end the scan of its network
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			continue
+		}
+		if p.Status != datastruct.StatusOpen {
+			// Closed and filtered hosts count in the exposure statistics too
+			if err = writeStatistic(writer, domain, datastruct.NewStatistic(p, nil, packet.RefIDZero)); err != nil {
+				return err
+			}
+			continue
 		}
 		header, err := parser.ParseHeader(p.RcvData)
 		if err != nil {
 			return err
 		}
 		seqNum++
-		if writer != nil {
-			s := datastruct.NewStatistic(p)
-			s.Domain = domain
-			err = s.WriteToCSV(writer)
-			if err != nil {
-				return err
-			}
+		sanity.Check(p)
+		if err = writeStatistic(writer, domain, datastruct.NewStatistic(p, header.Packet, header.RefIDClass.Kind)); err != nil {
+			return err
 		}
 		output.WriteToFile(p.Lines(), header.Lines(), domain+"_"+cidr, seqNum, p.RcvTime, now)
 	}
@@ -290,6 +309,17 @@ func detect(domain, ip string, writer *bufio.Writer) error {
 	return nil
 }
 
+func writeStatistic(writer *bufio.Writer, domain string, s *datastruct.Statistic) error {
+	if writer == nil {
+		return nil
+	}
+	s.Domain = domain
+	if jsonOutput {
+		return s.WriteToJSON(writer)
+	}
+	return s.WriteToCSV(writer)
+}
+
 func checkTLS(domain, ip string) error {
 	result := "x"
 	if tcp.IsTLSEnabled(ip, 4460, "") {
@@ -314,14 +344,16 @@ func detectAEAD(_, ip string) error {
 	return nil
 }
 
+// writeAmplification writes the amplification results and risk table of the
+// detection next to its statistic file.
+func writeAmplification(staDst string) error {
+	base := strings.TrimSuffix(staDst, filepath.Ext(staDst))
+	return output.WriteAmplification(datastruct.DrainAmplification(), base+"_amplification.csv", base+"_risk.csv")
+}
+
 func closeFunc(f *os.File, path string) {
 	err := f.Close()
 	if err != nil {
 		fmt.Printf("error closing file %s: %v", path, err)
 	}
 }
-
-func net24(ip string) string {
-	nums := strings.Split(ip, ".")
-	return fmt.Sprintf("%s.%s.%s", nums[0], nums[1], nums[2])
-}
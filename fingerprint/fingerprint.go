@@ -0,0 +1,217 @@
+package fingerprint
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/spf13/viper"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	configPath           = "../resource/"
+	rulesKey             = "fingerprint.rules"
+	minConfidenceKey     = "fingerprint.min_confidence"
+	defaultMinConfidence = 0.4
+	unknownName          = "unknown"
+)
+
+var (
+	//go:embed rules.json
+	defaultRules  []byte
+	minConfidence float64
+)
+
+func init() {
+	viper.AddConfigPath(configPath)
+	viper.SetConfigType("yaml")
+	viper.SetConfigName("properties")
+	viper.SetDefault(minConfidenceKey, defaultMinConfidence)
+	err := viper.ReadInConfig()
+	if err != nil {
+		fmt.Printf("error reading resource file: %v", err)
+	}
+	minConfidence = viper.GetFloat64(minConfidenceKey)
+}
+
+// Condition tests one feature, either against a list of values, a
+// substring or a numeric range. A required condition that fails rules the
+// implementation out, and one left unobserved keeps it from being picked,
+// while a missing feature otherwise only leaves its weight unmet. A positive
+// condition only ever speaks for the implementation: when it fails or is not
+// observed, its weight is left out of the total.
+type Condition struct {
+	Feature  string   `json:"feature"`
+	Equals   []string `json:"equals,omitempty"`
+	Contains string   `json:"contains,omitempty"`
+	Min      *float64 `json:"min,omitempty"`
+	Max      *float64 `json:"max,omitempty"`
+	Weight   float64  `json:"weight"`
+	Required bool     `json:"required,omitempty"`
+	Positive bool     `json:"positive,omitempty"`
+}
+
+type Implementation struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Rules       []Condition `json:"rules"`
+}
+
+type RuleSet struct {
+	Implementations []Implementation `json:"implementations"`
+}
+
+// Match is how well an observation fits one implementation. The confidence
+// is the weight of the conditions met over the weight of all conditions, so
+// features left unprobed lower it. Eligible is set when every required
+// condition was observed and holds.
+type Match struct {
+	Name       string
+	Confidence float64
+	Eligible   bool
+	Evidence   []string
+	met        float64
+}
+
+type Result struct {
+	Host       string
+	Best       Match
+	Candidates []Match
+	Features   *Observation
+}
+
+// LoadRules reads the rules from path, from the configured file when path
+// is empty, and falls back to the embedded rules when neither is set.
+func LoadRules(path string) (*RuleSet, error) {
+	if path == "" {
+		path = viper.GetString(rulesKey)
+	}
+	if path == "" {
+		return ParseRules(defaultRules)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading fingerprint rules %s: %v", path, err)
+	}
+	return ParseRules(data)
+}
+
+func ParseRules(data []byte) (*RuleSet, error) {
+	rs := new(RuleSet)
+	if err := json.Unmarshal(data, rs); err != nil {
+		return nil, fmt.Errorf("error parsing fingerprint rules: %v", err)
+	}
+	if len(rs.Implementations) == 0 {
+		return nil, errors.New("no implementation in fingerprint rules")
+	}
+	for _, impl := range rs.Implementations {
+		if impl.Name == "" || len(impl.Rules) == 0 {
+			return nil, fmt.Errorf("implementation %q has no name or no rules", impl.Name)
+		}
+		for _, c := range impl.Rules {
+			if c.Feature == "" || c.Weight <= 0 {
+				return nil, fmt.Errorf("%s: rule on %q needs a feature and a positive weight", impl.Name, c.Feature)
+			}
+			if len(c.Equals) == 0 && c.Contains == "" && c.Min == nil && c.Max == nil {
+				return nil, fmt.Errorf("%s: rule on %s tests nothing", impl.Name, c.Feature)
+			}
+		}
+	}
+	return rs, nil
+}
+
+// Classify scores the observation against every implementation and picks
+// the best eligible one, or unknown when none reaches the minimum confidence.
+// Of two equally confident implementations, the one with more evidence wins.
+func (rs *RuleSet) Classify(o *Observation) *Result {
+	res := &Result{Host: o.Host, Features: o}
+	for _, impl := range rs.Implementations {
+		res.Candidates = append(res.Candidates, impl.match(o))
+	}
+	sort.SliceStable(res.Candidates, func(i, j int) bool {
+		a, b := res.Candidates[i], res.Candidates[j]
+		return a.Confidence > b.Confidence || a.Confidence == b.Confidence && a.met > b.met
+	})
+	res.Best = Match{Name: unknownName}
+	for _, m := range res.Candidates {
+		if m.Eligible && m.Confidence >= minConfidence {
+			res.Best = m
+			break
+		}
+	}
+	return res
+}
+
+func (impl Implementation) match(o *Observation) Match {
+	m := Match{Name: impl.Name, Eligible: true}
+	total, met := 0.0, 0.0
+	for _, c := range impl.Rules {
+		value, ok := o.Get(c.Feature)
+		holds := ok && c.holds(value)
+		if !c.Positive || holds {
+			total += c.Weight
+		}
+		switch {
+		case holds:
+			met += c.Weight
+			m.Evidence = append(m.Evidence, c.Feature+"="+value)
+		case !ok:
+			m.Eligible = m.Eligible && !c.Required
+		case c.Required:
+			return Match{Name: impl.Name}
+		}
+	}
+	if total > 0 {
+		m.Confidence = met / total
+	}
+	m.met = met
+	return m
+}
+
+func (c Condition) holds(value string) bool {
+	if len(c.Equals) > 0 {
+		found := false
+		for _, v := range c.Equals {
+			found = found || strings.EqualFold(v, value)
+		}
+		if !found {
+			return false
+		}
+	}
+	if c.Contains != "" && !strings.Contains(strings.ToLower(value), strings.ToLower(c.Contains)) {
+		return false
+	}
+	if c.Min != nil || c.Max != nil {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || c.Min != nil && f < *c.Min || c.Max != nil && f > *c.Max {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Result) Lines() string {
+	buf := new(bytes.Buffer)
+	buf.WriteString(fmt.Sprintf("Implementation:  %s (confidence %.2f)\n", r.Best.Name, r.Best.Confidence))
+	if len(r.Best.Evidence) > 0 {
+		buf.WriteString(fmt.Sprintf("Evidence:        %s\n", strings.Join(r.Best.Evidence, ", ")))
+	}
+	others := make([]string, 0)
+	for _, m := range r.Candidates {
+		if m.Name != r.Best.Name && m.Confidence > 0 {
+			others = append(others, fmt.Sprintf("%s %.2f", m.Name, m.Confidence))
+		}
+	}
+	if len(others) > 0 {
+		buf.WriteString(fmt.Sprintf("Candidates:      %s\n", strings.Join(others, ", ")))
+	}
+	buf.WriteString("Features:\n")
+	buf.WriteString(r.Features.Lines())
+	buf.WriteString("\n\n")
+	return buf.String()
+}
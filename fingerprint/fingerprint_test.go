@@ -0,0 +1,107 @@
+package fingerprint
+
+import (
+	"active/parser"
+	"active/utils"
+	"encoding/binary"
+	"testing"
+)
+
+// reply builds a mode 4 answer to the fixed request.
+func reply(version, stratum, poll byte, precision int8, rootDisp uint32, refID string, sameRcvXmt bool) []byte {
+	request := utils.FixedData()
+	data := make([]byte, parser.HeaderLength)
+	data[0] = version<<3 | 4
+	data[1], data[2], data[3] = stratum, poll, byte(precision)
+	binary.BigEndian.PutUint32(data[8:12], rootDisp)
+	copy(data[12:16], refID)
+	binary.BigEndian.PutUint64(data[16:24], 0xE8000000_00000000)
+	copy(data[24:32], request[40:48])
+	binary.BigEndian.PutUint64(data[32:40], 0xE8000001_12345678)
+	if sameRcvXmt {
+		copy(data[40:48], data[32:40])
+	} else {
+		binary.BigEndian.PutUint64(data[40:48], 0xE8000001_12349ABC)
+	}
+	return data
+}
+
+func TestClassify(t *testing.T) {
+	rules, err := ParseRules(defaultRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		name  string
+		reply []byte
+		extra func(o *Observation)
+		want  string
+	}{
+		{"chrony", reply(3, 2, 4, -25, 0x100, "\x0a\x00\x00\x01", false), func(o *Observation) {
+			o.AddSystemVariables(nil)
+			o.Set("version_policy", "echo")
+			o.Set("interleaved", "interleaved")
+		}, "chrony"},
+		{"ntpd", reply(3, 2, 4, -23, 0x100, "\x0a\x00\x00\x01", false), func(o *Observation) {
+			o.AddSystemVariables(&parser.SystemVariables{Version: "ntpd 4.2.8p15@1.3728-o"})
+		}, "ntpd"},
+		{"ntpd with noquery", reply(3, 2, 4, -23, 0x100, "\x0a\x00\x00\x01", false), func(o *Observation) {
+			o.AddSystemVariables(nil)
+			o.Set("version_policy", "echo")
+			o.Set("interleaved", "basic")
+		}, "ntpd"},
+		{"ntpd without extra probes", reply(3, 2, 4, -23, 0x100, "\x0a\x00\x00\x01", false), nil, "ntpd"},
+		{"NTPsec", reply(3, 2, 4, -23, 0x100, "\x0a\x00\x00\x01", false), func(o *Observation) {
+			o.AddSystemVariables(&parser.SystemVariables{Version: "ntpsec-1.2.2"})
+		}, "NTPsec"},
+		{"OpenNTPD", reply(3, 1, 4, -20, 0x100, "\x0a\x00\x00\x01", false), func(o *Observation) {
+			o.AddSystemVariables(nil)
+			o.Set("version_policy", "echo")
+			o.Set("interleaved", "basic")
+		}, "OpenNTPD"},
+		{"w32time", reply(3, 1, 10, -23, 10<<16, "LOCL", false), func(o *Observation) {
+			o.AddSystemVariables(nil)
+			o.Set("version_policy", "fixed")
+		}, "Windows w32time"},
+		{"busybox", reply(3, 2, 5, -9, 0x100, "\x0a\x00\x00\x01", false), nil, "busybox ntpd"},
+		{"embedded", reply(3, 1, 4, -6, 0, "GPS", true), nil, "embedded SNTP"},
+		{"nothing known", reply(3, 2, 4, 0, 0x100, "\x0a\x00\x00\x01", false), func(o *Observation) {
+			o.Set("origin", "zero")
+			o.Set("poll_echo", "no")
+			o.Set("version_echo", "no")
+		}, unknownName},
+	}
+	for _, tt := range tests {
+		h, err := parser.ParseHeader(tt.reply)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := Observe("192.0.2.1", utils.FixedData(), h, len(tt.reply))
+		if tt.extra != nil {
+			tt.extra(o)
+		}
+		res := rules.Classify(o)
+		if res.Best.Name != tt.want {
+			t.Errorf("%s: classified as %s (%.2f), want %s\n%s", tt.name, res.Best.Name, res.Best.Confidence,
+				tt.want, res.Lines())
+		}
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	var tests = []struct {
+		data    string
+		wantErr bool
+	}{
+		{`{"implementations": [{"name": "a", "rules": [{"feature": "mode6", "equals": ["no"], "weight": 1}]}]}`, false},
+		{`{"implementations": []}`, true},
+		{`{"implementations": [{"name": "a", "rules": [{"feature": "mode6", "weight": 1}]}]}`, true},
+		{`{"implementations": [{"name": "a", "rules": [{"feature": "mode6", "equals": ["no"]}]}]}`, true},
+		{`not json`, true},
+	}
+	for _, tt := range tests {
+		if _, err := ParseRules([]byte(tt.data)); (err != nil) != tt.wantErr {
+			t.Errorf("ParseRules(%s) error = %v, wantErr %v", tt.data, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package fingerprint
+
+import (
+	"active/datastruct"
+	"active/malformed"
+	"active/packet"
+	"active/parser"
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Observation holds the features seen on one host, each one as a string so
+// that rules can compare them either as text or as numbers.
+type Observation struct {
+	Host     string
+	Features map[string]string
+}
+
+// Observe extracts the features of the parsed mode 3 reply to request, of
+// length bytes on the wire.
+func Observe(host string, request []byte, reply *parser.Header, length int) *Observation {
+	o := &Observation{Host: host, Features: make(map[string]string)}
+	if reply == nil || reply.Packet == nil || len(request) < parser.HeaderLength {
+		return o
+	}
+	req, _ := packet.Unmarshal(request)
+	rep := reply.Packet
+	o.Set("leap", strconv.Itoa(int(rep.Leap)))
+	o.Set("version", strconv.Itoa(int(rep.Version)))
+	o.Set("version_echo", yesNo(rep.Version == req.Version))
+	o.Set("mode", strconv.Itoa(int(rep.Mode)))
+	o.Set("stratum", strconv.Itoa(int(rep.Stratum)))
+	o.Set("poll", strconv.Itoa(int(rep.Poll)))
+	o.Set("poll_echo", yesNo(rep.Poll == req.Poll))
+	o.Set("precision", strconv.Itoa(int(rep.Precision)))
+	o.Set("root_delay", formatFloat(rep.RootDelay.Seconds()))
+	o.Set("root_disp", formatFloat(rep.RootDisp.Seconds()))
+	style, refID := refIDStyle(host, rep)
+	o.Set("refid_style", style)
+	if refID != "" {
+		o.Set("refid", refID)
+	}
+	o.Set("ref_time", zeroSet(rep.RefTime))
+
+	switch {
+	case rep.OriginTime == req.TransmitTime:
+		o.Set("origin", "echo")
+	case rep.OriginTime.IsZero():
+		o.Set("origin", "zero")
+	default:
+		o.Set("origin", "other")
+	}
+	if rep.ReceiveTime == rep.TransmitTime {
+		o.Set("rcv_xmt", "equal")
+	} else {
+		o.Set("rcv_xmt", "distinct")
+	}
+	if rep.TransmitTime.Fraction()&0xFFFF == 0 {
+		o.Set("xmt_fraction", "coarse")
+	} else {
+		o.Set("xmt_fraction", "fine")
+	}
+
+	o.Set("length", strconv.Itoa(length))
+	o.Set("extensions", strconv.Itoa(len(reply.Extensions)))
+	switch {
+	case reply.MAC == nil:
+		o.Set("mac", "none")
+	case reply.MAC.MSSNTP:
+		o.Set("mac", "ms-sntp")
+	case reply.MAC.IsCryptoNAK():
+		o.Set("mac", "crypto-nak")
+	default:
+		o.Set("mac", strconv.Itoa(len(reply.MAC.Digest)*8)+"-bit")
+	}
+	return o
+}
+
+// AddVersionMatrix adds the versions answered to requests of versions 1 to
+// 4, and whether the host echoes the version or always answers the same.
+func (o *Observation) AddVersionMatrix(m *parser.VersionMatrix) {
+	o.Set("versions", m.Key())
+	answered := make(map[int]struct{})
+	echo := true
+	for _, r := range m.Results {
+		if !r.Answered {
+			continue
+		}
+		answered[r.Version] = struct{}{}
+		echo = echo && r.Version == r.Sent
+	}
+	switch {
+	case len(answered) == 0:
+		return
+	case echo:
+		o.Set("version_policy", "echo")
+	case len(answered) == 1:
+		o.Set("version_policy", "fixed")
+	default:
+		o.Set("version_policy", "mixed")
+	}
+}
+
+// AddSystemVariables adds whether the host answers mode 6 READVAR and which
+// daemon its version string names, sv being nil when it did not answer.
+func (o *Observation) AddSystemVariables(sv *parser.SystemVariables) {
+	if sv == nil {
+		o.Set("mode6", "no")
+		return
+	}
+	o.Set("mode6", "yes")
+	version := strings.ToLower(sv.Version)
+	switch {
+	case strings.Contains(version, "ntpsec"):
+		o.Set("daemon", "ntpsec")
+	case strings.Contains(version, "ntpd"):
+		o.Set("daemon", "ntpd")
+	case version == "":
+		o.Set("daemon", "unknown")
+	default:
+		o.Set("daemon", "other")
+	}
+	if sv.Version != "" {
+		o.Set("daemon_version", sv.Version)
+	}
+}
+
+// AddMalformed adds the outcome of every malformed request variant.
+func (o *Observation) AddMalformed(r *malformed.Report) {
+	o.Set("malformed", r.Key())
+	for _, a := range r.Answers {
+		o.Set("malformed_"+a.Variant, a.Outcome())
+	}
+}
+
+// AddTimestamps adds how the host handles interleaved requests and whether
+// it leaks the timestamps of other clients.
+func (o *Observation) AddTimestamps(r *datastruct.TimestampReport) {
+	if r.Err != nil {
+		return
+	}
+	o.Set("interleaved", r.Interleaved)
+	o.Set("timestamp_leak", yesNo(r.Leak))
+}
+
+func (o *Observation) Set(name, value string) {
+	o.Features[name] = value
+}
+
+func (o *Observation) Get(name string) (string, bool) {
+	value, ok := o.Features[name]
+	return value, ok
+}
+
+func (o *Observation) Lines() string {
+	names := make([]string, 0, len(o.Features))
+	for name := range o.Features {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	buf := new(bytes.Buffer)
+	for _, name := range names {
+		buf.WriteString(fmt.Sprintf("    %-15s %s\n", name+":", o.Features[name]))
+	}
+	return buf.String()
+}
+
+// refIDStyle tells how the reference ID is filled: a kiss code, zero, ASCII
+// for a stratum 1 source, a local clock, an IPv6 hash or an address.
+func refIDStyle(host string, p *packet.Packet) (string, string) {
+	ip := net.ParseIP(host)
+	c := parser.ClassifyRefID(p, parser.RefIDHints{IPv6: ip != nil && ip.To4() == nil})
+	switch c.Kind {
+	case packet.RefIDKiss, packet.RefIDLocal:
+		return c.Kind.String(), c.Text
+	case packet.RefIDSource:
+		return "ascii", c.Text
+	default:
+		return c.Kind.String(), ""
+	}
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func zeroSet(t packet.Timestamp) string {
+	if t.IsZero() {
+		return "zero"
+	}
+	return "set"
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
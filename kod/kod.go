@@ -0,0 +1,147 @@
+package kod
+
+import (
+	"active/utils"
+	"bytes"
+	"fmt"
+	"github.com/spf13/viper"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	configPath       = "../resource/"
+	rateDelayKey     = "kod.rate_delay"
+	maxDelayKey      = "kod.max_delay"
+	defaultRateDelay = 2000
+	defaultMaxDelay  = 64000
+)
+
+var (
+	rateDelay time.Duration
+	maxDelay  time.Duration
+	mu        sync.Mutex
+	hosts     map[string]*hostState
+	networks  map[string]map[string]int
+)
+
+// hostState is what the registry remembers of a host that sent a kiss.
+type hostState struct {
+	code   string
+	denied bool
+	delay  time.Duration
+	next   time.Time
+}
+
+func init() {
+	viper.AddConfigPath(configPath)
+	viper.SetConfigType("yaml")
+	viper.SetConfigName("properties")
+	viper.SetDefault(rateDelayKey, defaultRateDelay)
+	viper.SetDefault(maxDelayKey, defaultMaxDelay)
+	err := viper.ReadInConfig()
+	if err != nil {
+		fmt.Printf("error reading resource file: %v", err)
+	}
+	rateDelay = time.Duration(viper.GetInt64(rateDelayKey)) * time.Millisecond
+	maxDelay = time.Duration(viper.GetInt64(maxDelayKey)) * time.Millisecond
+	Reset()
+}
+
+// Reset forgets every host and statistic.
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	hosts = make(map[string]*hostState)
+	networks = make(map[string]map[string]int)
+}
+
+// Record registers a kiss sent by host. DENY and RSTR stop any further probe
+// of the host, RATE doubles the time to wait before the next one.
+func Record(host, code string) {
+	mu.Lock()
+	defer mu.Unlock()
+	network := utils.NetworkOf(host)
+	if networks[network] == nil {
+		networks[network] = make(map[string]int)
+	}
+	networks[network][code]++
+
+	s, ok := hosts[host]
+	if !ok {
+		s = new(hostState)
+		hosts[host] = s
+	}
+	s.code = code
+	switch code {
+	case "DENY", "RSTR":
+		s.denied = true
+	case "RATE":
+		s.delay *= 2
+		if s.delay < rateDelay {
+			s.delay = rateDelay
+		}
+		if s.delay > maxDelay {
+			s.delay = maxDelay
+		}
+		s.next = time.Now().Add(s.delay)
+	}
+}
+
+// Wait blocks until host may be probed again, or returns an error if it
+// asked not to be probed at all.
+func Wait(host string) error {
+	mu.Lock()
+	s, ok := hosts[host]
+	if !ok {
+		mu.Unlock()
+		return nil
+	}
+	if s.denied {
+		mu.Unlock()
+		return fmt.Errorf("%s sent %s, not probed again", host, s.code)
+	}
+	wait := time.Until(s.next)
+	mu.Unlock()
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+	return nil
+}
+
+// Lines reports the kisses received per network and code, the networks with
+// the most kisses first.
+func Lines() string {
+	mu.Lock()
+	defer mu.Unlock()
+	if len(networks) == 0 {
+		return ""
+	}
+	totals := make(map[string]int)
+	keys := make([]string, 0, len(networks))
+	for n, codes := range networks {
+		keys = append(keys, n)
+		for _, c := range codes {
+			totals[n] += c
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return totals[keys[i]] > totals[keys[j]] || totals[keys[i]] == totals[keys[j]] && keys[i] < keys[j]
+	})
+	buf := new(bytes.Buffer)
+	buf.WriteString("Kiss-o'-Death per network:\n")
+	for _, n := range keys {
+		codes := make([]string, 0, len(networks[n]))
+		for c := range networks[n] {
+			codes = append(codes, c)
+		}
+		sort.Strings(codes)
+		buf.WriteString(fmt.Sprintf("    %-20s", n))
+		for _, c := range codes {
+			buf.WriteString(fmt.Sprintf(" %s x%d", c, networks[n][c]))
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
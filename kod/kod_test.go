@@ -0,0 +1,36 @@
+package kod
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecord(t *testing.T) {
+	rateDelay, maxDelay = 20*time.Millisecond, 30*time.Millisecond
+	Reset()
+	var tests = []struct {
+		host   string
+		code   string
+		denied bool
+		wait   time.Duration
+	}{
+		{"192.0.2.1", "RATE", false, 20 * time.Millisecond},
+		{"192.0.2.1", "RATE", false, 30 * time.Millisecond},
+		{"192.0.2.2", "DENY", true, 0},
+		{"192.0.2.3", "INIT", false, 0},
+	}
+	for _, test := range tests {
+		Record(test.host, test.code)
+		start := time.Now()
+		err := Wait(test.host)
+		waited := time.Since(start)
+		// A loaded machine may oversleep, only waiting too little is a failure
+		if (err != nil) != test.denied || waited < test.wait {
+			t.Errorf("Wait(%s) after %s = %v in %s", test.host, test.code, err, waited)
+		}
+	}
+	if lines := Lines(); !strings.Contains(lines, "192.0.2.0/24         DENY x1 INIT x1 RATE x2") {
+		t.Errorf("Lines() = %s", lines)
+	}
+}
@@ -0,0 +1,130 @@
+package listen
+
+import (
+	"active/datastruct"
+	"active/pcap"
+	"active/sockopt"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	ntpPort     = 123
+	headerLen   = 48
+	maxDatagram = 1500
+)
+
+var (
+	// The NTP multicast groups, 224.0.1.1 and ff0x::101 in link and site scope
+	group4  = net.IPv4(224, 0, 1, 1)
+	groups6 = []net.IP{net.ParseIP("ff02::101"), net.ParseIP("ff05::101")}
+)
+
+// Listen binds UDP port 123 on both address families, joins the NTP
+// multicast groups on the named interface, or the default one if empty, and
+// reports every NTP packet received until the duration has passed. Nothing
+// is ever sent.
+func Listen(ifName string, duration time.Duration) (<-chan *datastruct.RcvPayload, error) {
+	var ifi *net.Interface
+	if ifName != "" {
+		var err error
+		ifi, err = net.InterfaceByName(ifName)
+		if err != nil {
+			return nil, fmt.Errorf("error finding interface %s: %v", ifName, err)
+		}
+	}
+
+	conns := make([]*net.UDPConn, 0, 2)
+	conn4, err4 := net.ListenMulticastUDP("udp4", ifi, &net.UDPAddr{IP: group4, Port: ntpPort})
+	if err4 == nil {
+		conns = append(conns, conn4)
+	}
+	conn6, err6 := net.ListenMulticastUDP("udp6", ifi, &net.UDPAddr{IP: groups6[0], Port: ntpPort})
+	if err6 == nil {
+		conns = append(conns, conn6)
+		for _, g := range groups6[1:] {
+			err := sockopt.JoinGroup(conn6, ifi, g)
+			if err != nil {
+				fmt.Printf("error joining group %s: %v\n", g, err)
+			}
+		}
+	}
+	if len(conns) == 0 {
+		return nil, fmt.Errorf("error listening on port %d: %v, %v", ntpPort, err4, err6)
+	}
+	if err4 != nil {
+		fmt.Printf("IPv4 not listened: %v\n", err4)
+	}
+	if err6 != nil {
+		fmt.Printf("IPv6 not listened: %v\n", err6)
+	}
+
+	dataCh := make(chan *datastruct.RcvPayload, 1024)
+	end := time.Now().Add(duration)
+	wg := new(sync.WaitGroup)
+	wg.Add(len(conns))
+	for _, conn := range conns {
+		go collect(conn, end, dataCh, wg)
+	}
+	go func() {
+		wg.Wait()
+		close(dataCh)
+	}()
+	return dataCh, nil
+}
+
+func collect(conn *net.UDPConn, end time.Time, dataCh chan<- *datastruct.RcvPayload, wg *sync.WaitGroup) {
+	defer func() {
+		_ = conn.Close()
+		wg.Done()
+	}()
+	err := sockopt.EnableRecvTTL(conn)
+	if err != nil {
+		fmt.Println(err)
+	}
+	err = conn.SetReadDeadline(end)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	localAddr, _ := conn.LocalAddr().(*net.UDPAddr)
+	oob := make([]byte, sockopt.OOBSize)
+	for {
+		buf := make([]byte, maxDatagram)
+		n, oobn, _, udpAddr, err := conn.ReadMsgUDP(buf, oob)
+		if err != nil {
+			var netErr net.Error
+			if !errors.As(err, &netErr) || !netErr.Timeout() {
+				fmt.Println(err)
+			}
+			return
+		}
+		if !isNTP(buf[:n]) {
+			continue
+		}
+		payload := &datastruct.RcvPayload{
+			Host:    udpAddr.IP.String(),
+			Port:    udpAddr.Port,
+			Len:     n,
+			RcvTime: time.Now(),
+			RcvData: buf[:n],
+		}
+		payload.SetTTL(sockopt.ParseTTL(oob[:oobn]))
+		pcap.WriteUDP(udpAddr, localAddr, payload.TTL, payload.RcvData, payload.RcvTime)
+		dataCh <- payload
+	}
+}
+
+// isNTP keeps the packets that look like NTP in modes 1 to 5, leaving out
+// control and private messages.
+func isNTP(data []byte) bool {
+	if len(data) < headerLen {
+		return false
+	}
+	version := (data[0] >> 3) & 0b111
+	mode := data[0] & 0b111
+	return version >= 1 && version <= 4 && mode >= 1 && mode <= 5
+}
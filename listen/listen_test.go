@@ -0,0 +1,38 @@
+package listen
+
+import "testing"
+
+func TestIsNTP(t *testing.T) {
+	var tests = []struct {
+		name    string
+		length  int
+		version byte
+		mode    byte
+		want    bool
+	}{
+		{"empty", 0, 4, 3, false},
+		{"short", headerLen - 1, 4, 3, false},
+		{"reserved mode", headerLen, 4, 0, false},
+		{"symmetric active", headerLen, 4, 1, true},
+		{"symmetric passive", headerLen, 4, 2, true},
+		{"client", headerLen, 4, 3, true},
+		{"server", headerLen, 4, 4, true},
+		{"broadcast", headerLen, 4, 5, true},
+		{"control", headerLen, 2, 6, false},
+		{"private", headerLen, 2, 7, false},
+		{"version 0", headerLen, 0, 3, false},
+		{"version 1", headerLen, 1, 3, true},
+		{"version 5", headerLen, 5, 3, false},
+		{"version 7", headerLen, 7, 4, false},
+		{"with extension", headerLen + 16, 4, 4, true},
+	}
+	for _, tt := range tests {
+		data := make([]byte, tt.length)
+		if len(data) > 0 {
+			data[0] = tt.version<<3 | tt.mode
+		}
+		if got := isNTP(data); got != tt.want {
+			t.Errorf("%s: isNTP() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
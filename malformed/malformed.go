@@ -0,0 +1,215 @@
+package malformed
+
+import (
+	"active/datastruct"
+	"active/parser"
+	"active/udpdetect"
+	"active/utils"
+	"bytes"
+	"fmt"
+	"github.com/spf13/viper"
+	"strings"
+)
+
+const (
+	configPath  = "../resource/"
+	variantsKey = "malformed.variants"
+	junkLength  = 100
+	junkByte    = 0xA5
+	unknownType = 0xF123
+)
+
+// Variant is one crafted request, Expected telling whether a compliant
+// server answers it.
+type Variant struct {
+	Name        string
+	Description string
+	Request     []byte
+	Expected    bool
+}
+
+// Answer is what a host did with one variant.
+type Answer struct {
+	Variant  string
+	Status   datastruct.Status
+	Version  int
+	Mode     int
+	Len      int
+	KissCode string
+	Expected bool
+}
+
+// Report records the answers of one host to the whole suite.
+type Report struct {
+	Host    string
+	Answers []Answer
+}
+
+var (
+	suite = []Variant{
+		{"baseline", "the fixed client request", utils.NewPacketBuilder().Build(), true},
+		{"bad_version", "version 0", utils.NewPacketBuilder().Version(0).Build(), false},
+		{"mode_0", "reserved mode 0", utils.NewPacketBuilder().Mode(0).Build(), false},
+		{"truncated", "47-byte packet", utils.NewPacketBuilder().Truncate(47).Build(), false},
+		{"oversized", fmt.Sprintf("%d junk bytes after the header", junkLength),
+			utils.NewPacketBuilder().Append(bytes.Repeat([]byte{junkByte}, junkLength)).Build(), false},
+		{"leap", "leap indicator 1 instead of 3", utils.NewPacketBuilder().Leap(1).Build(), true},
+		{"unknown_extension", "NTPv4 request with an extension field of unknown type",
+			utils.NewPacketBuilder().Version(4).Extension(unknownType, nil, 28).Build(), true},
+		{"zero_transmit", "zero transmit timestamp", utils.NewPacketBuilder().TransmitTimestamp(0).Build(), false},
+	}
+)
+
+func init() {
+	viper.AddConfigPath(configPath)
+	viper.SetConfigType("yaml")
+	viper.SetConfigName("properties")
+	err := viper.ReadInConfig()
+	if err != nil {
+		fmt.Printf("error reading resource file: %v", err)
+	}
+}
+
+// Variants returns the variants listed in the configuration, all of them
+// when none is.
+func Variants() ([]Variant, error) {
+	names := viper.GetStringSlice(variantsKey)
+	if len(names) == 0 {
+		return suite, nil
+	}
+	res := make([]Variant, 0, len(names))
+	for _, name := range names {
+		v, ok := variantByName(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown malformed variant %q", name)
+		}
+		res = append(res, v)
+	}
+	return res, nil
+}
+
+func variantByName(name string) (Variant, bool) {
+	for _, v := range suite {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return Variant{}, false
+}
+
+// Probe sends every variant to host one after the other.
+func Probe(host string, variants []Variant) *Report {
+	r := &Report{Host: host}
+	for _, v := range variants {
+		p := udpdetect.DialHost(host, udpdetect.Probe{Requests: [][]byte{v.Request}, Type: datastruct.ProbeMalformed})
+		a := Answer{Variant: v.Name, Status: p.Status, Expected: v.Expected}
+		if p.Err != nil && p.Status == datastruct.StatusOpen {
+			a.Status = datastruct.StatusFiltered
+		}
+		if a.Answered() {
+			a.Len = p.Len
+			a.KissCode = p.KissCode
+			if len(p.RcvData) > 0 {
+				a.Version = int(p.RcvData[0]&0b00111000) >> 3
+				a.Mode = int(p.RcvData[0] & 0b111)
+			}
+		}
+		r.Answers = append(r.Answers, a)
+	}
+	return r
+}
+
+func (a Answer) Answered() bool {
+	return a.Status == datastruct.StatusOpen || a.Status == datastruct.StatusKoD
+}
+
+// Outcome sums the answer up as "answer", "kiss" or "silent".
+func (a Answer) Outcome() string {
+	switch {
+	case a.Status == datastruct.StatusKoD:
+		return "kiss"
+	case a.Answered():
+		return "answer"
+	default:
+		return "silent"
+	}
+}
+
+// Compliant tells whether the host answered exactly when a compliant server
+// would.
+func (a Answer) Compliant() bool {
+	return a.Answered() == a.Expected
+}
+
+// Violations lists the variants handled differently from a compliant server.
+func (r *Report) Violations() []string {
+	res := make([]string, 0)
+	for _, a := range r.Answers {
+		if !a.Compliant() {
+			res = append(res, a.Variant)
+		}
+	}
+	return res
+}
+
+// Key sums the report up with one letter per variant: A for an answer, K
+// for a kiss and - for silence.
+func (r *Report) Key() string {
+	buf := new(strings.Builder)
+	for _, a := range r.Answers {
+		switch a.Outcome() {
+		case "answer":
+			buf.WriteByte('A')
+		case "kiss":
+			buf.WriteByte('K')
+		default:
+			buf.WriteByte('-')
+		}
+	}
+	return buf.String()
+}
+
+func (r *Report) Lines() string {
+	buf := new(bytes.Buffer)
+	buf.WriteString(fmt.Sprintf("Malformed requests to %s: %s\n", r.Host, r.Key()))
+	for _, a := range r.Answers {
+		buf.WriteString(fmt.Sprintf("    %-18s %s", a.Variant+":", a.Outcome()))
+		if a.Answered() {
+			buf.WriteString(fmt.Sprintf(", v%d %s, %d bytes", a.Version, parser.ModeName(a.Mode), a.Len))
+			if a.KissCode != "" {
+				buf.WriteString(", " + a.KissCode)
+			}
+		}
+		if !a.Compliant() {
+			buf.WriteString(" (non-compliant)")
+		}
+		buf.WriteByte('\n')
+	}
+	if v := r.Violations(); len(v) > 0 {
+		buf.WriteString(fmt.Sprintf("Compliance:      %d violations (%s)\n\n\n", len(v), strings.Join(v, ", ")))
+	} else {
+		buf.WriteString("Compliance:      ok\n\n\n")
+	}
+	return buf.String()
+}
+
+// CSVLine writes host, key and the outcome of each variant, then the
+// number of violations.
+func (r *Report) CSVLine() string {
+	fields := []string{r.Host, r.Key()}
+	for _, a := range r.Answers {
+		fields = append(fields, a.Outcome())
+	}
+	fields = append(fields, fmt.Sprint(len(r.Violations())))
+	return strings.Join(fields, ",") + "\n"
+}
+
+// CSVHeader names the columns of CSVLine for the given variants.
+func CSVHeader(variants []Variant) string {
+	fields := []string{"host", "key"}
+	for _, v := range variants {
+		fields = append(fields, v.Name)
+	}
+	fields = append(fields, "violations")
+	return strings.Join(fields, ",") + "\n"
+}
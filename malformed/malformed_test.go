@@ -0,0 +1,85 @@
+package malformed
+
+import (
+	"active/datastruct"
+	"active/utils"
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestVariants(t *testing.T) {
+	baseline := utils.FixedData()
+	var tests = []struct {
+		name    string
+		length  int
+		leap    byte
+		version byte
+		mode    byte
+		zeroXmt bool
+		extType uint16
+		extLen  uint16
+	}{
+		{"baseline", 48, 3, 3, 3, false, 0, 0},
+		{"bad_version", 48, 3, 0, 3, false, 0, 0},
+		{"mode_0", 48, 3, 3, 0, false, 0, 0},
+		{"truncated", 47, 3, 3, 3, false, 0, 0},
+		{"oversized", 48 + junkLength, 3, 3, 3, false, 0, 0},
+		{"leap", 48, 1, 3, 3, false, 0, 0},
+		{"unknown_extension", 76, 3, 4, 3, false, unknownType, 28},
+		{"zero_transmit", 48, 3, 3, 3, true, 0, 0},
+	}
+	for _, tt := range tests {
+		v, ok := variantByName(tt.name)
+		if !ok {
+			t.Errorf("variant %s missing", tt.name)
+			continue
+		}
+		req := v.Request
+		if len(req) != tt.length {
+			t.Errorf("%s: %d bytes, want %d", tt.name, len(req), tt.length)
+			continue
+		}
+		if leap, version, mode := req[0]>>6, req[0]>>3&0x07, req[0]&0x07; leap != tt.leap ||
+			version != tt.version || mode != tt.mode {
+			t.Errorf("%s: leap %d, version %d, mode %d, want %d, %d, %d", tt.name, leap, version, mode,
+				tt.leap, tt.version, tt.mode)
+		}
+		// Nothing else of the header may differ from the baseline
+		if !bytes.Equal(req[1:40], baseline[1:40]) {
+			t.Errorf("%s: header differs from the baseline", tt.name)
+		}
+		if len(req) < 48 {
+			continue
+		}
+		if zero := binary.BigEndian.Uint64(req[40:48]) == 0; zero != tt.zeroXmt {
+			t.Errorf("%s: zero transmit timestamp %v, want %v", tt.name, zero, tt.zeroXmt)
+		}
+		if tt.extType != 0 {
+			extType, extLen := binary.BigEndian.Uint16(req[48:50]), binary.BigEndian.Uint16(req[50:52])
+			if extType != tt.extType || extLen != tt.extLen {
+				t.Errorf("%s: extension 0x%04X of %d bytes, want 0x%04X of %d", tt.name, extType, extLen,
+					tt.extType, tt.extLen)
+			}
+		} else if tt.name == "oversized" && !bytes.Equal(req[48:], bytes.Repeat([]byte{junkByte}, junkLength)) {
+			t.Errorf("%s: trailing bytes are not junk", tt.name)
+		}
+	}
+}
+
+func TestReport(t *testing.T) {
+	r := &Report{Host: "192.0.2.1", Answers: []Answer{
+		{Variant: "baseline", Status: datastruct.StatusOpen, Expected: true},
+		{Variant: "mode_0", Status: datastruct.StatusOpen, Expected: false},
+		{Variant: "truncated", Status: datastruct.StatusFiltered, Expected: false},
+		{Variant: "leap", Status: datastruct.StatusKoD, Expected: true},
+		{Variant: "unknown_extension", Status: datastruct.StatusFiltered, Expected: true},
+	}}
+	if key := r.Key(); key != "AA-K-" {
+		t.Errorf("Key() = %s, want AA-K-", key)
+	}
+	v := r.Violations()
+	if len(v) != 2 || v[0] != "mode_0" || v[1] != "unknown_extension" {
+		t.Errorf("Violations() = %v, want [mode_0 unknown_extension]", v)
+	}
+}
@@ -2,24 +2,27 @@ package nts
 
 import (
 	"active/datastruct"
+	"active/packet"
 	"active/parser"
+	"active/pcap"
 	"crypto/tls"
 	"fmt"
 	"io"
+	"net"
+	"time"
 )
 
-var (
-	variableReq = []byte{
-		0x80, 0x01, 0x00, 0x02, 0x00, 0x00, 0x80, 0x04, 0x00, 0x02, 0x00, 0x0F, 0x80, 0x00, 0x00, 0x00,
-	}
-	otherThanAesSivCmac = []byte{
-		0x80, 0x01, 0x00, 0x02, 0x00, 0x00, 0x80, 0x04, 0x00, 0x3C, 0x00, 0x01, 0x00, 0x02, 0x00, 0x03,
-		0x00, 0x04, 0x00, 0x05, 0x00, 0x06, 0x00, 0x07, 0x00, 0x08, 0x00, 0x09, 0x00, 0x0A, 0x00, 0x0B,
-		0x00, 0x0C, 0x00, 0x0D, 0x00, 0x0E, 0x00, 0x12, 0x00, 0x13, 0x00, 0x14, 0x00, 0x15, 0x00, 0x16,
-		0x00, 0x17, 0x00, 0x18, 0x00, 0x19, 0x00, 0x1A, 0x00, 0x1B, 0x00, 0x1C, 0x00, 0x1D, 0x00, 0x1E,
-		0x00, 0x1F, 0x00, 0x20, 0x00, 0x21, 0x80, 0x00, 0x00, 0x00,
+// otherThanAesSivCmac offers every registered AEAD algorithm except the
+// AES-SIV-CMAC ones, 0x0F to 0x11.
+func otherThanAesSivCmac() []byte {
+	ids := make([]uint16, 0)
+	for id := uint16(0x01); id <= 0x21; id++ {
+		if id < aesSivCmac256 || id > 0x11 {
+			ids = append(ids, id)
+		}
 	}
-)
+	return packet.MarshalRecords(packet.NextProtocolRecord(), packet.AEADRecord(ids...), packet.EOMRecord())
+}
 
 func singleReadWrite(aeadID byte, conn *tls.Conn, info datastruct.DetectInfo) error {
 	defer func(conn *tls.Conn) {
@@ -29,9 +32,8 @@ func singleReadWrite(aeadID byte, conn *tls.Conn, info datastruct.DetectInfo) er
 		}
 	}(conn)
 
-	variableReq[11] = aeadID
-
-	_, err := conn.Write(variableReq)
+	req := packet.MarshalRecords(packet.NextProtocolRecord(), packet.AEADRecord(uint16(aeadID)), packet.EOMRecord())
+	_, err := conn.Write(req)
 	if err != nil {
 		return fmt.Errorf("send NTS-KE request failed: %v", err)
 	}
@@ -52,7 +54,7 @@ func checkOtherThanAesSivCmac(conn *tls.Conn, info datastruct.DetectInfo) (bool,
 		}
 	}(conn)
 
-	_, err := conn.Write(otherThanAesSivCmac)
+	_, err := conn.Write(otherThanAesSivCmac())
 	if err != nil {
 		return false, fmt.Errorf("send NTS-KE request failed: %v", err)
 	}
@@ -79,3 +81,39 @@ func checkOtherThanAesSivCmac(conn *tls.Conn, info datastruct.DetectInfo) (bool,
 	}
 	return support, nil
 }
+
+// dialTLS behaves like tls.DialWithDialer, but records the connection together
+// with its TLS key log when a capture is running.
+func dialTLS(dialer *net.Dialer, addr string, config *tls.Config) (*tls.Conn, error) {
+	if !pcap.Enabled() {
+		return tls.DialWithDialer(dialer, "tcp", addr, config)
+	}
+	rawConn, err := dialer.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	captured := pcap.CaptureTCP(rawConn)
+	config = config.Clone()
+	if c, ok := captured.(*pcap.TCPConn); ok {
+		config.KeyLogWriter = c.KeyLog()
+	}
+	conn := tls.Client(captured, config)
+	if dialer.Timeout > 0 {
+		err = conn.SetDeadline(time.Now().Add(dialer.Timeout))
+		if err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	err = conn.Handshake()
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	err = conn.SetDeadline(time.Time{})
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
@@ -2,6 +2,7 @@ package nts
 
 import (
 	"active/datastruct"
+	"active/packet"
 	"crypto/tls"
 	"fmt"
 	"github.com/spf13/viper"
@@ -20,14 +21,11 @@ const (
 	haltTimeKey     = "nts.detect.halt_time"
 	defaultTimeout  = 5000
 	defaultHaltTime = 500
+	requestServer   = "194.58.207.80"
+	requestPort     = 4123
 )
 
 var (
-	reqBytes = []byte{
-		0x80, 0x01, 0x00, 0x02, 0x00, 0x00, 0x80, 0x04, 0x00, 0x02, 0x00, 0x0F, 0x00, 0x06, 0x00, 0x0D,
-		0x31, 0x39, 0x34, 0x2E, 0x35, 0x38, 0x2E, 0x32, 0x30, 0x37, 0x2E, 0x38, 0x30, 0x00, 0x07, 0x00,
-		0x02, 0x10, 0x1B, 0x80, 0x00, 0x00, 0x00,
-	}
 	timeout  time.Duration
 	haltTime time.Duration
 )
@@ -58,7 +56,7 @@ func DialNTSKE(host, serverName string, aeadID byte) (*datastruct.NTSPayload, er
 
 	dialer := &net.Dialer{Timeout: timeout}
 
-	conn, err := tls.DialWithDialer(dialer, "tcp", host+":4460", config)
+	conn, err := dialTLS(dialer, host+":4460", config)
 	if err != nil {
 		return nil, fmt.Errorf("cannot dial TLS server %s: %v", host, err)
 	}
@@ -93,13 +91,13 @@ func DialNTSKE(host, serverName string, aeadID byte) (*datastruct.NTSPayload, er
 		return nil, fmt.Errorf("export S2C key failed: %v", err)
 	}
 
-	if aeadID > 0x00 && aeadID <= 0x21 {
-		reqBytes[11] = aeadID
-	} else {
-		reqBytes[11] = aesSivCmac256
+	if aeadID == 0x00 || aeadID > 0x21 {
+		aeadID = aesSivCmac256
 	}
+	req := packet.MarshalRecords(packet.NextProtocolRecord(), packet.AEADRecord(uint16(aeadID)),
+		packet.ServerRecord(requestServer), packet.PortRecord(requestPort), packet.EOMRecord())
 
-	_, err = conn.Write(reqBytes)
+	_, err = conn.Write(req)
 	if err != nil {
 		return nil, fmt.Errorf("send NTS-KE request failed: %v", err)
 	}
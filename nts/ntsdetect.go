@@ -20,7 +20,7 @@ func DetectNTSServer(host, serverName string) (*datastruct.NTSDetectPayload, err
 	addr := host + ":4460"
 	dialer := &net.Dialer{Timeout: timeout}
 
-	conn, err := tls.DialWithDialer(dialer, "tcp", addr, config)
+	conn, err := dialTLS(dialer, addr, config)
 	if err != nil {
 		return nil, fmt.Errorf("cannot dial TLS server %s: %v", addr, err)
 	}
@@ -107,7 +107,7 @@ func DetectNTSServer(host, serverName string) (*datastruct.NTSDetectPayload, err
 func newConnection(addr string, config *tls.Config, dialer *net.Dialer) (*tls.Conn, error) {
 	<-time.After(haltTime)
 
-	conn, err := tls.DialWithDialer(dialer, "tcp", addr, config)
+	conn, err := dialTLS(dialer, addr, config)
 	if err != nil {
 		return nil, fmt.Errorf("cannot dial TLS server %s: %v", addr, err)
 	}
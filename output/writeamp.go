@@ -0,0 +1,71 @@
+package output
+
+import (
+	"active/datastruct"
+	"bufio"
+	"fmt"
+	"github.com/spf13/viper"
+	"os"
+	"strings"
+	"time"
+)
+
+// WriteAmplificationToFile writes the amplification results of a scan and
+// their risk table next to its other output.
+func WriteAmplificationToFile(records []*datastruct.Amplification, info string, now time.Time) {
+	if len(records) == 0 {
+		return
+	}
+	dirPath := viper.GetString(outputPathKey)
+	info = strings.Replace(info, "/", "_", 1)
+	base := dirPath + now.Format(fileTimeFormat) + info
+	err := WriteAmplification(records, base+"_amplification.csv", base+"_risk.csv")
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+// WriteAmplification writes one CSV line per host and probe type to hostPath,
+// and the risk table per country, ISP and probe type to riskPath. ip2region
+// offers no ASN, the ISP name stands for the network.
+func WriteAmplification(records []*datastruct.Amplification, hostPath, riskPath string) error {
+	table := datastruct.NewRiskTable()
+	err := writeCSV(hostPath, func(writer *bufio.Writer) error {
+		for _, a := range records {
+			a.Locate()
+			table.Add(a)
+			err := a.WriteToCSV(writer)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return writeCSV(riskPath, table.WriteToCSV)
+}
+
+func writeCSV(path string, write func(*bufio.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating file %s: %v", path, err)
+	}
+	defer func(f *os.File) {
+		err := f.Close()
+		if err != nil {
+			fmt.Printf("error closing file %s: %v", path, err)
+		}
+	}(file)
+	writer := bufio.NewWriter(file)
+	err = write(writer)
+	if err != nil {
+		return err
+	}
+	err = writer.Flush()
+	if err != nil {
+		return fmt.Errorf("error flushing writer: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package output
+
+import (
+	"fmt"
+	"github.com/spf13/viper"
+	"os"
+	"time"
+)
+
+func WriteControlToFile(raw, parsed, host string) {
+	now := time.Now()
+	dirPath := viper.GetString(outputPathKey)
+	filePath := dirPath + now.Format(fileTimeFormat) + host + "_control.txt"
+	dividingLine := now.Format(dividingLineFormat)
+
+	commonWrite(filePath, []string{dividingLine, raw, beforeParsed, parsed, "\n\n"})
+}
+
+// AppendToFile appends content to the file at path, creating it if needed.
+func AppendToFile(path, content string) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening file %s: %v", path, err)
+	}
+	_, err = file.WriteString(content)
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("error writing file %s: %v", path, err)
+	}
+	return file.Close()
+}
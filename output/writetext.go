@@ -39,6 +39,60 @@ func WriteToFile(raw, parsed, info string, seq int, rcvTime, now time.Time) {
 	commonWrite(filePath, []string{seqLine, dividingLine, raw, beforeParsed, parsed})
 }
 
+// StatusWriter streams the status lines of a scan to its status file, which
+// is only created with the first line, so that a large network with few
+// responders is not held in memory.
+type StatusWriter struct {
+	path   string
+	file   *os.File
+	writer *bufio.Writer
+	err    error
+}
+
+func NewStatusWriter(info string, now time.Time) *StatusWriter {
+	return &StatusWriter{path: statusPath(info, now)}
+}
+
+// WriteString appends s to the status file. After the first error, every
+// write is dropped and Close reports it.
+func (w *StatusWriter) WriteString(s string) {
+	if w.err != nil || s == "" {
+		return
+	}
+	if w.file == nil {
+		w.file, w.err = os.OpenFile(w.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if w.err != nil {
+			w.err = fmt.Errorf("error opening file %s: %v", w.path, w.err)
+			return
+		}
+		w.writer = bufio.NewWriter(w.file)
+	}
+	if _, err := w.writer.WriteString(s); err != nil {
+		w.err = fmt.Errorf("error writing file %s: %v", w.path, err)
+	}
+}
+
+func (w *StatusWriter) Close() error {
+	if w.file == nil {
+		return w.err
+	}
+	err := w.writer.Flush()
+	if err != nil && w.err == nil {
+		w.err = fmt.Errorf("error flushing writer: %v", err)
+	}
+	err = w.file.Close()
+	if err != nil && w.err == nil {
+		w.err = fmt.Errorf("error closing file %s: %v", w.path, err)
+	}
+	return w.err
+}
+
+func statusPath(info string, now time.Time) string {
+	dirPath := viper.GetString(outputPathKey)
+	info = strings.Replace(info, "/", "_", 1)
+	return dirPath + now.Format(fileTimeFormat) + info + "_status.txt"
+}
+
 func commonWrite(filePath string, strs []string) {
 	var file *os.File
 
@@ -2,6 +2,7 @@ package output
 
 import (
 	"active/async"
+	"active/datastruct"
 	"active/parser"
 	"active/udpdetect"
 	"active/utils"
@@ -26,6 +27,9 @@ func TestWriteToFile(t *testing.T) {
 			t.Error(err)
 			continue
 		}
+		if p.Status != datastruct.StatusOpen {
+			continue
+		}
 		header, err := parser.ParseHeader(p.RcvData)
 		if err != nil {
 			t.Error(err)
@@ -53,6 +57,9 @@ func TestAsyncWriteToFile(t *testing.T) {
 			t.Error(err)
 			continue
 		}
+		if p.Status != datastruct.StatusOpen {
+			continue
+		}
 		header, err := parser.ParseHeader(p.RcvData)
 		if err != nil {
 			t.Error(err)
@@ -0,0 +1,71 @@
+package packet
+
+import (
+	"encoding/binary"
+)
+
+const (
+	RecordEOM          = 0
+	RecordNextProtocol = 1
+	RecordError        = 2
+	RecordWarning      = 3
+	RecordAEAD         = 4
+	RecordCookie       = 5
+	RecordServer       = 6
+	RecordPort         = 7
+
+	ProtocolNTPv4 = 0
+)
+
+// Record is an NTS-KE record from RFC 8915.
+type Record struct {
+	Critical bool
+	Type     uint16
+	Body     []byte
+}
+
+// MarshalRecords encodes the records one after the other.
+func MarshalRecords(records ...Record) []byte {
+	data := make([]byte, 0)
+	for _, r := range records {
+		header := make([]byte, 4)
+		typ := r.Type & 0x7FFF
+		if r.Critical {
+			typ |= 0x8000
+		}
+		binary.BigEndian.PutUint16(header[0:2], typ)
+		binary.BigEndian.PutUint16(header[2:4], uint16(len(r.Body)))
+		data = append(append(data, header...), r.Body...)
+	}
+	return data
+}
+
+// NextProtocolRecord asks for NTPv4.
+func NextProtocolRecord() Record {
+	return Record{Critical: true, Type: RecordNextProtocol, Body: uint16s(ProtocolNTPv4)}
+}
+
+// AEADRecord offers the AEAD algorithms in order of preference.
+func AEADRecord(ids ...uint16) Record {
+	return Record{Critical: true, Type: RecordAEAD, Body: uint16s(ids...)}
+}
+
+func ServerRecord(server string) Record {
+	return Record{Type: RecordServer, Body: []byte(server)}
+}
+
+func PortRecord(port uint16) Record {
+	return Record{Type: RecordPort, Body: uint16s(port)}
+}
+
+func EOMRecord() Record {
+	return Record{Critical: true, Type: RecordEOM}
+}
+
+func uint16s(values ...uint16) []byte {
+	res := make([]byte, 2*len(values))
+	for i, v := range values {
+		binary.BigEndian.PutUint16(res[2*i:], v)
+	}
+	return res
+}
@@ -0,0 +1,163 @@
+package packet
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	HeaderLength          = 48
+	extensionHeaderLength = 4
+	minExtensionLength    = 16
+	maxMACLength          = 24
+	keyIDLength           = 4
+	msSNTPExtendedLength  = 72
+)
+
+// Packet is an NTP packet of modes 1 to 5: the header, then the extension
+// fields, the MAC and any raw bytes left after them. Numeric fields hold the
+// wire values, typed so that they convert to seconds and times.
+type Packet struct {
+	Leap         uint8
+	Version      uint8
+	Mode         uint8
+	Stratum      uint8
+	Poll         int8
+	Precision    int8
+	RootDelay    ShortFormat
+	RootDisp     ShortFormat
+	RefID        RefID
+	RefTime      Timestamp
+	OriginTime   Timestamp
+	ReceiveTime  Timestamp
+	TransmitTime Timestamp
+	Extensions   []Extension
+	MAC          *MAC
+	Trailer      []byte
+}
+
+// Extension is an RFC 7822 extension field. Value holds the body with its
+// padding.
+type Extension struct {
+	Type  uint16
+	Value []byte
+}
+
+// MAC closes an authenticated packet: a key ID and a digest, the digest
+// being empty for a crypto-NAK.
+type MAC struct {
+	KeyID  uint32
+	Digest []byte
+}
+
+// Len returns the length of the field on the wire, the value being padded to
+// a multiple of 4 bytes.
+func (e Extension) Len() int {
+	return (extensionHeaderLength + len(e.Value) + 3) &^ 3
+}
+
+// Marshal encodes the packet into a new buffer.
+func (p *Packet) Marshal() []byte {
+	data := p.MarshalUnsigned()
+	if p.MAC != nil {
+		keyID := make([]byte, keyIDLength)
+		binary.BigEndian.PutUint32(keyID, p.MAC.KeyID)
+		data = append(append(data, keyID...), p.MAC.Digest...)
+	}
+	return append(data, p.Trailer...)
+}
+
+// MarshalUnsigned encodes the header and the extension fields, the part of
+// the packet a MAC is computed over.
+func (p *Packet) MarshalUnsigned() []byte {
+	data := make([]byte, HeaderLength)
+	data[0] = p.Leap<<6 | (p.Version&0b111)<<3 | p.Mode&0b111
+	data[1] = p.Stratum
+	data[2] = byte(p.Poll)
+	data[3] = byte(p.Precision)
+	binary.BigEndian.PutUint32(data[4:8], uint32(p.RootDelay))
+	binary.BigEndian.PutUint32(data[8:12], uint32(p.RootDisp))
+	binary.BigEndian.PutUint32(data[12:16], uint32(p.RefID))
+	binary.BigEndian.PutUint64(data[16:24], uint64(p.RefTime))
+	binary.BigEndian.PutUint64(data[24:32], uint64(p.OriginTime))
+	binary.BigEndian.PutUint64(data[32:40], uint64(p.ReceiveTime))
+	binary.BigEndian.PutUint64(data[40:48], uint64(p.TransmitTime))
+	for _, e := range p.Extensions {
+		field := make([]byte, e.Len())
+		binary.BigEndian.PutUint16(field[0:2], e.Type)
+		binary.BigEndian.PutUint16(field[2:4], uint16(len(field)))
+		copy(field[extensionHeaderLength:], e.Value)
+		data = append(data, field...)
+	}
+	return data
+}
+
+// Unmarshal decodes a packet. When the bytes after the header are neither
+// extension fields nor a MAC, the packet is still returned with those bytes
+// in Trailer, together with the error.
+func Unmarshal(data []byte) (*Packet, error) {
+	if len(data) < HeaderLength {
+		return nil, fmt.Errorf("header length %d less than 48", len(data))
+	}
+	p := &Packet{
+		Leap:         data[0] >> 6,
+		Version:      data[0] >> 3 & 0b111,
+		Mode:         data[0] & 0b111,
+		Stratum:      data[1],
+		Poll:         int8(data[2]),
+		Precision:    int8(data[3]),
+		RootDelay:    ShortFormat(binary.BigEndian.Uint32(data[4:8])),
+		RootDisp:     ShortFormat(binary.BigEndian.Uint32(data[8:12])),
+		RefID:        RefID(binary.BigEndian.Uint32(data[12:16])),
+		RefTime:      Timestamp(binary.BigEndian.Uint64(data[16:24])),
+		OriginTime:   Timestamp(binary.BigEndian.Uint64(data[24:32])),
+		ReceiveTime:  Timestamp(binary.BigEndian.Uint64(data[32:40])),
+		TransmitTime: Timestamp(binary.BigEndian.Uint64(data[40:48])),
+	}
+	rest := data[HeaderLength:]
+	exts, rest, err := SplitTrailer(rest)
+	if err != nil {
+		if len(data) == HeaderLength+msSNTPExtendedLength {
+			// The MS-SNTP extended authenticator is longer than any MAC
+			p.MAC = newMAC(data[HeaderLength:])
+			return p, nil
+		}
+		p.Trailer = copyBytes(data[HeaderLength:])
+		return p, err
+	}
+	p.Extensions = exts
+	switch len(rest) {
+	case 0:
+	case keyIDLength, 20, maxMACLength:
+		p.MAC = newMAC(rest)
+	default:
+		p.Trailer = copyBytes(rest)
+		return p, fmt.Errorf("%d trailing bytes are neither an extension field nor a MAC", len(rest))
+	}
+	return p, nil
+}
+
+// SplitTrailer reads extension fields while more than a MAC is left, as in
+// RFC 7822 section 7.5, and returns them with the bytes after them.
+func SplitTrailer(data []byte) ([]Extension, []byte, error) {
+	var res []Extension
+	for len(data) > maxMACLength {
+		typ := binary.BigEndian.Uint16(data[0:2])
+		length := int(binary.BigEndian.Uint16(data[2:4]))
+		if length < minExtensionLength || length%4 != 0 || length > len(data) {
+			return res, data, fmt.Errorf("extension field 0x%04X has invalid length %d with %d bytes left",
+				typ, length, len(data))
+		}
+		res = append(res, Extension{Type: typ, Value: copyBytes(data[extensionHeaderLength:length])})
+		data = data[length:]
+	}
+	return res, data, nil
+}
+
+func newMAC(data []byte) *MAC {
+	return &MAC{KeyID: binary.BigEndian.Uint32(data[0:keyIDLength]), Digest: copyBytes(data[keyIDLength:])}
+}
+
+func copyBytes(data []byte) []byte {
+	return append([]byte(nil), data...)
+}
@@ -0,0 +1,94 @@
+package packet
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestMarshalRoundTrip(t *testing.T) {
+	header := Packet{
+		Leap: 3, Version: 4, Mode: 4, Stratum: 2, Poll: 6, Precision: -23,
+		RootDelay: 0x00001234, RootDisp: 0x00015678, RefID: 0xC0000201,
+		RefTime: 0xE8000000_00000001, OriginTime: 0xE8000001_00000002,
+		ReceiveTime: 0xE8000002_00000003, TransmitTime: 0xE8000003_00000004,
+	}
+	withExtensions := header
+	withExtensions.Extensions = []Extension{
+		{Type: 0x0104, Value: bytes.Repeat([]byte{0xAB}, 32)},
+		{Type: 0x0404, Value: make([]byte, 36)},
+	}
+	withMAC := header
+	withMAC.Extensions = []Extension{{Type: 0x2005, Value: make([]byte, 24)}}
+	withMAC.MAC = &MAC{KeyID: 7, Digest: bytes.Repeat([]byte{0x11}, 20)}
+	nak := header
+	nak.MAC = &MAC{KeyID: 0}
+	msSNTP := header
+	msSNTP.MAC = &MAC{KeyID: 0x80000457, Digest: bytes.Repeat([]byte{0x22}, 68)}
+
+	var tests = []struct {
+		name   string
+		packet Packet
+		length int
+	}{
+		{"header", header, 48},
+		{"extensions", withExtensions, 124},
+		{"extension and MAC", withMAC, 100},
+		{"crypto-NAK", nak, 52},
+		{"MS-SNTP extended", msSNTP, 120},
+	}
+	for _, tt := range tests {
+		data := tt.packet.Marshal()
+		if len(data) != tt.length {
+			t.Errorf("%s: marshalled %d bytes, want %d", tt.name, len(data), tt.length)
+		}
+		got, err := Unmarshal(data)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(*got, tt.packet) {
+			t.Errorf("%s: round trip gave %+v, want %+v", tt.name, *got, tt.packet)
+		}
+		if !bytes.Equal(got.Marshal(), data) {
+			t.Errorf("%s: marshalling the decoded packet differs", tt.name)
+		}
+	}
+}
+
+func TestUnmarshalTrailer(t *testing.T) {
+	junk := append(make([]byte, HeaderLength), bytes.Repeat([]byte{0xA5}, 100)...)
+	p, err := Unmarshal(junk)
+	if err == nil || p == nil || len(p.Trailer) != 100 {
+		t.Errorf("junk trailer: packet %v, error %v", p, err)
+	}
+	if !bytes.Equal(p.Marshal(), junk) {
+		t.Error("junk trailer does not round trip")
+	}
+	if _, err = Unmarshal(make([]byte, 47)); err == nil {
+		t.Error("47-byte packet accepted")
+	}
+}
+
+func TestMarshalRecords(t *testing.T) {
+	var tests = []struct {
+		name    string
+		records []Record
+		want    []byte
+	}{
+		{"AEAD 15", []Record{NextProtocolRecord(), AEADRecord(0x0F), EOMRecord()}, []byte{
+			0x80, 0x01, 0x00, 0x02, 0x00, 0x00, 0x80, 0x04, 0x00, 0x02, 0x00, 0x0F, 0x80, 0x00, 0x00, 0x00,
+		}},
+		{"server and port", []Record{NextProtocolRecord(), AEADRecord(0x0F), ServerRecord("194.58.207.80"),
+			PortRecord(4123), EOMRecord()}, []byte{
+			0x80, 0x01, 0x00, 0x02, 0x00, 0x00, 0x80, 0x04, 0x00, 0x02, 0x00, 0x0F, 0x00, 0x06, 0x00, 0x0D,
+			0x31, 0x39, 0x34, 0x2E, 0x35, 0x38, 0x2E, 0x32, 0x30, 0x37, 0x2E, 0x38, 0x30, 0x00, 0x07, 0x00,
+			0x02, 0x10, 0x1B, 0x80, 0x00, 0x00, 0x00,
+		}},
+	}
+	for _, tt := range tests {
+		if got := MarshalRecords(tt.records...); !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: MarshalRecords() = % X, want % X", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package packet
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	// eraLength is the number of seconds of an NTP era, the first one ending
+	// on 2036-02-07.
+	eraLength = 1 << 32
+	// unixOffset is the number of seconds from 1900 to 1970.
+	unixOffset = 2208988800
+	// eraJumpSkew is how far from the local clock a timestamp placed in
+	// another era may lie before the era becomes suspicious.
+	eraJumpSkew = 24 * time.Hour
+)
+
+var (
+	ntpEpoch = time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// ShortFormat is the 32-bit NTP short format of root delay and root
+// dispersion, seconds in 16.16 fixed point.
+type ShortFormat uint32
+
+// Timestamp is the 64-bit NTP timestamp format, seconds since 1900 in 32.32
+// fixed point.
+type Timestamp uint64
+
+// RefID is the reference ID, whose meaning depends on the stratum: a kiss
+// code at stratum 0, a clock source at stratum 1 and above it the IPv4
+// address of the upstream server, or the first four bytes of the MD5 hash of
+// its IPv6 address.
+type RefID uint32
+
+type RefIDKind int
+
+const (
+	RefIDZero RefIDKind = iota
+	RefIDKiss
+	RefIDSource
+	RefIDAddress
+	RefIDLocal
+	RefIDHash
+)
+
+const (
+	// localRefID is what w32time and others announce when they run on their
+	// own clock.
+	localRefID = "LOCL"
+)
+
+var (
+	refIDKindNames = []string{"zero", "kiss", "source", "address", "local", "hash"}
+)
+
+func (s ShortFormat) Seconds() float64 {
+	return float64(s) / (1 << 16)
+}
+
+func (s ShortFormat) Duration() time.Duration {
+	return time.Duration(uint64(s) * uint64(time.Second) >> 16)
+}
+
+// NewTimestamp converts t to the NTP timestamp format.
+func NewTimestamp(t time.Time) Timestamp {
+	d := t.Sub(ntpEpoch)
+	seconds := d / time.Second
+	frac := ((d - seconds*time.Second) << 32) / time.Second
+	return Timestamp(uint64(seconds)<<32 | uint64(frac))
+}
+
+func (t Timestamp) Seconds() uint32 {
+	return uint32(t >> 32)
+}
+
+func (t Timestamp) Fraction() uint32 {
+	return uint32(t)
+}
+
+func (t Timestamp) IsZero() bool {
+	return t == 0
+}
+
+// Time converts the timestamp to a time of era 0, counted from 1900.
+func (t Timestamp) Time() time.Time {
+	frac := (time.Duration(t.Fraction()) * time.Second) >> 32
+	return ntpEpoch.Add(time.Duration(t.Seconds()) * time.Second).Add(frac)
+}
+
+// TimeNear converts the timestamp to the time closest to pivot among every
+// era, which is right as long as the clocks differ by less than 68 years. The
+// zero timestamp marks an unset field and stays at 1900.
+func (t Timestamp) TimeNear(pivot time.Time) time.Time {
+	if t.IsZero() {
+		return ntpEpoch
+	}
+	frac := (int64(t.Fraction()) * int64(time.Second)) >> 32
+	return time.Unix(t.secondsNear(pivot)-unixOffset, frac).UTC()
+}
+
+// secondsNear returns the seconds since 1900 of the timestamp placed in the
+// era closest to pivot.
+func (t Timestamp) secondsNear(pivot time.Time) int64 {
+	p := pivot.Unix() + unixOffset
+	res := p&^(eraLength-1) + int64(t.Seconds())
+	if res-p > eraLength/2 {
+		res -= eraLength
+	} else if p-res > eraLength/2 {
+		res += eraLength
+	}
+	return res
+}
+
+// Era returns the NTP era of the time, 0 from 1900 to 2036, negative before.
+func Era(t time.Time) int {
+	return int((t.Unix() + unixOffset) >> 32)
+}
+
+// EraJump tells whether the timestamp, read relative to pivot, lands in
+// another era than pivot while lying more than a day away from it. A server
+// close to the 2036 rollover legitimately crosses the era, a wrapped or
+// broken clock does so from afar.
+func (t Timestamp) EraJump(pivot time.Time) bool {
+	if t.IsZero() {
+		return false
+	}
+	res := t.TimeNear(pivot)
+	if Era(res) == Era(pivot) {
+		return false
+	}
+	d := res.Sub(pivot)
+	return d > eraJumpSkew || d < -eraJumpSkew
+}
+
+func (t Timestamp) Bytes() []byte {
+	res := make([]byte, 8)
+	binary.BigEndian.PutUint64(res, uint64(t))
+	return res
+}
+
+func (r RefID) Bytes() []byte {
+	res := make([]byte, 4)
+	binary.BigEndian.PutUint32(res, uint32(r))
+	return res
+}
+
+// IP reads the reference ID as an IPv4 address.
+func (r RefID) IP() net.IP {
+	return net.IP(r.Bytes())
+}
+
+// ASCII reads the reference ID as up to four characters padded with zeros.
+func (r RefID) ASCII() string {
+	return strings.TrimRight(string(r.Bytes()), "\x00")
+}
+
+// IPv6RefID returns the reference ID a server synchronized to the IPv6
+// address ip announces.
+func IPv6RefID(ip net.IP) RefID {
+	sum := md5.Sum(ip.To16())
+	return RefID(binary.BigEndian.Uint32(sum[:4]))
+}
+
+// unicast tells whether the reference ID can be the IPv4 address of an
+// upstream server, the network 0/8, multicast and reserved addresses never
+// being one.
+func (r RefID) unicast() bool {
+	first := byte(r >> 24)
+	return first != 0 && first < 224
+}
+
+// printable tells whether the four bytes are printable ASCII characters.
+func (r RefID) printable() bool {
+	for _, b := range r.Bytes() {
+		if b < 0x20 || b > 0x7E {
+			return false
+		}
+	}
+	return true
+}
+
+// KissCode returns the kiss code of a Kiss-o'-Death, that is a stratum 0
+// packet of modes 1 to 5 whose reference ID holds four printable ASCII
+// characters.
+func (p *Packet) KissCode() (string, bool) {
+	if p.Stratum != 0 || p.Mode == 0 || p.Mode > 5 || !p.RefID.printable() {
+		return "", false
+	}
+	return string(p.RefID.Bytes()), true
+}
+
+// RefIDKind tells how to read the reference ID of the packet from the packet
+// alone. Above stratum 1, loopback addresses and LOCL mean a local clock, and
+// values that cannot be a unicast IPv4 address are taken for IPv6 hashes.
+func (p *Packet) RefIDKind() RefIDKind {
+	if _, ok := p.KissCode(); ok {
+		return RefIDKiss
+	}
+	switch {
+	case p.RefID == 0:
+		return RefIDZero
+	case p.RefID.ASCII() == localRefID:
+		return RefIDLocal
+	case p.Stratum <= 1:
+		return RefIDSource
+	case byte(p.RefID>>24) == 127:
+		return RefIDLocal
+	case !p.RefID.unicast():
+		return RefIDHash
+	default:
+		return RefIDAddress
+	}
+}
+
+func (k RefIDKind) String() string {
+	if k < 0 || int(k) >= len(refIDKindNames) {
+		return "unknown"
+	}
+	return refIDKindNames[k]
+}
+
+// Log2 converts a poll or precision exponent to seconds.
+func Log2(exp int8) float64 {
+	if exp < 0 {
+		return 1 / float64(uint64(1)<<uint(-exp))
+	}
+	return float64(uint64(1) << uint(exp))
+}
@@ -0,0 +1,159 @@
+package packet
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTimestampTime(t *testing.T) {
+	var tests = []struct {
+		ts   Timestamp
+		time time.Time
+	}{
+		{0, time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{0x83AA7E80_00000000, time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{0xE8000000_80000000, time.Date(2023, 5, 5, 22, 21, 52, 500000000, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := tt.ts.Time(); !got.Equal(tt.time) {
+			t.Errorf("Timestamp(%#x).Time() = %v, want %v", uint64(tt.ts), got, tt.time)
+		}
+		if got := NewTimestamp(tt.time); got != tt.ts {
+			t.Errorf("NewTimestamp(%v) = %#x, want %#x", tt.time, uint64(got), uint64(tt.ts))
+		}
+	}
+
+	now := time.Now().UTC()
+	if d := NewTimestamp(now).Time().Sub(now); d < -time.Nanosecond || d > time.Nanosecond {
+		t.Errorf("round trip of %v is off by %v", now, d)
+	}
+}
+
+func TestShortFormat(t *testing.T) {
+	var tests = []struct {
+		s        ShortFormat
+		seconds  float64
+		duration time.Duration
+	}{
+		{0, 0, 0},
+		{0x00010000, 1, time.Second},
+		{0x00008000, 0.5, 500 * time.Millisecond},
+		{0x00000010, 1.0 / 4096, 244140 * time.Nanosecond},
+	}
+	for _, tt := range tests {
+		if got := tt.s.Seconds(); got != tt.seconds {
+			t.Errorf("ShortFormat(%#x).Seconds() = %v, want %v", uint32(tt.s), got, tt.seconds)
+		}
+		if got := tt.s.Duration(); got != tt.duration {
+			t.Errorf("ShortFormat(%#x).Duration() = %v, want %v", uint32(tt.s), got, tt.duration)
+		}
+	}
+}
+
+func TestRefIDKind(t *testing.T) {
+	var tests = []struct {
+		name    string
+		stratum uint8
+		mode    uint8
+		refID   RefID
+		kind    RefIDKind
+		kiss    string
+	}{
+		{"kiss", 0, 4, 0x52415445, RefIDKiss, "RATE"},
+		{"unprintable stratum 0", 0, 4, 0x01020304, RefIDSource, ""},
+		{"control mode", 0, 6, 0x52415445, RefIDSource, ""},
+		{"zero", 3, 4, 0, RefIDZero, ""},
+		{"GPS", 1, 4, 0x47505300, RefIDSource, ""},
+		{"address", 2, 4, 0xC0000201, RefIDAddress, ""},
+		{"LOCL", 1, 4, 0x4C4F434C, RefIDLocal, ""},
+		{"loopback", 3, 4, 0x7F7F0101, RefIDLocal, ""},
+		{"multicast hash", 2, 4, 0xE1A2B3C4, RefIDHash, ""},
+		{"network 0 hash", 2, 4, 0x00A2B3C4, RefIDHash, ""},
+	}
+	for _, tt := range tests {
+		p := &Packet{Stratum: tt.stratum, Mode: tt.mode, RefID: tt.refID}
+		if got := p.RefIDKind(); got != tt.kind {
+			t.Errorf("%s: RefIDKind() = %d, want %d", tt.name, got, tt.kind)
+		}
+		code, ok := p.KissCode()
+		if code != tt.kiss || ok != (tt.kiss != "") {
+			t.Errorf("%s: KissCode() = %q, %v", tt.name, code, ok)
+		}
+	}
+}
+
+func TestRefIDViews(t *testing.T) {
+	if got := RefID(0x47505300).ASCII(); got != "GPS" {
+		t.Errorf("ASCII() = %q, want GPS", got)
+	}
+	if got := RefID(0xC0000201).IP().String(); got != "192.0.2.1" {
+		t.Errorf("IP() = %s, want 192.0.2.1", got)
+	}
+	if got := Log2(-6); got != 1.0/64 {
+		t.Errorf("Log2(-6) = %v", got)
+	}
+}
+
+func TestTimestampTimeNear(t *testing.T) {
+	rollover := time.Date(2036, 2, 7, 6, 28, 16, 0, time.UTC)
+	var tests = []struct {
+		name  string
+		ts    Timestamp
+		pivot time.Time
+		time  time.Time
+		era   int
+		jump  bool
+	}{
+		{"era 0", 0xE8000000_80000000, time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC),
+			time.Date(2023, 5, 5, 22, 21, 52, 500000000, time.UTC), 0, false},
+		{"unset", 0, time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), ntpEpoch, 0, false},
+		{"before rollover read after it", 0xFFFFFFF0_00000000, rollover.Add(10 * time.Second),
+			rollover.Add(-16 * time.Second), 0, false},
+		{"after rollover read before it", 0x00000010_40000000, rollover.Add(-10 * time.Second),
+			rollover.Add(16*time.Second + 250*time.Millisecond), 1, false},
+		{"era 1", 0x12345678_00000000, time.Date(2046, 1, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2045, 10, 12, 5, 19, 52, 0, time.UTC), 1, false},
+		{"wrapped clock", 0x00000005_00000000, time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC),
+			rollover.Add(5 * time.Second), 1, true},
+		{"far behind across the rollover", 0xF0000000_00000000, time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2027, 8, 6, 9, 4, 0, 0, time.UTC), 0, true},
+	}
+	for _, tt := range tests {
+		got := tt.ts.TimeNear(tt.pivot)
+		if !got.Equal(tt.time) {
+			t.Errorf("%s: TimeNear() = %v, want %v", tt.name, got, tt.time)
+		}
+		if era := Era(got); era != tt.era {
+			t.Errorf("%s: Era() = %d, want %d", tt.name, era, tt.era)
+		}
+		if jump := tt.ts.EraJump(tt.pivot); jump != tt.jump {
+			t.Errorf("%s: EraJump() = %v, want %v", tt.name, jump, tt.jump)
+		}
+		if !tt.ts.IsZero() && NewTimestamp(got) != tt.ts {
+			t.Errorf("%s: NewTimestamp() = %#x, want %#x", tt.name, uint64(NewTimestamp(got)), uint64(tt.ts))
+		}
+	}
+
+	if era := Era(rollover.Add(-time.Nanosecond)); era != 0 {
+		t.Errorf("Era() before the rollover = %d, want 0", era)
+	}
+	if era := Era(ntpEpoch.Add(-time.Second)); era != -1 {
+		t.Errorf("Era() before 1900 = %d, want -1", era)
+	}
+}
+
+func TestIPv6RefID(t *testing.T) {
+	var tests = []struct {
+		ip    string
+		refID RefID
+	}{
+		{"2001:db8::1", 0x39AB9B37},
+		{"2001:db8::123", 0xC975CECC},
+	}
+	for _, tt := range tests {
+		if got := IPv6RefID(net.ParseIP(tt.ip)); got != tt.refID {
+			t.Errorf("IPv6RefID(%s) = %08X, want %08X", tt.ip, uint32(got), uint32(tt.refID))
+		}
+	}
+}
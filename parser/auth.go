@@ -0,0 +1,87 @@
+package parser
+
+import (
+	"active/utils"
+	"fmt"
+)
+
+type AuthOutcome int
+
+const (
+	AuthIgnored AuthOutcome = iota
+	AuthAccepted
+	AuthRejected
+	AuthInvalid
+)
+
+// AuthResult is how a host answered a request carrying a key ID and a MAC.
+type AuthResult struct {
+	Outcome  AuthOutcome
+	KeyID    uint32
+	KissCode string
+	Reason   string
+}
+
+// ParseAuth classifies the reply to an authenticated request sent with key,
+// nil data meaning the host did not answer. A host accepts authentication
+// when its reply carries a MAC that verifies with the key, rejects it with a
+// crypto-NAK or an authentication kiss, and ignores it when the reply has no
+// MAC at all.
+func ParseAuth(data []byte, key *utils.Key) *AuthResult {
+	res := &AuthResult{}
+	if len(data) < HeaderLength {
+		res.Reason = "no reply"
+		return res
+	}
+	if code, ok := utils.KissCode(data); ok {
+		res.KissCode = code
+		if code == "CRYP" || code == "AUTH" || code == "NKEY" {
+			res.Outcome = AuthRejected
+			res.Reason = fmt.Sprintf("Kiss-o'-Death %s (%s)", code, utils.KissCodeMeaning(code))
+			return res
+		}
+	}
+	h, _ := ParseTrailer(data)
+	switch {
+	case h.TrailerErr != nil:
+		res.Outcome = AuthInvalid
+		res.Reason = h.TrailerErr.Error()
+	case h.MAC == nil:
+		res.Outcome = AuthIgnored
+		res.Reason = "reply without MAC"
+	case h.MAC.IsCryptoNAK():
+		res.Outcome = AuthRejected
+		res.Reason = "crypto-NAK"
+	default:
+		res.KeyID = h.MAC.KeyID
+		macLen := 4 + len(h.MAC.Digest)
+		if h.MAC.KeyID != key.ID {
+			res.Outcome = AuthInvalid
+			res.Reason = fmt.Sprintf("reply signed with key %d instead of %d", h.MAC.KeyID, key.ID)
+		} else if key.Verify(data[:len(data)-macLen], h.MAC.Digest) {
+			res.Outcome = AuthAccepted
+			res.Reason = fmt.Sprintf("MAC verified with %s key %d", key.Type, key.ID)
+		} else {
+			res.Outcome = AuthInvalid
+			res.Reason = fmt.Sprintf("MAC does not verify with %s key %d", key.Type, key.ID)
+		}
+	}
+	return res
+}
+
+func (r *AuthResult) String() string {
+	switch r.Outcome {
+	case AuthAccepted:
+		return "accepts"
+	case AuthRejected:
+		return "rejects"
+	case AuthInvalid:
+		return "invalid MAC"
+	default:
+		return "ignores"
+	}
+}
+
+func (r *AuthResult) Lines() string {
+	return fmt.Sprintf("Authentication:  %s (%s)\n\n\n", r.String(), r.Reason)
+}
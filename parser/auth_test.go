@@ -0,0 +1,40 @@
+package parser
+
+import (
+	"active/utils"
+	"testing"
+)
+
+func signed(key *utils.Key, keyID uint32) []byte {
+	data := make([]byte, HeaderLength)
+	data[0], data[1] = 0x24, 2
+	return append(data, append(mac(keyID, 0), key.Digest(data)...)...)
+}
+
+func TestParseAuth(t *testing.T) {
+	key := &utils.Key{ID: 5, Type: utils.KeySHA1, Secret: []byte("secret")}
+	corrupted := signed(key, 5)
+	corrupted[len(corrupted)-1] ^= 0xFF
+	kiss := make([]byte, HeaderLength)
+	kiss[0] = 0x24
+	copy(kiss[12:16], "CRYP")
+
+	var tests = []struct {
+		name string
+		data []byte
+		want AuthOutcome
+	}{
+		{"no reply", nil, AuthIgnored},
+		{"no MAC", join(), AuthIgnored},
+		{"verified", signed(key, 5), AuthAccepted},
+		{"crypto-NAK", join(mac(0, 0)), AuthRejected},
+		{"CRYP kiss", kiss, AuthRejected},
+		{"corrupted", corrupted, AuthInvalid},
+		{"other key", signed(key, 6), AuthInvalid},
+	}
+	for _, tt := range tests {
+		if got := ParseAuth(tt.data, key); got.Outcome != tt.want {
+			t.Errorf("%s: ParseAuth() = %s (%s), want %d", tt.name, got, got.Reason, tt.want)
+		}
+	}
+}
@@ -0,0 +1,545 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	ControlHeaderLength = 12
+	controlMaxData      = 468
+)
+
+const (
+	ReadStatOpcode = iota + 1
+	ReadVarOpcode
+	ReadMRUOpcode  = 10
+	ReqNonceOpcode = 12
+)
+
+const (
+	controlResponseBit = 0b10000000
+	controlErrorBit    = 0b01000000
+	controlMoreBit     = 0b00100000
+	controlOpcodeMask  = 0b00011111
+)
+
+var (
+	controlErrorNames = []string{
+		"Unspecified", "Authentication failure", "Invalid message length or format",
+		"Invalid opcode", "Unknown association identifier", "Unknown variable name",
+		"Invalid variable value", "Administratively prohibited",
+	}
+	clockSourceNames = []string{
+		"Unspecified or unknown", "Calibrated atomic clock", "VLF or LF radio", "HF radio",
+		"UHF satellite", "Local net", "UDP/NTP", "UDP/TIME", "Eyeball-and-wristwatch", "Telephone modem",
+	}
+	systemEventNames = []string{
+		"Unspecified", "Frequency correction drift", "Frequency correction file not found",
+		"Frequency set from file", "Spike detected", "Frequency set from measured",
+		"Frequency stepped", "Clock stepped", "Panic exit", "No system peer", "Leap second armed",
+		"Leap second disarmed", "Leap second inserted", "Clock stepped", "Kernel info", "Clock tick",
+	}
+)
+
+// UnknownVariableError is the mode 6 error code ntpd also returns for a stale nonce
+const UnknownVariableError = 5
+
+// ControlResponse is a mode 6 response reassembled from its fragments.
+type ControlResponse struct {
+	Version  int
+	Opcode   int
+	Sequence int
+	Status   uint16
+	AssocID  int
+	ErrCode  int
+	Data     []byte
+}
+
+type Variable struct {
+	Name  string
+	Value string
+}
+
+type SystemStatus struct {
+	Leap        int
+	ClockSource int
+	EventCount  int
+	EventCode   int
+}
+
+// SystemVariables are the variables of association 0 returned by READVAR.
+type SystemVariables struct {
+	Status    SystemStatus
+	Version   string
+	Processor string
+	System    string
+	Leap      int
+	Stratum   int
+	Precision int
+	RootDelay float64
+	RootDisp  float64
+	RefID     string
+	RefTime   string
+	Clock     string
+	Peer      int
+	TC        int
+	MinTC     int
+	Offset    float64
+	Frequency float64
+	SysJitter float64
+	ClkJitter float64
+	ClkWander float64
+	Others    []Variable
+}
+
+// NewControlRequest builds a mode 6 request, the data is padded to a multiple of 4 bytes.
+func NewControlRequest(opcode, sequence, assocID int, data []byte) []byte {
+	n := ControlHeaderLength + len(data)
+	if n%4 != 0 {
+		n += 4 - n%4
+	}
+	req := make([]byte, n)
+	// LI = 0, VN = 2, Mode = 6
+	req[0] = 0x16
+	req[1] = byte(opcode) & controlOpcodeMask
+	binary.BigEndian.PutUint16(req[2:4], uint16(sequence))
+	binary.BigEndian.PutUint16(req[6:8], uint16(assocID))
+	binary.BigEndian.PutUint16(req[10:12], uint16(len(data)))
+	copy(req[ControlHeaderLength:], data)
+	return req
+}
+
+// IsLastFragment tells whether a single mode 6 datagram has its More bit cleared.
+func IsLastFragment(data []byte) bool {
+	return len(data) >= ControlHeaderLength && data[1]&controlMoreBit == 0
+}
+
+// ControlComplete reports whether the fragments cover the whole response.
+func ControlComplete(fragments [][]byte) bool {
+	_, err := ParseControlResponse(fragments)
+	return err == nil
+}
+
+// ParseControlResponse reassembles the fragments of one mode 6 response, in
+// any order, and checks that none is missing.
+func ParseControlResponse(fragments [][]byte) (*ControlResponse, error) {
+	if len(fragments) == 0 {
+		return nil, errors.New("no mode 6 fragment received")
+	}
+	type piece struct {
+		offset int
+		data   []byte
+		last   bool
+	}
+	pieces := make([]piece, 0, len(fragments))
+	var res *ControlResponse
+	for _, f := range fragments {
+		if len(f) < ControlHeaderLength {
+			return nil, fmt.Errorf("mode 6 header length %d less than %d", len(f), ControlHeaderLength)
+		}
+		if f[0]&0b00000111 != controlMessageMode {
+			return nil, fmt.Errorf("wrong mode number: %d", f[0]&0b00000111)
+		}
+		if f[1]&controlResponseBit == 0 {
+			return nil, errors.New("mode 6 packet is not a response")
+		}
+		status := binary.BigEndian.Uint16(f[4:6])
+		if f[1]&controlErrorBit != 0 {
+			code := int(status >> 8)
+			return &ControlResponse{
+				Version: int(f[0]&0b00111000) >> 3,
+				Opcode:  int(f[1] & controlOpcodeMask),
+				ErrCode: code,
+			}, fmt.Errorf("mode 6 error response: %s", controlErrorName(code))
+		}
+		offset := int(binary.BigEndian.Uint16(f[8:10]))
+		count := int(binary.BigEndian.Uint16(f[10:12]))
+		if ControlHeaderLength+count > len(f) {
+			return nil, fmt.Errorf("mode 6 count %d exceeds datagram length %d", count, len(f))
+		}
+		if res == nil {
+			res = &ControlResponse{
+				Version:  int(f[0]&0b00111000) >> 3,
+				Opcode:   int(f[1] & controlOpcodeMask),
+				Sequence: int(binary.BigEndian.Uint16(f[2:4])),
+				Status:   status,
+				AssocID:  int(binary.BigEndian.Uint16(f[6:8])),
+			}
+		}
+		pieces = append(pieces, piece{
+			offset: offset,
+			data:   f[ControlHeaderLength : ControlHeaderLength+count],
+			last:   f[1]&controlMoreBit == 0,
+		})
+	}
+
+	sort.Slice(pieces, func(i, j int) bool {
+		return pieces[i].offset < pieces[j].offset
+	})
+	buf := new(bytes.Buffer)
+	complete := false
+	for _, p := range pieces {
+		if p.offset < buf.Len() {
+			// Duplicate fragment
+			continue
+		}
+		if p.offset > buf.Len() {
+			return nil, fmt.Errorf("mode 6 fragment missing at offset %d", buf.Len())
+		}
+		buf.Write(p.data)
+		if p.last {
+			complete = true
+			break
+		}
+	}
+	if !complete {
+		return nil, errors.New("last mode 6 fragment missing")
+	}
+	res.Data = buf.Bytes()
+	return res, nil
+}
+
+// ParseVariables splits the text of a READVAR response into its
+// comma-separated name=value pairs, keeping the order and unquoting values.
+func ParseVariables(data []byte) []Variable {
+	res := make([]Variable, 0)
+	text := string(data)
+	for len(text) > 0 {
+		text = strings.TrimLeft(text, " ,\r\n\t")
+		if len(text) == 0 {
+			break
+		}
+		end := 0
+		quoted := false
+		for end < len(text) {
+			c := text[end]
+			if c == '"' {
+				quoted = !quoted
+			} else if c == ',' && !quoted {
+				break
+			}
+			end++
+		}
+		item := strings.TrimSpace(text[:end])
+		text = text[end:]
+		if item == "" {
+			continue
+		}
+		name, value := item, ""
+		if i := strings.IndexByte(item, '='); i >= 0 {
+			name, value = strings.TrimSpace(item[:i]), strings.TrimSpace(item[i+1:])
+		}
+		if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+			value = value[1 : len(value)-1]
+		}
+		res = append(res, Variable{Name: name, Value: value})
+	}
+	return res
+}
+
+func ParseSystemStatus(status uint16) SystemStatus {
+	return SystemStatus{
+		Leap:        int(status >> 14),
+		ClockSource: int(status>>8) & 0x3F,
+		EventCount:  int(status>>4) & 0x0F,
+		EventCode:   int(status) & 0x0F,
+	}
+}
+
+// ParseSystemVariables parses the READVAR response of association 0.
+func ParseSystemVariables(res *ControlResponse) (*SystemVariables, error) {
+	if res.Opcode != ReadVarOpcode {
+		return nil, fmt.Errorf("unexpected mode 6 opcode %d, want READVAR", res.Opcode)
+	}
+	if res.AssocID != 0 {
+		return nil, fmt.Errorf("unexpected association %d, want 0", res.AssocID)
+	}
+	sv := &SystemVariables{Status: ParseSystemStatus(res.Status)}
+	for _, v := range ParseVariables(res.Data) {
+		var err error
+		switch v.Name {
+		case "version":
+			sv.Version = v.Value
+		case "processor":
+			sv.Processor = v.Value
+		case "system":
+			sv.System = v.Value
+		case "leap":
+			sv.Leap, err = strconv.Atoi(v.Value)
+		case "stratum":
+			sv.Stratum, err = strconv.Atoi(v.Value)
+		case "precision":
+			sv.Precision, err = strconv.Atoi(v.Value)
+		case "rootdelay":
+			sv.RootDelay, err = strconv.ParseFloat(v.Value, 64)
+		case "rootdisp", "rootdispersion":
+			sv.RootDisp, err = strconv.ParseFloat(v.Value, 64)
+		case "refid":
+			sv.RefID = v.Value
+		case "reftime":
+			sv.RefTime = v.Value
+		case "clock":
+			sv.Clock = v.Value
+		case "peer":
+			sv.Peer, err = strconv.Atoi(v.Value)
+		case "tc":
+			sv.TC, err = strconv.Atoi(v.Value)
+		case "mintc":
+			sv.MinTC, err = strconv.Atoi(v.Value)
+		case "offset":
+			sv.Offset, err = strconv.ParseFloat(v.Value, 64)
+		case "frequency":
+			sv.Frequency, err = strconv.ParseFloat(v.Value, 64)
+		case "sys_jitter", "jitter":
+			sv.SysJitter, err = strconv.ParseFloat(v.Value, 64)
+		case "clk_jitter":
+			sv.ClkJitter, err = strconv.ParseFloat(v.Value, 64)
+		case "clk_wander", "stability":
+			sv.ClkWander, err = strconv.ParseFloat(v.Value, 64)
+		default:
+			sv.Others = append(sv.Others, v)
+		}
+		if err != nil {
+			// Keep what cannot be typed instead of failing the whole response
+			sv.Others = append(sv.Others, v)
+		}
+	}
+	return sv, nil
+}
+
+func (s SystemStatus) Lines() string {
+	return fmt.Sprintf("System Status:   leap=%d, source=%s, events=%d, last event=%s\n",
+		s.Leap, indexedName(clockSourceNames, s.ClockSource), s.EventCount, indexedName(systemEventNames, s.EventCode))
+}
+
+func (sv *SystemVariables) Lines() string {
+	buf := new(bytes.Buffer)
+	buf.WriteString(sv.Status.Lines())
+	buf.WriteString(fmt.Sprintf("Version:   %s\nProcessor: %s\nSystem:    %s\n", sv.Version, sv.Processor, sv.System))
+	buf.WriteString(fmt.Sprintf("Leap:      %d\nStratum:   %d\nPrecision: %d\n", sv.Leap, sv.Stratum, sv.Precision))
+	buf.WriteString(fmt.Sprintf("Root Delay:      %.3f ms\nRoot Dispersion: %.3f ms\n", sv.RootDelay, sv.RootDisp))
+	buf.WriteString(fmt.Sprintf("Reference ID:    %s\nReference Time:  %s\nClock:           %s\n", sv.RefID, sv.RefTime, sv.Clock))
+	buf.WriteString(fmt.Sprintf("Peer:      %d\nTC:        %d\nMin TC:    %d\n", sv.Peer, sv.TC, sv.MinTC))
+	buf.WriteString(fmt.Sprintf("Offset:    %.3f ms\nFrequency: %.3f ppm\n", sv.Offset, sv.Frequency))
+	buf.WriteString(fmt.Sprintf("Jitter:    sys %.3f ms, clock %.3f ms, wander %.3f ppm\n", sv.SysJitter, sv.ClkJitter, sv.ClkWander))
+	for _, v := range sv.Others {
+		buf.WriteString(fmt.Sprintf("%s = %s\n", v.Name, v.Value))
+	}
+	buf.WriteString("\n\n")
+	return buf.String()
+}
+
+func controlErrorName(code int) string {
+	return indexedName(controlErrorNames, code)
+}
+
+func indexedName(names []string, i int) string {
+	if i >= 0 && i < len(names) {
+		return names[i]
+	}
+	return fmt.Sprintf("Unknown (%d)", i)
+}
+
+type Association struct {
+	ID     int
+	Status uint16
+}
+
+// ReadStat is the READSTAT response of association 0, listing the
+// associations of the server.
+type ReadStat struct {
+	Status       SystemStatus
+	Associations []Association
+}
+
+func ParseReadStat(res *ControlResponse) (*ReadStat, error) {
+	if res.Opcode != ReadStatOpcode {
+		return nil, fmt.Errorf("unexpected mode 6 opcode %d, want READSTAT", res.Opcode)
+	}
+	if len(res.Data)%4 != 0 {
+		return nil, fmt.Errorf("READSTAT data length %d is not a multiple of 4", len(res.Data))
+	}
+	rs := &ReadStat{
+		Status:       ParseSystemStatus(res.Status),
+		Associations: make([]Association, 0, len(res.Data)/4),
+	}
+	for i := 0; i < len(res.Data); i += 4 {
+		rs.Associations = append(rs.Associations, Association{
+			ID:     int(binary.BigEndian.Uint16(res.Data[i : i+2])),
+			Status: binary.BigEndian.Uint16(res.Data[i+2 : i+4]),
+		})
+	}
+	return rs, nil
+}
+
+func (rs *ReadStat) Lines() string {
+	buf := new(bytes.Buffer)
+	buf.WriteString(rs.Status.Lines())
+	buf.WriteString(fmt.Sprintf("Associations:    %d\n", len(rs.Associations)))
+	for _, a := range rs.Associations {
+		buf.WriteString(fmt.Sprintf("    %5d  status 0x%04X\n", a.ID, a.Status))
+	}
+	return buf.String()
+}
+
+var peerSelectionNames = []string{
+	"reject", "falsetick", "excess", "outlier", "candidate", "backup", "sys.peer", "pps.peer",
+}
+
+type PeerStatus struct {
+	Configured  bool
+	AuthEnabled bool
+	Authentic   bool
+	Reachable   bool
+	Broadcast   bool
+	Selection   int
+	EventCount  int
+	EventCode   int
+}
+
+// PeerVariables are the variables of one association returned by READVAR.
+type PeerVariables struct {
+	AssocID    int
+	Status     PeerStatus
+	SrcAddr    string
+	SrcPort    int
+	DstAddr    string
+	RefID      string
+	Stratum    int
+	HostMode   int
+	PeerMode   int
+	Reach      int
+	HostPoll   int
+	PeerPoll   int
+	Offset     float64
+	Delay      float64
+	Dispersion float64
+	Jitter     float64
+	Others     []Variable
+}
+
+func ParsePeerStatus(status uint16) PeerStatus {
+	return PeerStatus{
+		Configured:  status&0x8000 != 0,
+		AuthEnabled: status&0x4000 != 0,
+		Authentic:   status&0x2000 != 0,
+		Reachable:   status&0x1000 != 0,
+		Broadcast:   status&0x0800 != 0,
+		Selection:   int(status>>8) & 0x07,
+		EventCount:  int(status>>4) & 0x0F,
+		EventCode:   int(status) & 0x0F,
+	}
+}
+
+func (s PeerStatus) State() string {
+	return indexedName(peerSelectionNames, s.Selection)
+}
+
+// ParsePeerVariables parses the READVAR response of a non-zero association.
+func ParsePeerVariables(res *ControlResponse) (*PeerVariables, error) {
+	if res.Opcode != ReadVarOpcode {
+		return nil, fmt.Errorf("unexpected mode 6 opcode %d, want READVAR", res.Opcode)
+	}
+	if res.AssocID == 0 {
+		return nil, errors.New("association 0 holds system variables, not peer variables")
+	}
+	pv := &PeerVariables{AssocID: res.AssocID, Status: ParsePeerStatus(res.Status)}
+	for _, v := range ParseVariables(res.Data) {
+		var err error
+		switch v.Name {
+		case "srcadr":
+			pv.SrcAddr = v.Value
+		case "srcport":
+			pv.SrcPort, err = strconv.Atoi(v.Value)
+		case "dstadr":
+			pv.DstAddr = v.Value
+		case "refid":
+			pv.RefID = v.Value
+		case "stratum":
+			pv.Stratum, err = strconv.Atoi(v.Value)
+		case "hmode":
+			pv.HostMode, err = strconv.Atoi(v.Value)
+		case "pmode":
+			pv.PeerMode, err = strconv.Atoi(v.Value)
+		case "reach":
+			pv.Reach, err = parseReach(v.Value)
+		case "hpoll":
+			pv.HostPoll, err = strconv.Atoi(v.Value)
+		case "ppoll":
+			pv.PeerPoll, err = strconv.Atoi(v.Value)
+		case "offset":
+			pv.Offset, err = strconv.ParseFloat(v.Value, 64)
+		case "delay":
+			pv.Delay, err = strconv.ParseFloat(v.Value, 64)
+		case "dispersion", "rootdisp":
+			pv.Dispersion, err = strconv.ParseFloat(v.Value, 64)
+		case "jitter":
+			pv.Jitter, err = strconv.ParseFloat(v.Value, 64)
+		default:
+			pv.Others = append(pv.Others, v)
+		}
+		if err != nil {
+			pv.Others = append(pv.Others, v)
+		}
+	}
+	return pv, nil
+}
+
+func (pv *PeerVariables) Lines() string {
+	return fmt.Sprintf("%5d  %-40s %-16s %2d  %-9s reach %03o  offset %9.3f  delay %8.3f  jitter %7.3f\n",
+		pv.AssocID, pv.SrcAddr, pv.RefID, pv.Stratum, pv.Status.State(), pv.Reach, pv.Offset, pv.Delay, pv.Jitter)
+}
+
+// PeerGraph holds the upstream peers of one server, each association being
+// an edge from Host to the peer address.
+type PeerGraph struct {
+	Host   string
+	System *SystemVariables
+	Peers  []*PeerVariables
+}
+
+func (g *PeerGraph) Lines() string {
+	buf := new(bytes.Buffer)
+	if g.System != nil {
+		buf.WriteString(fmt.Sprintf("Host:      %s (stratum %d, refid %s)\n", g.Host, g.System.Stratum, g.System.RefID))
+	} else {
+		buf.WriteString(fmt.Sprintf("Host:      %s\n", g.Host))
+	}
+	buf.WriteString(fmt.Sprintf("Peers:     %d\n", len(g.Peers)))
+	for _, pv := range g.Peers {
+		buf.WriteString(pv.Lines())
+	}
+	buf.WriteString("\n\n")
+	return buf.String()
+}
+
+// EdgeLines returns one CSV line per association: host, peer address, peer
+// refid, peer stratum, selection state, reach and offset.
+func (g *PeerGraph) EdgeLines() string {
+	buf := new(bytes.Buffer)
+	for _, pv := range g.Peers {
+		buf.WriteString(fmt.Sprintf("%s,%s,%s,%d,%s,%d,%.3f\n",
+			g.Host, pv.SrcAddr, pv.RefID, pv.Stratum, pv.Status.State(), pv.Reach, pv.Offset))
+	}
+	return buf.String()
+}
+
+// parseReach reads the reach register, printed in hexadecimal with a 0x
+// prefix by recent servers and in octal, with or without a leading zero, by
+// older ones.
+func parseReach(s string) (int, error) {
+	var reach uint64
+	var err error
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		reach, err = strconv.ParseUint(s[2:], 16, 8)
+	} else {
+		reach, err = strconv.ParseUint(s, 8, 8)
+	}
+	return int(reach), err
+}
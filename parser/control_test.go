@@ -0,0 +1,94 @@
+package parser
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func controlFragment(opcode, offset int, more bool, data string) []byte {
+	f := make([]byte, ControlHeaderLength+len(data))
+	f[0] = 0x16
+	f[1] = 0x80 | byte(opcode)
+	if more {
+		f[1] |= controlMoreBit
+	}
+	binary.BigEndian.PutUint16(f[2:4], 7)
+	binary.BigEndian.PutUint16(f[4:6], 0x0615)
+	binary.BigEndian.PutUint16(f[8:10], uint16(offset))
+	binary.BigEndian.PutUint16(f[10:12], uint16(len(data)))
+	copy(f[ControlHeaderLength:], data)
+	return f
+}
+
+func TestParseControlResponse(t *testing.T) {
+	first := `version="ntpd 4.2.8p15@1.3728-o", processor="x86_64", `
+	second := `system="Linux/5.4.0", leap=00, stratum=2, rootdelay=1.234, refid=10.0.0.1, extra="a, b"`
+	var tests = []struct {
+		fragments [][]byte
+		complete  bool
+	}{
+		{[][]byte{controlFragment(ReadVarOpcode, 0, false, first+second)}, true},
+		{[][]byte{
+			controlFragment(ReadVarOpcode, len(first), false, second),
+			controlFragment(ReadVarOpcode, 0, true, first),
+		}, true},
+		{[][]byte{controlFragment(ReadVarOpcode, 0, true, first)}, false},
+		{[][]byte{controlFragment(ReadVarOpcode, len(first), false, second)}, false},
+	}
+	for _, test := range tests {
+		res, err := ParseControlResponse(test.fragments)
+		if (err == nil) != test.complete {
+			t.Errorf("ParseControlResponse() error = %v, want complete %v", err, test.complete)
+			continue
+		}
+		if !test.complete {
+			continue
+		}
+		sv, err := ParseSystemVariables(res)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if sv.Version != "ntpd 4.2.8p15@1.3728-o" || sv.System != "Linux/5.4.0" || sv.Stratum != 2 ||
+			sv.RootDelay != 1.234 || sv.RefID != "10.0.0.1" {
+			t.Errorf("ParseSystemVariables() = %+v", sv)
+		}
+		if len(sv.Others) != 1 || sv.Others[0].Value != "a, b" {
+			t.Errorf("ParseSystemVariables() others = %v", sv.Others)
+		}
+		t.Log(sv.Lines())
+	}
+}
+
+func TestParsePeerVariables(t *testing.T) {
+	var tests = []struct {
+		status uint16
+		reach  string
+		state  string
+		want   int
+	}{
+		{0x9614, "0xff", "sys.peer", 0xff},
+		{0x9414, "377", "candidate", 0xff},
+		{0x8011, "0x1", "reject", 1},
+		{0x9414, "177", "candidate", 0x7f},
+		{0x9414, "017", "candidate", 0x0f},
+	}
+	for _, test := range tests {
+		f := controlFragment(ReadVarOpcode, 0, false, "srcadr=192.0.2.1, refid=GPS, stratum=1, reach="+test.reach)
+		binary.BigEndian.PutUint16(f[4:6], test.status)
+		binary.BigEndian.PutUint16(f[6:8], 3)
+		res, err := ParseControlResponse([][]byte{f})
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		pv, err := ParsePeerVariables(res)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if pv.Status.State() != test.state || pv.Reach != test.want || pv.SrcAddr != "192.0.2.1" || pv.Stratum != 1 {
+			t.Errorf("ParsePeerVariables(0x%04X, %s) = %+v", test.status, test.reach, pv)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package parser
+
+import (
+	"active/packet"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+)
+
+const (
+	keyIDLength          = 4
+	cryptoNAKLength      = 4
+	md5MACLength         = 20
+	sha1MACLength        = 24
+	msSNTPExtendedLength = 72
+)
+
+var extensionNames = map[uint16]string{
+	0x0104: "Unique Identifier",
+	0x0204: "NTS Cookie",
+	0x0304: "NTS Cookie Placeholder",
+	0x0404: "NTS Authenticator and Encrypted Extension Fields",
+	0x2005: "Checksum Complement",
+}
+
+// ExtensionField is an RFC 7822 extension field following the header.
+type ExtensionField struct {
+	Type   uint16
+	Length int
+	Value  []byte
+}
+
+// MAC is the authenticator closing a packet: a key ID followed by a digest,
+// or the key ID alone for a crypto-NAK.
+type MAC struct {
+	KeyID     uint32
+	Digest    []byte
+	Algorithm string
+	// MSSNTP is set for the Microsoft MS-SNTP extended authenticator, whose
+	// flags and hash IDs sit between the key ID and the digest.
+	MSSNTP    bool
+	Flags     byte
+	HashHints byte
+	HashID    byte
+}
+
+func (e ExtensionField) Name() string {
+	if name, ok := extensionNames[e.Type]; ok {
+		return name
+	}
+	return "Unknown"
+}
+
+// IsCryptoNAK tells whether the MAC is a bare zero key ID, the answer of a
+// server that could not authenticate the request.
+func (m *MAC) IsCryptoNAK() bool {
+	return len(m.Digest) == 0
+}
+
+// parseTrailer walks the extension fields after the header and recognizes
+// the MAC at the end. A malformed trailer is kept in TrailerErr rather than
+// failing the whole header.
+func parseTrailer(data []byte, h *Header) {
+	p, err := packet.Unmarshal(data)
+	setTrailer(h, p, err)
+}
+
+// setTrailer fills the extension fields and the MAC of the header from the
+// decoded packet and the error of decoding it.
+func setTrailer(h *Header, p *packet.Packet, err error) {
+	if p == nil {
+		h.TrailerErr = err
+		return
+	}
+	for _, e := range p.Extensions {
+		h.Extensions = append(h.Extensions, ExtensionField{Type: e.Type, Length: e.Len(), Value: e.Value})
+	}
+	if err != nil {
+		h.TrailerErr = err
+		return
+	}
+	if p.MAC != nil {
+		h.MAC = newMAC(p.MAC)
+	}
+}
+
+func newMAC(m *packet.MAC) *MAC {
+	res := &MAC{KeyID: m.KeyID, Digest: m.Digest}
+	switch keyIDLength + len(m.Digest) {
+	case cryptoNAKLength:
+		res.Algorithm = "crypto-NAK"
+	case md5MACLength:
+		res.Algorithm = "MD5, AES-128-CMAC or MS-SNTP"
+	case sha1MACLength:
+		res.Algorithm = "SHA1"
+	case msSNTPExtendedLength:
+		// Reserved byte, flags, client hash ID hints and signature hash ID
+		// come before the checksum
+		res.Algorithm = "MS-SNTP extended"
+		res.MSSNTP = true
+		res.Flags, res.HashHints, res.HashID = m.Digest[1], m.Digest[2], m.Digest[3]
+		res.Digest = m.Digest[4:]
+	}
+	return res
+}
+
+// trailerLines describes the extension fields and the MAC, empty when the
+// packet ends with the header.
+func (h *Header) trailerLines() string {
+	buf := new(bytes.Buffer)
+	for i, e := range h.Extensions {
+		buf.WriteString(fmt.Sprintf("Extension %d:     0x%04X %s (%d bytes)\n", i+1, e.Type, e.Name(), e.Length))
+	}
+	if m := h.MAC; m != nil {
+		if m.IsCryptoNAK() {
+			buf.WriteString("MAC:             crypto-NAK\n")
+		} else {
+			buf.WriteString(fmt.Sprintf("MAC:             %s, key ID %d, digest %s\n",
+				m.Algorithm, m.KeyID, hex.EncodeToString(m.Digest)))
+		}
+		if m.MSSNTP {
+			buf.WriteString(fmt.Sprintf("MS-SNTP:         key selector %d, RID %d, flags 0x%02X, hash hints 0x%02X, "+
+				"hash ID 0x%02X\n", m.KeyID>>31, m.KeyID&0x7FFFFFFF, m.Flags, m.HashHints, m.HashID))
+		} else if len(m.Digest) == md5MACLength-keyIDLength {
+			buf.WriteString(fmt.Sprintf("MS-SNTP:         if so, key selector %d, RID %d\n",
+				m.KeyID>>31, m.KeyID&0x7FFFFFFF))
+		}
+	}
+	if h.TrailerErr != nil {
+		buf.WriteString(fmt.Sprintf("Trailer Error:   %v\n", h.TrailerErr))
+	}
+	return buf.String()
+}
+
+// ParseTrailer returns the extension fields and the MAC following the header
+// without parsing the header itself.
+func ParseTrailer(data []byte) (*Header, error) {
+	if len(data) < HeaderLength {
+		return nil, fmt.Errorf("header length %d less than 48", len(data))
+	}
+	h := &Header{}
+	parseTrailer(data, h)
+	return h, nil
+}
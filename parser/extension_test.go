@@ -0,0 +1,74 @@
+package parser
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func extension(typ uint16, length int) []byte {
+	res := make([]byte, length)
+	binary.BigEndian.PutUint16(res[0:2], typ)
+	binary.BigEndian.PutUint16(res[2:4], uint16(length))
+	return res
+}
+
+func mac(keyID uint32, digestLen int) []byte {
+	res := make([]byte, 4+digestLen)
+	binary.BigEndian.PutUint32(res[0:4], keyID)
+	return res
+}
+
+func join(parts ...[]byte) []byte {
+	res := make([]byte, HeaderLength)
+	for _, p := range parts {
+		res = append(res, p...)
+	}
+	return res
+}
+
+func TestParseTrailer(t *testing.T) {
+	var tests = []struct {
+		name       string
+		data       []byte
+		extensions []uint16
+		algorithm  string
+		keyID      uint32
+		wantErr    bool
+	}{
+		{"header only", join(), nil, "", 0, false},
+		{"crypto-NAK", join(mac(0, 0)), nil, "crypto-NAK", 0, false},
+		{"MD5", join(mac(7, 16)), nil, "MD5, AES-128-CMAC or MS-SNTP", 7, false},
+		{"SHA1", join(mac(9, 20)), nil, "SHA1", 9, false},
+		{"NTS", join(extension(0x0104, 36), extension(0x0404, 40)), []uint16{0x0104, 0x0404}, "", 0, false},
+		{"extension and MAC", join(extension(0x2005, 28), mac(3, 16)), []uint16{0x2005}, "MD5, AES-128-CMAC or MS-SNTP", 3, false},
+		{"MS-SNTP extended", join(mac(0x80000457, 68)), nil, "MS-SNTP extended", 0x80000457, false},
+		{"bad length", join(extension(0x0104, 12), make([]byte, 20)), nil, "", 0, true},
+		{"odd trailer", join(make([]byte, 8)), nil, "", 0, true},
+	}
+	for _, tt := range tests {
+		h := &Header{}
+		parseTrailer(tt.data, h)
+		if (h.TrailerErr != nil) != tt.wantErr {
+			t.Errorf("%s: TrailerErr = %v, wantErr %v", tt.name, h.TrailerErr, tt.wantErr)
+			continue
+		}
+		if len(h.Extensions) != len(tt.extensions) {
+			t.Errorf("%s: got %d extensions, want %d", tt.name, len(h.Extensions), len(tt.extensions))
+			continue
+		}
+		for i, e := range h.Extensions {
+			if e.Type != tt.extensions[i] {
+				t.Errorf("%s: extension %d type 0x%04X, want 0x%04X", tt.name, i, e.Type, tt.extensions[i])
+			}
+		}
+		if tt.algorithm == "" {
+			if h.MAC != nil {
+				t.Errorf("%s: unexpected MAC %+v", tt.name, h.MAC)
+			}
+			continue
+		}
+		if h.MAC == nil || h.MAC.Algorithm != tt.algorithm || h.MAC.KeyID != tt.keyID {
+			t.Errorf("%s: MAC = %+v, want %s key %d", tt.name, h.MAC, tt.algorithm, tt.keyID)
+		}
+	}
+}
@@ -1,13 +1,15 @@
 package parser
 
 import (
+	"active/packet"
 	"active/utils"
-	"encoding/binary"
 	"errors"
 	"fmt"
 	"strconv"
 )
 
+// Header is the string view of an NTP header, derived from the typed model
+// in Packet.
 type Header struct {
 	Leap              string
 	Version           string
@@ -22,12 +24,19 @@ type Header struct {
 	OriginTimestamp   string
 	ReceiveTimestamp  string
 	TransmitTimestamp string
+	KissCode          string
+	Extensions        []ExtensionField
+	MAC               *MAC
+	TrailerErr        error
+	Packet            *packet.Packet
+	RefIDClass        *RefIDClass
+	hints             RefIDHints
 }
 
-type stepFunc func([]byte, *Header) error
+type stepFunc func(*packet.Packet, *Header) error
 
 const (
-	HeaderLength = 48
+	HeaderLength = packet.HeaderLength
 )
 
 const (
@@ -47,6 +56,10 @@ const (
 )
 
 var (
+	modeNames = []string{
+		"Reserved", "Symmetric active", "Symmetric passive", "Client",
+		"Server", "Broadcast", "Control message", "Private",
+	}
 	parseChain = []stepFunc{
 		parseLeapIndicator, parseVersion, parseMode, parseStratum, parsePoll,
 		parsePrecision, parseRootDelay, parseRootDisp, parseRefID, parseRefTimestamp,
@@ -55,23 +68,36 @@ var (
 )
 
 func ParseHeader(data []byte) (*Header, error) {
+	return ParseHeaderWithHints(data, RefIDHints{})
+}
+
+// ParseHeaderWithHints parses the header, using what is known of the server
+// to interpret the reference ID.
+func ParseHeaderWithHints(data []byte, hints RefIDHints) (*Header, error) {
 	if len(data) < HeaderLength {
 		for _, b := range data {
 			fmt.Printf("%02X", b)
 		}
 		return nil, errors.New(fmt.Sprintf("header length %d less than 48", len(data)))
 	}
-	res := &Header{}
+	p, trailerErr := packet.Unmarshal(data)
+	res := &Header{Packet: p, hints: hints}
 	var err error
 	for _, handler := range parseChain {
-		err = handler(data, res)
+		err = handler(p, res)
 		if err != nil {
 			return nil, err
 		}
 	}
+	setTrailer(res, p, trailerErr)
 	return res, nil
 }
 
+// IsKoD tells whether the header is a Kiss-o'-Death.
+func (h *Header) IsKoD() bool {
+	return h.KissCode != ""
+}
+
 func (h *Header) Print() {
 	fmt.Print(h.Lines())
 }
@@ -79,13 +105,13 @@ func (h *Header) Print() {
 func (h *Header) Lines() string {
 	return fmt.Sprintf("Leap:    %s\nVersion: %s\nMode:    %s\nStratum: %s\nPoll:    %s\nPrecision:       %s\nRoot "+
 		"Delay:      %s\nRoot Dispersion: %s\nReference ID:    %s\nReference Timestamp: %s\nOrigin Timestamp:    %s\n"+
-		"Receive Timestamp:   %s\nTransmit Timestamp:  %s\n\n\n\n", h.Leap, h.Version, h.Mode, h.Stratum, h.Poll, h.Precision,
-		h.RootDelay, h.RootDisp, h.RefID, h.RefTimestamp, h.OriginTimestamp, h.ReceiveTimestamp, h.TransmitTimestamp)
+		"Receive Timestamp:   %s\nTransmit Timestamp:  %s\n%s\n\n\n", h.Leap, h.Version, h.Mode, h.Stratum, h.Poll, h.Precision,
+		h.RootDelay, h.RootDisp, h.RefID, h.RefTimestamp, h.OriginTimestamp, h.ReceiveTimestamp, h.TransmitTimestamp,
+		h.trailerLines())
 }
 
-func parseLeapIndicator(data []byte, h *Header) error {
-	li := (data[0] & 0b11000000) >> 6
-	switch li {
+func parseLeapIndicator(p *packet.Packet, h *Header) error {
+	switch p.Leap {
 	case normalIndicator:
 		h.Leap = "No warning"
 	case plusSecondIndicator:
@@ -100,18 +126,16 @@ func parseLeapIndicator(data []byte, h *Header) error {
 	return nil
 }
 
-func parseVersion(data []byte, h *Header) error {
-	version := (data[0] & 0b00111000) >> 3
-	if version < 2 || version > 4 {
-		return errors.New(fmt.Sprintf("wrong version number: %d", version))
+func parseVersion(p *packet.Packet, h *Header) error {
+	if p.Version < 1 || p.Version > 4 {
+		return errors.New(fmt.Sprintf("wrong version number: %d", p.Version))
 	}
-	h.Version = fmt.Sprintf("NTPv%d", version)
+	h.Version = fmt.Sprintf("NTPv%d", p.Version)
 	return nil
 }
 
-func parseMode(data []byte, h *Header) error {
-	mode := data[0] & 0b00000111
-	switch mode {
+func parseMode(p *packet.Packet, h *Header) error {
+	switch p.Mode {
 	case symmetricActiveMode:
 		h.Mode = "Symmetric active"
 	case symmetricPassiveMode:
@@ -125,17 +149,23 @@ func parseMode(data []byte, h *Header) error {
 	case controlMessageMode:
 		h.Mode = "Control message"
 	default:
-		return errors.New(fmt.Sprintf("wrong mode number: %d", mode))
+		return errors.New(fmt.Sprintf("wrong mode number: %d", p.Mode))
 	}
 	return nil
 }
 
-func parseStratum(data []byte, h *Header) error {
-	stratum := data[1]
+func ModeName(mode int) string {
+	return indexedName(modeNames, mode)
+}
+
+func parseStratum(p *packet.Packet, h *Header) error {
+	stratum := p.Stratum
 	if stratum > 16 {
 		return errors.New(fmt.Sprintf("wrong stratum number: %d", stratum))
 	}
-	if stratum == 0 {
+	if _, ok := p.KissCode(); ok {
+		h.Stratum = "Kiss-o'-Death"
+	} else if stratum == 0 {
 		h.Stratum = "Not specified"
 	} else if stratum == 16 {
 		h.Stratum = "Clock unsynchronized"
@@ -145,60 +175,66 @@ func parseStratum(data []byte, h *Header) error {
 	return nil
 }
 
-func parsePoll(data []byte, h *Header) error {
-	poll := int8(data[2])
-	h.Poll = utils.FromInt8(poll)
+func parsePoll(p *packet.Packet, h *Header) error {
+	h.Poll = utils.FromInt8(p.Poll)
 	return nil
 }
 
-func parsePrecision(data []byte, h *Header) error {
-	precision := int8(data[3])
-	h.Precision = utils.FromInt8(precision)
+func parsePrecision(p *packet.Packet, h *Header) error {
+	h.Precision = utils.FromInt8(p.Precision)
 	return nil
 }
 
-func parseRootDelay(data []byte, h *Header) error {
-	val := binary.BigEndian.Uint32(data[4:8])
-	floatVal := float64(val) / (1 << 16)
-	h.RootDelay = utils.FormatScientific(floatVal) + " sec"
+func parseRootDelay(p *packet.Packet, h *Header) error {
+	h.RootDelay = utils.FormatScientific(p.RootDelay.Seconds()) + " sec"
 	return nil
 }
 
-func parseRootDisp(data []byte, h *Header) error {
-	val := binary.BigEndian.Uint32(data[8:12])
-	floatVal := float64(val) / (1 << 16)
-	h.RootDisp = utils.FormatScientific(floatVal) + " sec"
+func parseRootDisp(p *packet.Packet, h *Header) error {
+	h.RootDisp = utils.FormatScientific(p.RootDisp.Seconds()) + " sec"
 	return nil
 }
 
-func parseRefID(data []byte, h *Header) error {
-	if h.Stratum == "1" {
+func parseRefID(p *packet.Packet, h *Header) error {
+	c := ClassifyRefID(p, h.hints)
+	h.RefIDClass = c
+	switch {
+	case c.Kind == packet.RefIDKiss:
+		h.KissCode = c.Text
+		h.RefID = c.String()
+	case c.Kind == packet.RefIDSource && p.Stratum == 1:
 		// Special reference identifier
-		h.RefID = completeSource(data[12:16])
-	} else {
+		h.RefID = completeSource(p.RefID.Bytes())
+	case c.Kind == packet.RefIDLocal || c.Kind == packet.RefIDHash:
+		h.RefID = c.String()
+	default:
 		// Normal IP address
-		ipStr := fmt.Sprintf("%d.%d.%d.%d", data[12], data[13], data[14], data[15])
+		ipStr := p.RefID.IP().String()
 		h.RefID = fmt.Sprintf("%s (%s)", ipStr, utils.RegionOf(ipStr))
 	}
 	return nil
 }
 
-func parseRefTimestamp(data []byte, h *Header) error {
-	h.RefTimestamp = utils.FormatTimestamp(data[16:24])
+func parseRefTimestamp(p *packet.Packet, h *Header) error {
+	h.RefTimestamp = formatTimestamp(p.RefTime)
 	return nil
 }
 
-func parseOriginTimestamp(data []byte, h *Header) error {
-	h.OriginTimestamp = utils.FormatTimestamp(data[24:32])
+func parseOriginTimestamp(p *packet.Packet, h *Header) error {
+	h.OriginTimestamp = formatTimestamp(p.OriginTime)
 	return nil
 }
 
-func parseReceiveTimestamp(data []byte, h *Header) error {
-	h.ReceiveTimestamp = utils.FormatTimestamp(data[32:40])
+func parseReceiveTimestamp(p *packet.Packet, h *Header) error {
+	h.ReceiveTimestamp = formatTimestamp(p.ReceiveTime)
 	return nil
 }
 
-func parseTransmitTimestamp(data []byte, h *Header) error {
-	h.TransmitTimestamp = utils.FormatTimestamp(data[40:48])
+func parseTransmitTimestamp(p *packet.Packet, h *Header) error {
+	h.TransmitTimestamp = formatTimestamp(p.TransmitTime)
 	return nil
 }
+
+func formatTimestamp(t packet.Timestamp) string {
+	return utils.FormatTimestamp(t.Bytes())
+}
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"active/datastruct"
 	"active/udpdetect"
 	"fmt"
 	"testing"
@@ -17,6 +18,9 @@ func TestParseHeader(t *testing.T) {
 			fmt.Println(err)
 			continue
 		}
+		if p.Status != datastruct.StatusOpen {
+			continue
+		}
 		data := p.RcvData
 		p.Print()
 		header, err := ParseHeader(data)
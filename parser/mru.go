@@ -0,0 +1,188 @@
+package parser
+
+import (
+	"active/utils"
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MRUEntry is one client of the MRU list of a server.
+type MRUEntry struct {
+	Addr    string
+	Last    string
+	Count   int
+	Mode    int
+	Version int
+}
+
+// MRUPage is the content of one READ_MRU response.
+type MRUPage struct {
+	Entries []MRUEntry
+	Nonce   string
+	// Done is set once the server sent `now`, which ends the list
+	Done bool
+}
+
+// MRUStats are the aggregate statistics kept from an MRU list, the client
+// addresses themselves are not stored.
+type MRUStats struct {
+	Entries   int
+	Clients   int
+	Packets   int
+	Requests  int
+	SendBytes int
+	Responses int
+	Bytes     int
+	Complete  bool
+	Networks  map[string]int
+	Modes     map[int]int
+	Versions  map[int]int
+	// seenEntries holds the addresses with port of the entries counted,
+	// seenHosts the addresses alone of the clients counted
+	seenEntries map[string]struct{}
+	seenHosts   map[string]struct{}
+}
+
+func ParseNonce(res *ControlResponse) (string, error) {
+	for _, v := range ParseVariables(res.Data) {
+		if v.Name == "nonce" {
+			return v.Value, nil
+		}
+	}
+	return "", fmt.Errorf("no nonce in %q", string(res.Data))
+}
+
+// ParseMRUPage collects the addr.N, last.N, ct.N and mv.N variables of a
+// READ_MRU response into entries ordered by N.
+func ParseMRUPage(res *ControlResponse) (*MRUPage, error) {
+	if res.Opcode != ReadMRUOpcode {
+		return nil, fmt.Errorf("unexpected mode 6 opcode %d, want READ_MRU", res.Opcode)
+	}
+	page := new(MRUPage)
+	entries := make(map[int]*MRUEntry)
+	for _, v := range ParseVariables(res.Data) {
+		switch v.Name {
+		case "nonce":
+			page.Nonce = v.Value
+			continue
+		case "now":
+			page.Done = true
+			continue
+		}
+		dot := strings.LastIndexByte(v.Name, '.')
+		if dot < 0 {
+			continue
+		}
+		i, err := strconv.Atoi(v.Name[dot+1:])
+		if err != nil {
+			continue
+		}
+		e, ok := entries[i]
+		if !ok {
+			e = new(MRUEntry)
+			entries[i] = e
+		}
+		switch v.Name[:dot] {
+		case "addr":
+			e.Addr = v.Value
+		case "last":
+			e.Last = v.Value
+		case "ct":
+			e.Count, _ = strconv.Atoi(v.Value)
+		case "mv":
+			mv, err := strconv.ParseUint(v.Value, 0, 8)
+			if err == nil {
+				e.Mode, e.Version = int(mv&0x07), int(mv>>3)&0x07
+			}
+		}
+	}
+	indexes := make([]int, 0, len(entries))
+	for i := range entries {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	for _, i := range indexes {
+		if entries[i].Addr != "" {
+			page.Entries = append(page.Entries, *entries[i])
+		}
+	}
+	return page, nil
+}
+
+func NewMRUStats() *MRUStats {
+	return &MRUStats{
+		Networks:    make(map[string]int),
+		Modes:       make(map[int]int),
+		Versions:    make(map[int]int),
+		seenEntries: make(map[string]struct{}),
+		seenHosts:   make(map[string]struct{}),
+	}
+}
+
+// Add counts an entry, an address already seen on an earlier page is skipped.
+func (s *MRUStats) Add(e MRUEntry) bool {
+	if _, ok := s.seenEntries[e.Addr]; ok {
+		return false
+	}
+	s.seenEntries[e.Addr] = struct{}{}
+	s.Entries++
+	s.Packets += e.Count
+	s.Modes[e.Mode]++
+	s.Versions[e.Version]++
+
+	host, _, err := net.SplitHostPort(e.Addr)
+	if err != nil {
+		host = e.Addr
+	}
+	if _, ok := s.seenHosts[host]; !ok {
+		s.seenHosts[host] = struct{}{}
+		s.Clients++
+		s.Networks[utils.NetworkOf(host)]++
+	}
+	return true
+}
+
+func (s *MRUStats) Lines() string {
+	buf := new(bytes.Buffer)
+	buf.WriteString(fmt.Sprintf("Complete:  %v\nEntries:   %d\nClients:   %d\nPackets:   %d\n",
+		s.Complete, s.Entries, s.Clients, s.Packets))
+	buf.WriteString(fmt.Sprintf("Requests:  %d (%d bytes)\nResponses: %d (%d bytes)\n",
+		s.Requests, s.SendBytes, s.Responses, s.Bytes))
+	buf.WriteString("Modes:\n")
+	for _, m := range sortedKeys(s.Modes) {
+		buf.WriteString(fmt.Sprintf("    %-20s %d\n", ModeName(m), s.Modes[m]))
+	}
+	buf.WriteString("Versions:\n")
+	for _, v := range sortedKeys(s.Versions) {
+		buf.WriteString(fmt.Sprintf("    %-20d %d\n", v, s.Versions[v]))
+	}
+	buf.WriteString(fmt.Sprintf("Networks:  %d\n", len(s.Networks)))
+	nets := make([]string, 0, len(s.Networks))
+	for n := range s.Networks {
+		nets = append(nets, n)
+	}
+	sort.Slice(nets, func(i, j int) bool {
+		if s.Networks[nets[i]] != s.Networks[nets[j]] {
+			return s.Networks[nets[i]] > s.Networks[nets[j]]
+		}
+		return nets[i] < nets[j]
+	})
+	for _, n := range nets {
+		buf.WriteString(fmt.Sprintf("    %-20s %d\n", n, s.Networks[n]))
+	}
+	buf.WriteString("\n\n")
+	return buf.String()
+}
+
+func sortedKeys(m map[int]int) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
@@ -0,0 +1,43 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestParseMRUPage(t *testing.T) {
+	var tests = []struct {
+		data    string
+		entries int
+		nonce   string
+		done    bool
+	}{
+		{"addr.1=10.0.0.2:123, ct.1=1, mv.1=0x1b, addr.0=10.0.0.1:123, ct.0=5, mv.0=0x23, nonce=def", 2, "def", false},
+		{"addr.0=[2001:db8::1]:123, ct.0=1, mv.0=0x23, now=0x2", 1, "", true},
+		{"addr.1=10.0.0.3, ct.1=2, mv.1=0x1b, addr.0=10.0.0.1:123, ct.0=5, mv.0=0x23, now=0x2", 2, "", true},
+		{"now=0x2", 0, "", true},
+	}
+	stats := NewMRUStats()
+	for _, test := range tests {
+		f := controlFragment(ReadMRUOpcode, 0, false, test.data)
+		res, err := ParseControlResponse([][]byte{f})
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		page, err := ParseMRUPage(res)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if len(page.Entries) != test.entries || page.Nonce != test.nonce || page.Done != test.done {
+			t.Errorf("ParseMRUPage(%s) = %+v", test.data, page)
+		}
+		for _, e := range page.Entries {
+			stats.Add(e)
+		}
+	}
+	if stats.Entries != 4 || stats.Clients != 4 || stats.Packets != 9 || stats.Networks["10.0.0.0/24"] != 3 ||
+		stats.Modes[3] != 4 || stats.Versions[3] != 2 {
+		t.Errorf("MRUStats = %+v", stats)
+	}
+}
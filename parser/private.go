@@ -0,0 +1,141 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	PrivateHeaderLength  = 8
+	privateRequestLength = 48
+	privateMode          = 7
+)
+
+// Implementation numbers of mode 7 requests
+const (
+	ImplUniv     = 0
+	ImplXNTPDOld = 2
+	ImplXNTPD    = 3
+)
+
+// Request codes of the monitor list
+const (
+	MonGetList  = 20
+	MonGetList1 = 42
+)
+
+var privateErrorNames = []string{
+	"No error", "Incompatible implementation number", "Unimplemented request code",
+	"Format error", "No data available", "Unknown", "Unknown", "Authentication failure",
+}
+
+type PrivateHeader struct {
+	Response bool
+	More     bool
+	Version  int
+	Sequence int
+	Impl     int
+	ReqCode  int
+	ErrCode  int
+	NItems   int
+	ItemSize int
+}
+
+// Monlist sums up the mode 7 response to a monitor list request.
+type Monlist struct {
+	Impl     int
+	ReqCode  int
+	ErrCode  int
+	SendLen  int
+	Packets  int
+	Bytes    int
+	Items    int
+	ItemSize int
+}
+
+// NewMonlistRequest builds the 48-byte MON_GETLIST or MON_GETLIST_1 request
+// for the given implementation, the same that ntpdc sends.
+func NewMonlistRequest(impl, reqCode int) []byte {
+	req := make([]byte, privateRequestLength)
+	// R = 0, M = 0, VN = 2, Mode = 7
+	req[0] = 0x17
+	req[2] = byte(impl)
+	req[3] = byte(reqCode)
+	return req
+}
+
+func ParsePrivateHeader(data []byte) (*PrivateHeader, error) {
+	if len(data) < PrivateHeaderLength {
+		return nil, fmt.Errorf("mode 7 header length %d less than %d", len(data), PrivateHeaderLength)
+	}
+	if data[0]&0b00000111 != privateMode {
+		return nil, fmt.Errorf("wrong mode number: %d", data[0]&0b00000111)
+	}
+	return &PrivateHeader{
+		Response: data[0]&0b10000000 != 0,
+		More:     data[0]&0b01000000 != 0,
+		Version:  int(data[0]&0b00111000) >> 3,
+		Sequence: int(data[1] & 0b01111111),
+		Impl:     int(data[2]),
+		ReqCode:  int(data[3]),
+		ErrCode:  int(data[4] >> 4),
+		NItems:   int(binary.BigEndian.Uint16(data[4:6]) & 0x0FFF),
+		ItemSize: int(binary.BigEndian.Uint16(data[6:8]) & 0x0FFF),
+	}, nil
+}
+
+// ParseMonlist counts the packets, bytes and items of the response to a
+// monitor list request of sendLen bytes, datagrams that are not mode 7
+// responses are ignored.
+func ParseMonlist(datagrams [][]byte, sendLen int) (*Monlist, error) {
+	var m *Monlist
+	for _, d := range datagrams {
+		h, err := ParsePrivateHeader(d)
+		if err != nil || !h.Response {
+			continue
+		}
+		if m == nil {
+			m = &Monlist{Impl: h.Impl, ReqCode: h.ReqCode, ErrCode: h.ErrCode, SendLen: sendLen, ItemSize: h.ItemSize}
+		}
+		m.Packets++
+		m.Bytes += len(d)
+		if h.ErrCode == 0 {
+			m.Items += h.NItems
+		}
+	}
+	if m == nil {
+		return nil, errors.New("no mode 7 response")
+	}
+	return m, nil
+}
+
+// Amplification returns the bandwidth amplification factor of the response.
+func (m *Monlist) Amplification() float64 {
+	if m.SendLen == 0 {
+		return 0
+	}
+	return float64(m.Bytes) / float64(m.SendLen)
+}
+
+// IsReflector tells whether the monitor list came back with data larger than
+// the request, making the host usable for reflected amplification.
+func (m *Monlist) IsReflector() bool {
+	return m.ErrCode == 0 && m.Items > 0 && m.Bytes > m.SendLen
+}
+
+func (m *Monlist) Lines() string {
+	buf := new(bytes.Buffer)
+	buf.WriteString(fmt.Sprintf("Implementation: %d\nRequest Code:   %d\n", m.Impl, m.ReqCode))
+	buf.WriteString(fmt.Sprintf("Error:          %s\n", indexedName(privateErrorNames, m.ErrCode)))
+	buf.WriteString(fmt.Sprintf("Packets:        %d\nBytes:          %d\n", m.Packets, m.Bytes))
+	buf.WriteString(fmt.Sprintf("Items:          %d (%d bytes each)\n", m.Items, m.ItemSize))
+	buf.WriteString(fmt.Sprintf("Amplification:  %.2f\n", m.Amplification()))
+	if m.IsReflector() {
+		buf.WriteString("Reflector:      yes\n\n\n")
+	} else {
+		buf.WriteString("Reflector:      no\n\n\n")
+	}
+	return buf.String()
+}
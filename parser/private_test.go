@@ -0,0 +1,45 @@
+package parser
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func privateResponse(more bool, errCode, nItems, itemSize int) []byte {
+	d := make([]byte, PrivateHeaderLength+nItems*itemSize)
+	d[0] = 0x97
+	if more {
+		d[0] |= 0x40
+	}
+	d[2], d[3] = ImplXNTPD, MonGetList1
+	binary.BigEndian.PutUint16(d[4:6], uint16(errCode<<12|nItems))
+	binary.BigEndian.PutUint16(d[6:8], uint16(itemSize))
+	return d
+}
+
+func TestParseMonlist(t *testing.T) {
+	request := NewMonlistRequest(ImplXNTPD, MonGetList1)
+	var tests = []struct {
+		datagrams [][]byte
+		items     int
+		bytes     int
+		reflector bool
+	}{
+		{[][]byte{privateResponse(true, 0, 6, 72), privateResponse(false, 0, 2, 72)}, 8, 16 + 8*72, true},
+		{[][]byte{privateResponse(false, 4, 0, 0)}, 0, 8, false},
+		{[][]byte{request, privateResponse(false, 0, 1, 72)}, 1, 80, true},
+	}
+	for _, test := range tests {
+		m, err := ParseMonlist(test.datagrams, len(request))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if m.Items != test.items || m.Bytes != test.bytes || m.IsReflector() != test.reflector {
+			t.Errorf("ParseMonlist() = %+v, reflector %v", m, m.IsReflector())
+		}
+	}
+	if _, err := ParseMonlist([][]byte{request}, len(request)); err == nil {
+		t.Errorf("ParseMonlist(request) should fail")
+	}
+}
@@ -0,0 +1,202 @@
+package parser
+
+import (
+	"active/packet"
+	"active/utils"
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/spf13/viper"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	configPath     = "../resource/"
+	ipv6ServersKey = "refid.ipv6_servers"
+)
+
+var (
+	ipv6Mu      sync.RWMutex
+	ipv6Servers = make(map[packet.RefID]string)
+	// hashingImplementations announce the MD5 hash of an IPv6 upstream
+	hashingImplementations = map[string]bool{
+		"ntpd":   true,
+		"NTPsec": true,
+		"chrony": true,
+	}
+)
+
+// RefIDHints is what is known of a server beyond its reply.
+type RefIDHints struct {
+	// IPv6 is set when the server answered over IPv6, which makes an IPv6
+	// upstream likely
+	IPv6 bool
+	// Implementation is the fingerprinted implementation, empty if unknown
+	Implementation string
+}
+
+// RefIDClass is the interpretation of a reference ID.
+type RefIDClass struct {
+	Kind packet.RefIDKind
+	ID   packet.RefID
+	// Text is the kiss code, the clock source or the address
+	Text string
+	// Upstream is the IPv6 server a hash resolves to, empty if unknown
+	Upstream string
+}
+
+func init() {
+	viper.AddConfigPath(configPath)
+	viper.SetConfigType("yaml")
+	viper.SetConfigName("properties")
+	err := viper.ReadInConfig()
+	if err != nil {
+		fmt.Printf("error reading resource file: %v", err)
+	}
+	if path := viper.GetString(ipv6ServersKey); path != "" {
+		if _, err = LoadIPv6Servers(path); err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+// AddIPv6Server remembers an IPv6 server so that the reference IDs of its
+// clients resolve to it. Other addresses are ignored.
+func AddIPv6Server(host string) bool {
+	ip := net.ParseIP(host)
+	if ip == nil || ip.To4() != nil {
+		return false
+	}
+	ipv6Mu.Lock()
+	defer ipv6Mu.Unlock()
+	ipv6Servers[packet.IPv6RefID(ip)] = ip.String()
+	return true
+}
+
+// LoadIPv6Servers reads IPv6 servers from a file holding one address per
+// line, '#' starting a comment, and returns the number of servers added.
+func LoadIPv6Servers(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("error opening IPv6 server file %s: %v", path, err)
+	}
+	defer func() { _ = file.Close() }()
+	count := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.SplitN(scanner.Text(), "#", 2)[0])
+		if line != "" && AddIPv6Server(line) {
+			count++
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return count, fmt.Errorf("error reading IPv6 server file %s: %v", path, err)
+	}
+	return count, nil
+}
+
+// ResolveIPv6RefID returns the known IPv6 server whose hash is the reference
+// ID.
+func ResolveIPv6RefID(id packet.RefID) (string, bool) {
+	ipv6Mu.RLock()
+	defer ipv6Mu.RUnlock()
+	host, ok := ipv6Servers[id]
+	return host, ok
+}
+
+// ClassifyRefID interprets the reference ID of a packet. Above stratum 1, a
+// value matching a known IPv6 server or outside unicast space is a hash, and
+// so is any address when an IPv6 server fingerprinted as an implementation
+// known to hash answered. An IPv6 server of unknown implementation may well
+// sync to an IPv4 upstream, its reference ID is taken as an address.
+func ClassifyRefID(p *packet.Packet, hints RefIDHints) *RefIDClass {
+	res := &RefIDClass{Kind: p.RefIDKind(), ID: p.RefID}
+	switch res.Kind {
+	case packet.RefIDKiss:
+		res.Text, _ = p.KissCode()
+	case packet.RefIDSource:
+		res.Text = p.RefID.ASCII()
+	case packet.RefIDLocal:
+		if p.RefID>>24 == 127 {
+			res.Text = p.RefID.IP().String()
+		} else {
+			res.Text = p.RefID.ASCII()
+		}
+	case packet.RefIDAddress, packet.RefIDHash:
+		res.Text = p.RefID.IP().String()
+		if host, ok := ResolveIPv6RefID(p.RefID); ok {
+			res.Kind, res.Upstream = packet.RefIDHash, host
+		} else if hints.IPv6 && hashingImplementations[hints.Implementation] {
+			res.Kind = packet.RefIDHash
+		}
+	}
+	return res
+}
+
+// PendingRefIDs collects the reference IDs that may hash an IPv6 server the
+// scan had not found yet when the reply was parsed, so that they resolve once
+// every responder is known. Only distinct reference IDs are kept, never the
+// replies.
+type PendingRefIDs struct {
+	mu  sync.Mutex
+	ids map[packet.RefID]int
+}
+
+func NewPendingRefIDs() *PendingRefIDs {
+	return &PendingRefIDs{ids: make(map[packet.RefID]int)}
+}
+
+// Add keeps the reference ID of c when it is an address or a hash of unknown
+// upstream.
+func (r *PendingRefIDs) Add(c *RefIDClass) {
+	if c == nil || c.Upstream != "" || (c.Kind != packet.RefIDAddress && c.Kind != packet.RefIDHash) {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ids[c.ID]++
+}
+
+// Lines lists the kept reference IDs that now resolve to an IPv6 server, with
+// the number of replies that carried them.
+func (r *PendingRefIDs) Lines() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make([]packet.RefID, 0)
+	for id := range r.ids {
+		if _, ok := ResolveIPv6RefID(id); ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	buf := new(bytes.Buffer)
+	for _, id := range ids {
+		host, _ := ResolveIPv6RefID(id)
+		buf.WriteString(fmt.Sprintf("    %08X  IPv6 hash of %-39s %d replies\n", uint32(id), host, r.ids[id]))
+	}
+	return buf.String()
+}
+
+func (c *RefIDClass) String() string {
+	switch c.Kind {
+	case packet.RefIDZero:
+		return "zero"
+	case packet.RefIDKiss:
+		return fmt.Sprintf("%s (%s)", c.Text, utils.KissCodeMeaning(c.Text))
+	case packet.RefIDLocal:
+		return fmt.Sprintf("%s (local clock)", c.Text)
+	case packet.RefIDHash:
+		if c.Upstream != "" {
+			return fmt.Sprintf("%08X (IPv6 hash of %s)", uint32(c.ID), c.Upstream)
+		}
+		return fmt.Sprintf("%08X (IPv6 hash, upstream unknown)", uint32(c.ID))
+	case packet.RefIDAddress:
+		return fmt.Sprintf("%s (%s)", c.Text, utils.RegionOf(c.Text))
+	default:
+		return c.Text
+	}
+}
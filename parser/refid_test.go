@@ -0,0 +1,93 @@
+package parser
+
+import (
+	"active/packet"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestClassifyRefID(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ipv6.txt")
+	err := os.WriteFile(path, []byte("# known servers\n2001:db8::1\n192.0.2.1\n\n2001:db8::123 # pool\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count, err := LoadIPv6Servers(path); err != nil || count != 2 {
+		t.Fatalf("LoadIPv6Servers() = %d, %v", count, err)
+	}
+
+	var tests = []struct {
+		name     string
+		stratum  uint8
+		refID    packet.RefID
+		hints    RefIDHints
+		kind     packet.RefIDKind
+		text     string
+		upstream string
+	}{
+		{"zero", 3, 0, RefIDHints{}, packet.RefIDZero, "", ""},
+		{"kiss", 0, 0x52415445, RefIDHints{}, packet.RefIDKiss, "RATE", ""},
+		{"GPS", 1, 0x47505300, RefIDHints{}, packet.RefIDSource, "GPS", ""},
+		{"LOCL", 1, 0x4C4F434C, RefIDHints{}, packet.RefIDLocal, "LOCL", ""},
+		{"loopback", 2, 0x7F7F0101, RefIDHints{}, packet.RefIDLocal, "127.127.1.1", ""},
+		{"IPv4 upstream", 2, 0xC0000201, RefIDHints{}, packet.RefIDAddress, "192.0.2.1", ""},
+		{"known IPv6 upstream", 2, 0x39AB9B37, RefIDHints{}, packet.RefIDHash, "57.171.155.55", "2001:db8::1"},
+		{"unresolved hash", 2, 0xE1A2B3C4, RefIDHints{}, packet.RefIDHash, "225.162.179.196", ""},
+		{"IPv6 server", 2, 0xC6336401, RefIDHints{IPv6: true}, packet.RefIDAddress, "198.51.100.1", ""},
+		{"IPv6 server with non-unicast refid", 2, 0xE1A2B3C4, RefIDHints{IPv6: true}, packet.RefIDHash,
+			"225.162.179.196", ""},
+		{"IPv6 hashing server", 2, 0xC6336401, RefIDHints{IPv6: true, Implementation: "chrony"},
+			packet.RefIDHash, "198.51.100.1", ""},
+		{"IPv6 server not hashing", 2, 0xC6336401, RefIDHints{IPv6: true, Implementation: "Windows w32time"},
+			packet.RefIDAddress, "198.51.100.1", ""},
+	}
+	for _, tt := range tests {
+		p := &packet.Packet{Version: 4, Mode: 4, Stratum: tt.stratum, RefID: tt.refID}
+		c := ClassifyRefID(p, tt.hints)
+		if c.Kind != tt.kind || c.Text != tt.text || c.Upstream != tt.upstream {
+			t.Errorf("%s: ClassifyRefID() = %s %q %q, want %s %q %q", tt.name,
+				c.Kind, c.Text, c.Upstream, tt.kind, tt.text, tt.upstream)
+		}
+	}
+
+	if _, ok := ResolveIPv6RefID(packet.IPv6RefID([]byte{192, 0, 2, 1})); ok {
+		t.Errorf("IPv4 address resolved as an IPv6 server")
+	}
+}
+
+func TestRefIDClassString(t *testing.T) {
+	var tests = []struct {
+		class RefIDClass
+		str   string
+	}{
+		{RefIDClass{Kind: packet.RefIDLocal, Text: "LOCL"}, "LOCL (local clock)"},
+		{RefIDClass{Kind: packet.RefIDHash, ID: 0x39AB9B37, Upstream: "2001:db8::1"},
+			"39AB9B37 (IPv6 hash of 2001:db8::1)"},
+		{RefIDClass{Kind: packet.RefIDHash, ID: 0xE1A2B3C4}, "E1A2B3C4 (IPv6 hash, upstream unknown)"},
+	}
+	for _, tt := range tests {
+		if got := tt.class.String(); got != tt.str {
+			t.Errorf("String() = %q, want %q", got, tt.str)
+		}
+	}
+}
+
+func TestPendingRefIDs(t *testing.T) {
+	pending := NewPendingRefIDs()
+	pending.Add(&RefIDClass{Kind: packet.RefIDHash, ID: 0xC975CECC})
+	pending.Add(&RefIDClass{Kind: packet.RefIDAddress, ID: 0xC975CECC})
+	pending.Add(&RefIDClass{Kind: packet.RefIDAddress, ID: 0xC0000201})
+	pending.Add(&RefIDClass{Kind: packet.RefIDLocal, ID: 0x4C4F434C})
+	pending.Add(&RefIDClass{Kind: packet.RefIDHash, ID: 0x39AB9B37, Upstream: "2001:db8::1"})
+
+	// The server is only found after its clients answered
+	AddIPv6Server("2001:db8::123")
+	lines := pending.Lines()
+	if !strings.Contains(lines, "C975CECC  IPv6 hash of 2001:db8::123") || !strings.Contains(lines, " 2 replies") ||
+		strings.Count(lines, "\n") != 1 {
+		t.Errorf("Lines() = %q", lines)
+	}
+}
@@ -0,0 +1,68 @@
+package parser
+
+import (
+	"active/utils"
+	"fmt"
+)
+
+type SymmetricOutcome int
+
+const (
+	SymmetricIgnored SymmetricOutcome = iota
+	SymmetricPassive
+	SymmetricKoD
+	SymmetricOther
+)
+
+// SymmetricResult is how a host answered an unauthenticated symmetric active
+// packet.
+type SymmetricResult struct {
+	Outcome  SymmetricOutcome
+	Mode     int
+	KissCode string
+}
+
+// ParseSymmetric classifies the reply to a mode 1 packet, nil data meaning
+// the host did not answer.
+func ParseSymmetric(data []byte) *SymmetricResult {
+	if len(data) == 0 {
+		return &SymmetricResult{Outcome: SymmetricIgnored}
+	}
+	res := &SymmetricResult{Mode: int(data[0] & 0b00000111)}
+	if code, ok := utils.KissCode(data); ok {
+		res.Outcome = SymmetricKoD
+		res.KissCode = code
+	} else if res.Mode == symmetricPassiveMode {
+		res.Outcome = SymmetricPassive
+	} else {
+		res.Outcome = SymmetricOther
+	}
+	return res
+}
+
+// Vulnerable tells whether the host lets an unauthenticated peer set up an
+// association with it.
+func (r *SymmetricResult) Vulnerable() bool {
+	return r.Outcome == SymmetricPassive
+}
+
+func (r *SymmetricResult) String() string {
+	switch r.Outcome {
+	case SymmetricPassive:
+		return "symmetric passive reply, accepts unauthenticated peers"
+	case SymmetricKoD:
+		return fmt.Sprintf("Kiss-o'-Death %s (%s)", r.KissCode, utils.KissCodeMeaning(r.KissCode))
+	case SymmetricOther:
+		return fmt.Sprintf("answered with mode %d (%s)", r.Mode, ModeName(r.Mode))
+	default:
+		return "ignored"
+	}
+}
+
+func (r *SymmetricResult) Lines() string {
+	flag := "no"
+	if r.Vulnerable() {
+		flag = "yes"
+	}
+	return fmt.Sprintf("Symmetric Mode:  %s\nPeer Injection:  %s\n\n\n", r.String(), flag)
+}
@@ -0,0 +1,28 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestParseSymmetric(t *testing.T) {
+	kod := versionReply(4, 0)
+	copy(kod[12:16], "RATE")
+	passive := versionReply(4, 2)
+	passive[0] = 4<<3 | symmetricPassiveMode
+	var tests = []struct {
+		data       []byte
+		outcome    SymmetricOutcome
+		vulnerable bool
+	}{
+		{nil, SymmetricIgnored, false},
+		{passive, SymmetricPassive, true},
+		{kod, SymmetricKoD, false},
+		{versionReply(4, 2), SymmetricOther, false},
+	}
+	for _, test := range tests {
+		res := ParseSymmetric(test.data)
+		if res.Outcome != test.outcome || res.Vulnerable() != test.vulnerable {
+			t.Errorf("ParseSymmetric() = %s", res)
+		}
+	}
+}
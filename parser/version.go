@@ -0,0 +1,115 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// VersionResult is what a host answered to a request of one version.
+type VersionResult struct {
+	Sent     int
+	Answered bool
+	Version  int
+	Mode     int
+	Err      error
+	Diffs    []string
+}
+
+// VersionMatrix records how a host answers requests of versions 1 to 4.
+type VersionMatrix struct {
+	Host    string
+	Results []VersionResult
+}
+
+// NewVersionMatrix builds the matrix from the replies indexed by the version
+// sent, a missing or nil reply meaning the host stayed silent. Header fields
+// are compared with the reply to the highest version answered.
+func NewVersionMatrix(host string, replies map[int][]byte) *VersionMatrix {
+	m := &VersionMatrix{Host: host}
+	headers := make(map[int]*Header)
+	base := 0
+	for v := 1; v <= 4; v++ {
+		r := VersionResult{Sent: v}
+		data := replies[v]
+		if len(data) > 0 {
+			r.Answered = true
+			r.Version = int(data[0]&0b00111000) >> 3
+			r.Mode = int(data[0] & 0b00000111)
+			headers[v], r.Err = ParseHeader(data)
+			if r.Err == nil {
+				base = v
+			}
+		}
+		m.Results = append(m.Results, r)
+	}
+	if base == 0 {
+		return m
+	}
+	for i := range m.Results {
+		r := &m.Results[i]
+		if r.Sent != base && headers[r.Sent] != nil {
+			r.Diffs = compareHeaders(headers[base], headers[r.Sent])
+		}
+	}
+	return m
+}
+
+// compareHeaders lists the fields that differ between two replies, leaving
+// out the version and the timestamps which always change.
+func compareHeaders(a, b *Header) []string {
+	fields := []struct {
+		name string
+		x, y string
+	}{
+		{"leap", a.Leap, b.Leap},
+		{"mode", a.Mode, b.Mode},
+		{"stratum", a.Stratum, b.Stratum},
+		{"poll", a.Poll, b.Poll},
+		{"precision", a.Precision, b.Precision},
+		{"root delay", a.RootDelay, b.RootDelay},
+		{"root dispersion", a.RootDisp, b.RootDisp},
+		{"reference id", a.RefID, b.RefID},
+	}
+	res := make([]string, 0)
+	for _, f := range fields {
+		if f.x != f.y {
+			res = append(res, f.name)
+		}
+	}
+	return res
+}
+
+// Key sums the matrix up as the version answered to each version sent, `-`
+// standing for no reply, like "-234".
+func (m *VersionMatrix) Key() string {
+	buf := new(strings.Builder)
+	for _, r := range m.Results {
+		if r.Answered {
+			buf.WriteString(fmt.Sprint(r.Version))
+		} else {
+			buf.WriteByte('-')
+		}
+	}
+	return buf.String()
+}
+
+func (m *VersionMatrix) Lines() string {
+	buf := new(bytes.Buffer)
+	buf.WriteString(fmt.Sprintf("Version matrix of %s: %s\n", m.Host, m.Key()))
+	for _, r := range m.Results {
+		if !r.Answered {
+			buf.WriteString(fmt.Sprintf("    v%d request: no reply\n", r.Sent))
+			continue
+		}
+		buf.WriteString(fmt.Sprintf("    v%d request: answered v%d (%s)", r.Sent, r.Version, ModeName(r.Mode)))
+		if r.Err != nil {
+			buf.WriteString(fmt.Sprintf(", %v", r.Err))
+		} else if len(r.Diffs) > 0 {
+			buf.WriteString(", differs in " + strings.Join(r.Diffs, ", "))
+		}
+		buf.WriteByte('\n')
+	}
+	buf.WriteString("\n\n")
+	return buf.String()
+}
@@ -0,0 +1,34 @@
+package parser
+
+import (
+	"testing"
+)
+
+func versionReply(version, stratum byte) []byte {
+	data := make([]byte, HeaderLength)
+	data[0] = version<<3 | serverMode
+	data[1] = stratum
+	return data
+}
+
+func TestNewVersionMatrix(t *testing.T) {
+	var tests = []struct {
+		replies map[int][]byte
+		key     string
+		diffs   int
+	}{
+		{map[int][]byte{2: versionReply(2, 2), 3: versionReply(3, 2), 4: versionReply(4, 3)}, "-234", 2},
+		{map[int][]byte{1: versionReply(3, 2), 2: versionReply(3, 2), 3: versionReply(3, 2), 4: versionReply(3, 2)}, "3333", 0},
+		{map[int][]byte{}, "----", 0},
+	}
+	for _, test := range tests {
+		m := NewVersionMatrix("192.0.2.1", test.replies)
+		diffs := 0
+		for _, r := range m.Results {
+			diffs += len(r.Diffs)
+		}
+		if m.Key() != test.key || diffs != test.diffs {
+			t.Errorf("NewVersionMatrix() = %s with %d diffs, want %s with %d", m.Key(), diffs, test.key, test.diffs)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"net"
+	"sync/atomic"
+)
+
+const (
+	protoTCP       = 6
+	protoUDP       = 17
+	defaultTTL     = 64
+	ipv4HeaderLen  = 20
+	ipv6HeaderLen  = 40
+	udpHeaderLen   = 8
+	tcpHeaderLen   = 20
+	tcpFlagFIN     = 0x01
+	tcpFlagSYN     = 0x02
+	tcpFlagPSH     = 0x08
+	tcpFlagACK     = 0x10
+	tcpWindowSize  = 0xFFFF
+	maxIPv4Payload = 0xFFFF - ipv4HeaderLen
+)
+
+var (
+	ipID uint32
+)
+
+func buildUDP(src, dst net.IP, srcPort, dstPort, ttl int, payload []byte) []byte {
+	segment := make([]byte, udpHeaderLen+len(payload))
+	binary.BigEndian.PutUint16(segment[0:2], uint16(srcPort))
+	binary.BigEndian.PutUint16(segment[2:4], uint16(dstPort))
+	binary.BigEndian.PutUint16(segment[4:6], uint16(len(segment)))
+	copy(segment[udpHeaderLen:], payload)
+	return buildIP(src, dst, protoUDP, ttl, segment, 6)
+}
+
+func buildTCP(src, dst net.IP, srcPort, dstPort int, seq, ack uint32, flags byte, payload []byte) []byte {
+	segment := make([]byte, tcpHeaderLen+len(payload))
+	binary.BigEndian.PutUint16(segment[0:2], uint16(srcPort))
+	binary.BigEndian.PutUint16(segment[2:4], uint16(dstPort))
+	binary.BigEndian.PutUint32(segment[4:8], seq)
+	binary.BigEndian.PutUint32(segment[8:12], ack)
+	segment[12] = (tcpHeaderLen / 4) << 4
+	segment[13] = flags
+	binary.BigEndian.PutUint16(segment[14:16], tcpWindowSize)
+	copy(segment[tcpHeaderLen:], payload)
+	return buildIP(src, dst, protoTCP, 0, segment, 16)
+}
+
+// buildIP wraps a transport segment into an IPv4 header, or an IPv6 header if
+// either address is IPv6, and fills in the transport checksum at csumPos.
+func buildIP(src, dst net.IP, proto byte, ttl int, segment []byte, csumPos int) []byte {
+	if ttl <= 0 || ttl > 255 {
+		ttl = defaultTTL
+	}
+	src4, dst4 := toIPv4(src), toIPv4(dst)
+	if src4 != nil && dst4 != nil {
+		header := make([]byte, ipv4HeaderLen)
+		header[0] = 0x45
+		binary.BigEndian.PutUint16(header[2:4], uint16(ipv4HeaderLen+len(segment)))
+		binary.BigEndian.PutUint16(header[4:6], uint16(atomic.AddUint32(&ipID, 1)))
+		// Don't fragment
+		header[6] = 0x40
+		header[8] = byte(ttl)
+		header[9] = proto
+		copy(header[12:16], src4)
+		copy(header[16:20], dst4)
+		binary.BigEndian.PutUint16(header[10:12], checksum(header, 0))
+
+		pseudo := make([]byte, 12)
+		copy(pseudo[0:4], src4)
+		copy(pseudo[4:8], dst4)
+		pseudo[9] = proto
+		binary.BigEndian.PutUint16(pseudo[10:12], uint16(len(segment)))
+		putTransportChecksum(segment, csumPos, proto, pseudo)
+		return append(header, segment...)
+	}
+
+	src6, dst6 := toIPv6(src), toIPv6(dst)
+	header := make([]byte, ipv6HeaderLen)
+	header[0] = 0x60
+	binary.BigEndian.PutUint16(header[4:6], uint16(len(segment)))
+	header[6] = proto
+	header[7] = byte(ttl)
+	copy(header[8:24], src6)
+	copy(header[24:40], dst6)
+
+	pseudo := make([]byte, 40)
+	copy(pseudo[0:16], src6)
+	copy(pseudo[16:32], dst6)
+	binary.BigEndian.PutUint32(pseudo[32:36], uint32(len(segment)))
+	pseudo[39] = proto
+	putTransportChecksum(segment, csumPos, proto, pseudo)
+	return append(header, segment...)
+}
+
+func putTransportChecksum(segment []byte, pos int, proto byte, pseudo []byte) {
+	sum := checksum(segment, sum16(pseudo))
+	if proto == protoUDP && sum == 0 {
+		// A computed UDP checksum of zero is sent as all ones
+		sum = 0xFFFF
+	}
+	binary.BigEndian.PutUint16(segment[pos:pos+2], sum)
+}
+
+func checksum(data []byte, initial uint32) uint16 {
+	sum := initial + sum16(data)
+	for sum>>16 != 0 {
+		sum = (sum & 0xFFFF) + (sum >> 16)
+	}
+	return ^uint16(sum)
+}
+
+func sum16(data []byte) uint32 {
+	var sum uint32
+	n := len(data)
+	for i := 0; i+1 < n; i += 2 {
+		sum += uint32(data[i])<<8 | uint32(data[i+1])
+	}
+	if n%2 == 1 {
+		sum += uint32(data[n-1]) << 8
+	}
+	return sum
+}
+
+// toIPv4 returns the 4-byte form of ip, treating an unspecified or missing
+// address as 0.0.0.0 so that it pairs with any IPv4 peer.
+func toIPv4(ip net.IP) net.IP {
+	if ip == nil || ip.IsUnspecified() {
+		return net.IPv4zero.To4()
+	}
+	return ip.To4()
+}
+
+func toIPv6(ip net.IP) net.IP {
+	if ip == nil {
+		return net.IPv6unspecified
+	}
+	return ip.To16()
+}
@@ -0,0 +1,38 @@
+package pcap
+
+import (
+	"net"
+	"testing"
+)
+
+func TestBuildUDP(t *testing.T) {
+	var tests = []struct {
+		src, dst   string
+		headerLen  int
+		pseudoFunc func(packet []byte) []byte
+	}{
+		{"192.168.1.2", "203.107.6.88", ipv4HeaderLen, func(packet []byte) []byte {
+			pseudo := append([]byte(nil), packet[12:20]...)
+			return append(pseudo, 0, protoUDP, packet[24], packet[25])
+		}},
+		{"2001:db8::1", "2001:db8::123", ipv6HeaderLen, func(packet []byte) []byte {
+			pseudo := append([]byte(nil), packet[8:40]...)
+			return append(pseudo, 0, 0, packet[44], packet[45], 0, 0, 0, protoUDP)
+		}},
+	}
+	payload := []byte{0xDB, 0x00, 0x04, 0xFA, 0x00, 0x01, 0x00}
+	for _, test := range tests {
+		packet := buildUDP(net.ParseIP(test.src), net.ParseIP(test.dst), 11123, 123, 0, payload)
+		if len(packet) != test.headerLen+udpHeaderLen+len(payload) {
+			t.Errorf("buildUDP(%s, %s) length = %d", test.src, test.dst, len(packet))
+			continue
+		}
+		if test.headerLen == ipv4HeaderLen && checksum(packet[:ipv4HeaderLen], 0) != 0 {
+			t.Errorf("buildUDP(%s, %s) has a bad IPv4 header checksum", test.src, test.dst)
+		}
+		pseudo := test.pseudoFunc(packet)
+		if checksum(packet[test.headerLen:], sum16(pseudo)) != 0 {
+			t.Errorf("buildUDP(%s, %s) has a bad UDP checksum", test.src, test.dst)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package pcap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	sectionHeaderBlock    = 0x0A0D0D0A
+	interfaceDescBlock    = 0x00000001
+	enhancedPacketBlock   = 0x00000006
+	decryptionSecretBlock = 0x0000000A
+	byteOrderMagic        = 0x1A2B3C4D
+	tlsKeyLogSecrets      = 0x544C534B
+	linkTypeRaw           = 101
+	optEndOfOpt           = 0
+	optTSResol            = 9
+	// Timestamps are written in nanoseconds
+	tsResolNano = 9
+)
+
+var (
+	mu     sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+)
+
+// Open starts capturing every probe and reply into a new pcapng file at path.
+func Open(path string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if writer != nil {
+		return fmt.Errorf("capture already started")
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating capture file %s: %v", path, err)
+	}
+	file = f
+	writer = bufio.NewWriter(f)
+	writeBlock(sectionHeaderBlock, sectionHeaderBody())
+	writeBlock(interfaceDescBlock, interfaceDescBody())
+	return nil
+}
+
+// Close flushes and closes the capture file, it does nothing if no capture is running.
+func Close() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if writer == nil {
+		return nil
+	}
+	err := writer.Flush()
+	closeErr := file.Close()
+	writer, file = nil, nil
+	if err != nil {
+		return fmt.Errorf("error flushing capture file: %v", err)
+	}
+	if closeErr != nil {
+		return fmt.Errorf("error closing capture file: %v", closeErr)
+	}
+	return nil
+}
+
+func Enabled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return writer != nil
+}
+
+// WriteUDP records a datagram sent from src to dst at ts. A TTL of 0 stands
+// for the default of 64.
+func WriteUDP(src, dst *net.UDPAddr, ttl int, payload []byte, ts time.Time) {
+	if src == nil || dst == nil {
+		return
+	}
+	packet := buildUDP(src.IP, dst.IP, src.Port, dst.Port, ttl, payload)
+	mu.Lock()
+	defer mu.Unlock()
+	if writer == nil {
+		return
+	}
+	writePacket(packet, ts)
+}
+
+// WriteSecrets records a TLS key log so that the following TLS records can be
+// decrypted by readers of the capture.
+func WriteSecrets(keyLog []byte) {
+	if len(keyLog) == 0 {
+		return
+	}
+	body := make([]byte, 8, 8+pad4(len(keyLog)))
+	binary.LittleEndian.PutUint32(body[0:4], tlsKeyLogSecrets)
+	binary.LittleEndian.PutUint32(body[4:8], uint32(len(keyLog)))
+	body = append(body, keyLog...)
+	body = append(body, make([]byte, pad4(len(keyLog))-len(keyLog))...)
+	mu.Lock()
+	defer mu.Unlock()
+	if writer == nil {
+		return
+	}
+	writeBlock(decryptionSecretBlock, body)
+}
+
+func writePacket(packet []byte, ts time.Time) {
+	nano := uint64(ts.UnixNano())
+	body := make([]byte, 20, 20+pad4(len(packet))+4)
+	// Interface ID 0
+	binary.LittleEndian.PutUint32(body[4:8], uint32(nano>>32))
+	binary.LittleEndian.PutUint32(body[8:12], uint32(nano))
+	binary.LittleEndian.PutUint32(body[12:16], uint32(len(packet)))
+	binary.LittleEndian.PutUint32(body[16:20], uint32(len(packet)))
+	body = append(body, packet...)
+	body = append(body, make([]byte, pad4(len(packet))-len(packet))...)
+	writeBlock(enhancedPacketBlock, body)
+}
+
+func sectionHeaderBody() []byte {
+	body := make([]byte, 16)
+	binary.LittleEndian.PutUint32(body[0:4], byteOrderMagic)
+	// Version 1.0
+	binary.LittleEndian.PutUint16(body[4:6], 1)
+	binary.LittleEndian.PutUint16(body[6:8], 0)
+	// Section length unknown
+	binary.LittleEndian.PutUint64(body[8:16], 0xFFFFFFFFFFFFFFFF)
+	return body
+}
+
+func interfaceDescBody() []byte {
+	body := make([]byte, 20)
+	binary.LittleEndian.PutUint16(body[0:2], linkTypeRaw)
+	// Snap length 0 means no limit
+	binary.LittleEndian.PutUint16(body[8:10], optTSResol)
+	binary.LittleEndian.PutUint16(body[10:12], 1)
+	body[12] = tsResolNano
+	binary.LittleEndian.PutUint16(body[16:18], optEndOfOpt)
+	return body
+}
+
+// writeBlock must be called with mu held.
+func writeBlock(blockType uint32, body []byte) {
+	total := uint32(12 + len(body))
+	head := make([]byte, 8)
+	binary.LittleEndian.PutUint32(head[0:4], blockType)
+	binary.LittleEndian.PutUint32(head[4:8], total)
+	tail := make([]byte, 4)
+	binary.LittleEndian.PutUint32(tail, total)
+	_, _ = writer.Write(head)
+	_, _ = writer.Write(body)
+	_, _ = writer.Write(tail)
+}
+
+func pad4(n int) int {
+	return (n + 3) &^ 3
+}
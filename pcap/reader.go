@@ -0,0 +1,329 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+const (
+	classicMagicMicro = 0xA1B2C3D4
+	classicMagicNano  = 0xA1B23C4D
+	simplePacketBlock = 0x00000003
+	linkTypeNull      = 0
+	linkTypeEthernet  = 1
+	linkTypeLinuxSLL  = 113
+	linkTypeIPv4      = 228
+	linkTypeIPv6      = 229
+	etherTypeIPv4     = 0x0800
+	etherTypeIPv6     = 0x86DD
+	etherTypeVLAN     = 0x8100
+)
+
+// Packet is a UDP datagram or TCP segment read back from a capture.
+type Packet struct {
+	Time    time.Time
+	Proto   byte
+	SrcIP   net.IP
+	DstIP   net.IP
+	SrcPort int
+	DstPort int
+	TTL     int
+	Seq     uint32
+	Flags   byte
+	Payload []byte
+}
+
+// Capture holds the UDP and TCP packets of a capture file in file order,
+// together with the TLS key logs found in it.
+type Capture struct {
+	Packets []*Packet
+	KeyLog  []byte
+	Skipped int
+}
+
+type iface struct {
+	linkType uint16
+	// Duration of one timestamp unit
+	unit time.Duration
+}
+
+// ReadFile reads a pcapng or classic pcap file.
+func ReadFile(path string) (*Capture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading capture file %s: %v", path, err)
+	}
+	if len(data) < 4 {
+		return nil, errors.New("capture file too short")
+	}
+	if binary.LittleEndian.Uint32(data[:4]) == sectionHeaderBlock {
+		return readPcapNG(data)
+	}
+	return readClassic(data)
+}
+
+func readClassic(data []byte) (*Capture, error) {
+	if len(data) < 24 {
+		return nil, errors.New("incomplete pcap file header")
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	magic := order.Uint32(data[:4])
+	if magic != classicMagicMicro && magic != classicMagicNano {
+		order = binary.BigEndian
+		magic = order.Uint32(data[:4])
+	}
+	var unit time.Duration
+	switch magic {
+	case classicMagicMicro:
+		unit = time.Microsecond
+	case classicMagicNano:
+		unit = time.Nanosecond
+	default:
+		return nil, fmt.Errorf("unrecognized capture file magic %08X", magic)
+	}
+	linkType := uint16(order.Uint32(data[20:24]))
+
+	res := new(Capture)
+	cur := 24
+	for cur+16 <= len(data) {
+		sec := order.Uint32(data[cur : cur+4])
+		frac := order.Uint32(data[cur+4 : cur+8])
+		capLen := int(order.Uint32(data[cur+8 : cur+12]))
+		cur += 16
+		if cur+capLen > len(data) {
+			return nil, errors.New("a packet with an incorrect length exists at the end")
+		}
+		ts := time.Unix(int64(sec), 0).Add(time.Duration(frac) * unit)
+		res.add(decodeLink(linkType, data[cur:cur+capLen]), ts)
+		cur += capLen
+	}
+	return res, nil
+}
+
+func readPcapNG(data []byte) (*Capture, error) {
+	res := new(Capture)
+	var order binary.ByteOrder = binary.LittleEndian
+	ifaces := make([]iface, 0)
+	cur := 0
+	for cur+12 <= len(data) {
+		if binary.LittleEndian.Uint32(data[cur:cur+4]) == sectionHeaderBlock {
+			// Every section declares its own byte order and interfaces
+			if binary.LittleEndian.Uint32(data[cur+8:cur+12]) == byteOrderMagic {
+				order = binary.LittleEndian
+			} else {
+				order = binary.BigEndian
+			}
+			ifaces = ifaces[:0]
+		}
+		blockType := order.Uint32(data[cur : cur+4])
+		total := int(order.Uint32(data[cur+4 : cur+8]))
+		if total < 12 || cur+total > len(data) {
+			return nil, fmt.Errorf("a block with an incorrect length exists at pos %d", cur)
+		}
+		body := data[cur+8 : cur+total-4]
+		cur += total
+
+		switch blockType {
+		case interfaceDescBlock:
+			if len(body) < 8 {
+				return nil, errors.New("incomplete interface description block")
+			}
+			ifaces = append(ifaces, iface{
+				linkType: order.Uint16(body[0:2]),
+				unit:     tsUnit(body[8:], order),
+			})
+		case enhancedPacketBlock:
+			if len(body) < 20 {
+				return nil, errors.New("incomplete enhanced packet block")
+			}
+			id := int(order.Uint32(body[0:4]))
+			if id >= len(ifaces) {
+				return nil, fmt.Errorf("packet refers to unknown interface %d", id)
+			}
+			ticks := uint64(order.Uint32(body[4:8]))<<32 | uint64(order.Uint32(body[8:12]))
+			capLen := int(order.Uint32(body[12:16]))
+			if 20+capLen > len(body) {
+				return nil, errors.New("enhanced packet block with an incorrect length")
+			}
+			res.add(decodeLink(ifaces[id].linkType, body[20:20+capLen]), ticksToTime(ticks, ifaces[id].unit))
+		case simplePacketBlock:
+			if len(ifaces) == 0 || len(body) < 4 {
+				continue
+			}
+			// No timestamp is available in simple packet blocks
+			res.add(decodeLink(ifaces[0].linkType, body[4:]), time.Time{})
+		case decryptionSecretBlock:
+			if len(body) < 8 || order.Uint32(body[0:4]) != tlsKeyLogSecrets {
+				continue
+			}
+			n := int(order.Uint32(body[4:8]))
+			if 8+n > len(body) {
+				return nil, errors.New("decryption secrets block with an incorrect length")
+			}
+			res.KeyLog = append(res.KeyLog, body[8:8+n]...)
+			if n > 0 && body[8+n-1] != '\n' {
+				res.KeyLog = append(res.KeyLog, '\n')
+			}
+		}
+	}
+	return res, nil
+}
+
+func (c *Capture) add(p *Packet, ts time.Time) {
+	if p == nil {
+		c.Skipped++
+		return
+	}
+	p.Time = ts
+	c.Packets = append(c.Packets, p)
+}
+
+// tsUnit reads the if_tsresol option of an interface, microseconds by default.
+func tsUnit(opts []byte, order binary.ByteOrder) time.Duration {
+	for len(opts) >= 4 {
+		code := order.Uint16(opts[0:2])
+		n := int(order.Uint16(opts[2:4]))
+		if code == optEndOfOpt || 4+n > len(opts) {
+			break
+		}
+		if code == optTSResol && n >= 1 {
+			res := opts[4]
+			if res&0x80 != 0 {
+				// Negative power of two
+				return time.Second >> (res & 0x7F)
+			}
+			unit := time.Second
+			for i := byte(0); i < res && unit > 1; i++ {
+				unit /= 10
+			}
+			return unit
+		}
+		opts = opts[4+pad4(n):]
+	}
+	return time.Microsecond
+}
+
+func ticksToTime(ticks uint64, unit time.Duration) time.Time {
+	perSecond := uint64(time.Second / unit)
+	sec := ticks / perSecond
+	rest := ticks % perSecond
+	return time.Unix(int64(sec), int64(time.Duration(rest)*unit))
+}
+
+func decodeLink(linkType uint16, frame []byte) *Packet {
+	switch linkType {
+	case linkTypeRaw, linkTypeIPv4, linkTypeIPv6:
+		return decodeIP(frame)
+	case linkTypeEthernet:
+		if len(frame) < 14 {
+			return nil
+		}
+		etherType := binary.BigEndian.Uint16(frame[12:14])
+		frame = frame[14:]
+		if etherType == etherTypeVLAN && len(frame) >= 4 {
+			etherType = binary.BigEndian.Uint16(frame[2:4])
+			frame = frame[4:]
+		}
+		if etherType != etherTypeIPv4 && etherType != etherTypeIPv6 {
+			return nil
+		}
+		return decodeIP(frame)
+	case linkTypeLinuxSLL:
+		if len(frame) < 16 {
+			return nil
+		}
+		return decodeIP(frame[16:])
+	case linkTypeNull:
+		if len(frame) < 4 {
+			return nil
+		}
+		return decodeIP(frame[4:])
+	}
+	return nil
+}
+
+func decodeIP(frame []byte) *Packet {
+	if len(frame) < 1 {
+		return nil
+	}
+	p := new(Packet)
+	var segment []byte
+	switch frame[0] >> 4 {
+	case 4:
+		if len(frame) < ipv4HeaderLen {
+			return nil
+		}
+		headerLen := int(frame[0]&0x0F) * 4
+		total := int(binary.BigEndian.Uint16(frame[2:4]))
+		if headerLen < ipv4HeaderLen || total < headerLen || total > len(frame) {
+			return nil
+		}
+		// Fragments are not reassembled
+		if binary.BigEndian.Uint16(frame[6:8])&0x3FFF != 0 {
+			return nil
+		}
+		p.TTL = int(frame[8])
+		p.Proto = frame[9]
+		p.SrcIP = net.IP(append([]byte(nil), frame[12:16]...))
+		p.DstIP = net.IP(append([]byte(nil), frame[16:20]...))
+		segment = frame[headerLen:total]
+	case 6:
+		if len(frame) < ipv6HeaderLen {
+			return nil
+		}
+		total := ipv6HeaderLen + int(binary.BigEndian.Uint16(frame[4:6]))
+		if total > len(frame) {
+			return nil
+		}
+		p.Proto = frame[6]
+		p.TTL = int(frame[7])
+		p.SrcIP = net.IP(append([]byte(nil), frame[8:24]...))
+		p.DstIP = net.IP(append([]byte(nil), frame[24:40]...))
+		segment = frame[ipv6HeaderLen:total]
+	default:
+		return nil
+	}
+
+	switch p.Proto {
+	case protoUDP:
+		if len(segment) < udpHeaderLen {
+			return nil
+		}
+		p.SrcPort = int(binary.BigEndian.Uint16(segment[0:2]))
+		p.DstPort = int(binary.BigEndian.Uint16(segment[2:4]))
+		p.Payload = append([]byte(nil), segment[udpHeaderLen:]...)
+	case protoTCP:
+		if len(segment) < tcpHeaderLen {
+			return nil
+		}
+		offset := int(segment[12]>>4) * 4
+		if offset < tcpHeaderLen || offset > len(segment) {
+			return nil
+		}
+		p.SrcPort = int(binary.BigEndian.Uint16(segment[0:2]))
+		p.DstPort = int(binary.BigEndian.Uint16(segment[2:4]))
+		p.Seq = binary.BigEndian.Uint32(segment[4:8])
+		p.Flags = segment[13]
+		p.Payload = append([]byte(nil), segment[offset:]...)
+	default:
+		return nil
+	}
+	return p
+}
+
+func (p *Packet) IsUDP() bool {
+	return p.Proto == protoUDP
+}
+
+func (p *Packet) IsTCP() bool {
+	return p.Proto == protoTCP
+}
+
+// IsSYN reports whether p opens a TCP connection or answers the opening.
+func (p *Packet) IsSYN() bool {
+	return p.Flags&tcpFlagSYN != 0
+}
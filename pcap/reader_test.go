@@ -0,0 +1,59 @@
+package pcap
+
+import (
+	"bytes"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.pcapng")
+	err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := &net.UDPAddr{IP: net.ParseIP("192.168.1.2"), Port: 11123}
+	remote := &net.UDPAddr{IP: net.ParseIP("203.107.6.88"), Port: 123}
+	sendTime := time.Date(2023, 3, 1, 8, 0, 0, 123456789, time.UTC)
+	rcvTime := sendTime.Add(30 * time.Millisecond)
+	request, reply := []byte{0xDB, 0x00, 0x04, 0xFA}, []byte{0x24, 0x02, 0x03, 0xE9}
+	WriteUDP(local, remote, 0, request, sendTime)
+	WriteUDP(remote, local, 52, reply, rcvTime)
+	WriteSecrets([]byte("CLIENT_TRAFFIC_SECRET_0 00 11"))
+	err = Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	capture, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(capture.Packets) != 2 {
+		t.Fatalf("%d packets read, want 2", len(capture.Packets))
+	}
+	var tests = []struct {
+		src, dst *net.UDPAddr
+		ttl      int
+		payload  []byte
+		ts       time.Time
+	}{
+		{local, remote, defaultTTL, request, sendTime},
+		{remote, local, 52, reply, rcvTime},
+	}
+	for i, test := range tests {
+		p := capture.Packets[i]
+		if !p.IsUDP() || !p.SrcIP.Equal(test.src.IP) || !p.DstIP.Equal(test.dst.IP) ||
+			p.SrcPort != test.src.Port || p.DstPort != test.dst.Port {
+			t.Errorf("packet %d: %s:%d -> %s:%d", i, p.SrcIP, p.SrcPort, p.DstIP, p.DstPort)
+		}
+		if p.TTL != test.ttl || !bytes.Equal(p.Payload, test.payload) || !p.Time.Equal(test.ts) {
+			t.Errorf("packet %d: ttl %d, payload %X, time %s", i, p.TTL, p.Payload, p.Time)
+		}
+	}
+	if string(capture.KeyLog) != "CLIENT_TRAFFIC_SECRET_0 00 11\n" {
+		t.Errorf("key log = %q", capture.KeyLog)
+	}
+}
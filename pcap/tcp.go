@@ -0,0 +1,150 @@
+package pcap
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+type segment struct {
+	fromLocal bool
+	flags     byte
+	payload   []byte
+	ts        time.Time
+}
+
+// TCPConn records the byte streams of a TCP connection as synthesized TCP
+// segments. They are kept in memory and written on Close, after the key log
+// collected through KeyLog, so that readers can decrypt TLS records in order.
+type TCPConn struct {
+	net.Conn
+	mu       sync.Mutex
+	keyLog   *bytes.Buffer
+	segments []segment
+	closed   bool
+}
+
+// CaptureTCP wraps an established connection for capturing. The connection is
+// returned untouched if no capture is running.
+func CaptureTCP(conn net.Conn) net.Conn {
+	if !Enabled() {
+		return conn
+	}
+	now := time.Now()
+	c := &TCPConn{
+		Conn:   conn,
+		keyLog: new(bytes.Buffer),
+		segments: []segment{
+			{fromLocal: true, flags: tcpFlagSYN, ts: now},
+			{fromLocal: false, flags: tcpFlagSYN | tcpFlagACK, ts: now},
+			{fromLocal: true, flags: tcpFlagACK, ts: now},
+		},
+	}
+	return c
+}
+
+// KeyLog returns the writer to be set as tls.Config.KeyLogWriter.
+func (c *TCPConn) KeyLog() io.Writer {
+	return &lockedWriter{mu: &c.mu, buf: c.keyLog}
+}
+
+func (c *TCPConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.record(false, b[:n])
+	}
+	return n, err
+}
+
+func (c *TCPConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.record(true, b[:n])
+	}
+	return n, err
+}
+
+func (c *TCPConn) Close() error {
+	err := c.Conn.Close()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return err
+	}
+	c.closed = true
+	now := time.Now()
+	c.segments = append(c.segments,
+		segment{fromLocal: true, flags: tcpFlagFIN | tcpFlagACK, ts: now},
+		segment{fromLocal: false, flags: tcpFlagFIN | tcpFlagACK, ts: now},
+		segment{fromLocal: true, flags: tcpFlagACK, ts: now})
+	c.flush()
+	return err
+}
+
+func (c *TCPConn) record(fromLocal bool, data []byte) {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(data) > 0 {
+		n := len(data)
+		if n > maxIPv4Payload-tcpHeaderLen {
+			n = maxIPv4Payload - tcpHeaderLen
+		}
+		payload := make([]byte, n)
+		copy(payload, data[:n])
+		c.segments = append(c.segments, segment{
+			fromLocal: fromLocal,
+			flags:     tcpFlagPSH | tcpFlagACK,
+			payload:   payload,
+			ts:        now,
+		})
+		data = data[n:]
+	}
+}
+
+// flush must be called with c.mu held.
+func (c *TCPConn) flush() {
+	local, _ := c.Conn.LocalAddr().(*net.TCPAddr)
+	remote, _ := c.Conn.RemoteAddr().(*net.TCPAddr)
+	if local == nil || remote == nil {
+		return
+	}
+	WriteSecrets(c.keyLog.Bytes())
+
+	// Sequence numbers of the local and the remote side
+	var seq = [2]uint32{0x10000000, 0x20000000}
+	for _, s := range c.segments {
+		from, to, own := local, remote, 0
+		if !s.fromLocal {
+			from, to, own = remote, local, 1
+		}
+		var ack uint32
+		if s.flags&tcpFlagACK != 0 {
+			ack = seq[1-own]
+		}
+		packet := buildTCP(from.IP, to.IP, from.Port, to.Port, seq[own], ack, s.flags, s.payload)
+		seq[own] += uint32(len(s.payload))
+		if s.flags&(tcpFlagSYN|tcpFlagFIN) != 0 {
+			seq[own]++
+		}
+		mu.Lock()
+		if writer != nil {
+			writePacket(packet, s.ts)
+		}
+		mu.Unlock()
+	}
+	c.segments = nil
+}
+
+type lockedWriter struct {
+	mu  *sync.Mutex
+	buf *bytes.Buffer
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
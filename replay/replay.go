@@ -0,0 +1,252 @@
+package replay
+
+import (
+	"active/datastruct"
+	"active/packet"
+	"active/parser"
+	"active/pcap"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	ntpPort     = 123
+	ntsKEPort   = 4460
+	keyLength   = 32
+	ntpMask     = 0b00000111
+	clientMode  = 3
+	exportLabel = "EXPORTER-network-time-security"
+)
+
+// Result holds what a capture contains in the form produced by a live scan.
+type Result struct {
+	NTP     []*datastruct.RcvPayload
+	NTS     []*datastruct.NTSPayload
+	Errors  []error
+	Skipped int
+}
+
+type connKey struct {
+	client string
+	server string
+}
+
+type tcpSegment struct {
+	seq     uint32
+	payload []byte
+}
+
+type tcpConn struct {
+	server    net.IP
+	port      int
+	firstTime time.Time
+	isn       [2]*uint32
+	segments  [2][]tcpSegment
+}
+
+// ReadCapture extracts the NTP replies and the NTS-KE exchanges of a capture.
+func ReadCapture(path string) (*Result, error) {
+	capture, err := pcap.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	res := &Result{Skipped: capture.Skipped}
+	res.NTP = extractNTP(capture.Packets)
+
+	keys := parseKeyLog(capture.KeyLog)
+	conns, order := collectConns(capture.Packets)
+	for _, key := range order {
+		payload, err := decryptConn(conns[key], keys)
+		if err != nil {
+			res.Errors = append(res.Errors, fmt.Errorf("NTS-KE connection %s -> %s: %v", key.client, key.server, err))
+			continue
+		}
+		res.NTS = append(res.NTS, payload)
+	}
+	return res, nil
+}
+
+// extractNTP turns every datagram sent from port 123 into a payload. The send
+// time comes from the latest client request to the same host in the capture,
+// otherwise from the origin timestamp like in the async engine.
+func extractNTP(packets []*pcap.Packet) []*datastruct.RcvPayload {
+	lastSent := make(map[string]time.Time)
+	lastData := make(map[string][]byte)
+	res := make([]*datastruct.RcvPayload, 0)
+	for _, p := range packets {
+		if !p.IsUDP() {
+			continue
+		}
+		if p.DstPort == ntpPort && len(p.Payload) > 0 && p.Payload[0]&ntpMask == clientMode {
+			lastSent[p.DstIP.String()] = p.Time
+			lastData[p.DstIP.String()] = p.Payload
+			continue
+		}
+		if p.SrcPort != ntpPort {
+			continue
+		}
+		host := p.SrcIP.String()
+		payload := &datastruct.RcvPayload{
+			Host:    host,
+			Port:    p.SrcPort,
+			Len:     len(p.Payload),
+			RcvTime: p.Time,
+			RcvData: p.Payload,
+		}
+		payload.SetTTL(p.TTL)
+		payload.CheckKoD()
+		if sendTime, ok := lastSent[host]; ok {
+			payload.SendTime = sendTime
+			payload.SendData = lastData[host]
+			payload.SendLen = len(payload.SendData)
+		} else if len(p.Payload) >= parser.HeaderLength {
+			// The capture may be old, its own clock places the era
+			origin := packet.Timestamp(binary.BigEndian.Uint64(p.Payload[24:32]))
+			payload.SendTime = origin.TimeNear(p.Time)
+		}
+		if len(p.Payload) < parser.HeaderLength {
+			payload.Err = fmt.Errorf("header length %d less than 48", len(p.Payload))
+		}
+		res = append(res, payload)
+	}
+	return res
+}
+
+func collectConns(packets []*pcap.Packet) (map[connKey]*tcpConn, []connKey) {
+	conns := make(map[connKey]*tcpConn)
+	order := make([]connKey, 0)
+	for _, p := range packets {
+		if !p.IsTCP() || (p.DstPort != ntsKEPort && p.SrcPort != ntsKEPort) {
+			continue
+		}
+		// Direction 0 is client to server, 1 is server to client
+		dir := 0
+		client := net.JoinHostPort(p.SrcIP.String(), strconv.Itoa(p.SrcPort))
+		server := net.JoinHostPort(p.DstIP.String(), strconv.Itoa(p.DstPort))
+		serverIP, serverPort := p.DstIP, p.DstPort
+		if p.SrcPort == ntsKEPort {
+			dir = 1
+			client, server = server, client
+			serverIP, serverPort = p.SrcIP, p.SrcPort
+		}
+		key := connKey{client: client, server: server}
+		c, ok := conns[key]
+		if !ok {
+			c = &tcpConn{server: serverIP, port: serverPort, firstTime: p.Time}
+			conns[key] = c
+			order = append(order, key)
+		}
+		if p.IsSYN() {
+			isn := p.Seq + 1
+			c.isn[dir] = &isn
+			continue
+		}
+		if len(p.Payload) > 0 {
+			c.segments[dir] = append(c.segments[dir], tcpSegment{seq: p.Seq, payload: p.Payload})
+		}
+	}
+	return conns, order
+}
+
+// stream reassembles one direction, dropping retransmissions and stopping at
+// the first gap.
+func (c *tcpConn) stream(dir int) []byte {
+	segments := c.segments[dir]
+	if len(segments) == 0 {
+		return nil
+	}
+	base := segments[0].seq
+	if c.isn[dir] != nil {
+		base = *c.isn[dir]
+	}
+	sort.SliceStable(segments, func(i, j int) bool {
+		return segments[i].seq-base < segments[j].seq-base
+	})
+	res := make([]byte, 0)
+	next := uint32(0)
+	for _, s := range segments {
+		start := s.seq - base
+		end := start + uint32(len(s.payload))
+		if end <= next {
+			continue
+		}
+		if start > next {
+			break
+		}
+		res = append(res, s.payload[next-start:]...)
+		next = end
+	}
+	return res
+}
+
+func decryptConn(c *tcpConn, keys keyLog) (*datastruct.NTSPayload, error) {
+	clientRecords := splitRecords(c.stream(0))
+	serverRecords := splitRecords(c.stream(1))
+	if len(clientRecords) == 0 || len(serverRecords) == 0 {
+		return nil, errors.New("no TLS records in both directions")
+	}
+	clientRandom, _, err := helloRandom(clientRecords, handshakeClientHello)
+	if err != nil {
+		return nil, err
+	}
+	_, suiteID, err := helloRandom(serverRecords, handshakeServerHello)
+	if err != nil {
+		return nil, err
+	}
+	s, err := suiteByID(suiteID)
+	if err != nil {
+		return nil, err
+	}
+	secrets, ok := keys[hex.EncodeToString(clientRandom)]
+	if !ok {
+		return nil, errors.New("no key log entry for this connection")
+	}
+
+	server, err := newHalfConn(s, secrets[serverHandshakeLabel], secrets[serverTrafficLabel])
+	if err != nil {
+		return nil, err
+	}
+	data, err := server.decrypt(serverRecords)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &datastruct.NTSPayload{
+		Host:       c.server.String(),
+		Port:       c.port,
+		CertDomain: server.certificateDomain(),
+		Len:        len(data),
+		RcvData:    data,
+	}
+
+	exporterSecret := secrets[exporterLabel]
+	aeadID := negotiatedAEAD(data)
+	if exporterSecret != nil && aeadID > 0 {
+		ctx := []byte{0x00, 0x00, 0x00, aeadID}
+		res.C2SKey = exportKeyingMaterial(s, exporterSecret, exportLabel, append(ctx, 0x00), keyLength)
+		res.S2CKey = exportKeyingMaterial(s, exporterSecret, exportLabel, append(ctx, 0x01), keyLength)
+	}
+	return res, nil
+}
+
+func negotiatedAEAD(data []byte) byte {
+	info := datastruct.DetectInfo{
+		AEADList:      make([]bool, 34),
+		ServerPortSet: make(map[string]struct{}),
+	}
+	if parser.ParseDetectInfo(data, info) != nil {
+		return 0
+	}
+	for id, ok := range info.AEADList {
+		if ok {
+			return byte(id)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,277 @@
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+const (
+	recordHeaderLen       = 5
+	recordChangeCipher    = 20
+	recordHandshake       = 22
+	recordApplicationData = 23
+	handshakeClientHello  = 1
+	handshakeServerHello  = 2
+	handshakeCertificate  = 11
+	randomLen             = 32
+	aes128GCMSHA256       = 0x1301
+	aes256GCMSHA384       = 0x1302
+	chacha20Poly1305      = 0x1303
+	clientHandshakeLabel  = "CLIENT_HANDSHAKE_TRAFFIC_SECRET"
+	serverHandshakeLabel  = "SERVER_HANDSHAKE_TRAFFIC_SECRET"
+	clientTrafficLabel    = "CLIENT_TRAFFIC_SECRET_0"
+	serverTrafficLabel    = "SERVER_TRAFFIC_SECRET_0"
+	exporterLabel         = "EXPORTER_SECRET"
+)
+
+// keyLog maps a client random in hex to the secrets logged for it, by label.
+type keyLog map[string]map[string][]byte
+
+type suite struct {
+	id     uint16
+	keyLen int
+	hash   func() hash.Hash
+}
+
+// halfConn decrypts the records of one direction of a TLS 1.3 connection. It
+// starts with the handshake traffic secret and moves on to the application
+// traffic secret once a record no longer opens with the former.
+type halfConn struct {
+	suite       *suite
+	appSecret   []byte
+	aead        cipher.AEAD
+	iv          []byte
+	seq         uint64
+	application bool
+	handshake   *bytes.Buffer
+}
+
+func parseKeyLog(data []byte) keyLog {
+	res := make(keyLog)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		secret, err := hex.DecodeString(fields[2])
+		if err != nil {
+			continue
+		}
+		random := strings.ToLower(fields[1])
+		if res[random] == nil {
+			res[random] = make(map[string][]byte)
+		}
+		res[random][fields[0]] = secret
+	}
+	return res
+}
+
+func suiteByID(id uint16) (*suite, error) {
+	switch id {
+	case aes128GCMSHA256:
+		return &suite{id: id, keyLen: 16, hash: sha256.New}, nil
+	case aes256GCMSHA384:
+		return &suite{id: id, keyLen: 32, hash: sha512.New384}, nil
+	case chacha20Poly1305:
+		return nil, errors.New("cipher suite TLS_CHACHA20_POLY1305_SHA256 is not supported")
+	}
+	return nil, fmt.Errorf("unsupported cipher suite %04X", id)
+}
+
+// splitRecords cuts a TLS byte stream into records, ignoring an incomplete
+// record at the end.
+func splitRecords(stream []byte) [][]byte {
+	res := make([][]byte, 0)
+	for len(stream) >= recordHeaderLen {
+		n := recordHeaderLen + int(binary.BigEndian.Uint16(stream[3:5]))
+		if n > len(stream) {
+			break
+		}
+		res = append(res, stream[:n])
+		stream = stream[n:]
+	}
+	return res
+}
+
+// helloRandom returns the random of the ClientHello or ServerHello that the
+// first record of stream carries, along with the cipher suite of a ServerHello.
+func helloRandom(records [][]byte, msgType byte) ([]byte, uint16, error) {
+	for _, r := range records {
+		if r[0] != recordHandshake {
+			continue
+		}
+		body := r[recordHeaderLen:]
+		// Handshake type (1), length (3), legacy version (2), random (32)
+		if len(body) < 4+2+randomLen || body[0] != msgType {
+			return nil, 0, fmt.Errorf("handshake message type %d not found", msgType)
+		}
+		random := body[6 : 6+randomLen]
+		if msgType != handshakeServerHello {
+			return random, 0, nil
+		}
+		rest := body[6+randomLen:]
+		if len(rest) < 1 || len(rest) < 1+int(rest[0])+2 {
+			return nil, 0, errors.New("incomplete ServerHello")
+		}
+		rest = rest[1+int(rest[0]):]
+		return random, binary.BigEndian.Uint16(rest[:2]), nil
+	}
+	return nil, 0, errors.New("no handshake record found")
+}
+
+func newHalfConn(s *suite, handshakeSecret, appSecret []byte) (*halfConn, error) {
+	if handshakeSecret == nil || appSecret == nil {
+		return nil, errors.New("traffic secrets missing in key log")
+	}
+	h := &halfConn{suite: s, appSecret: appSecret, handshake: new(bytes.Buffer)}
+	err := h.setSecret(handshakeSecret)
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *halfConn) setSecret(secret []byte) error {
+	key := expandLabel(h.suite.hash, secret, "key", nil, h.suite.keyLen)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	h.aead, err = cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	h.iv = expandLabel(h.suite.hash, secret, "iv", nil, h.aead.NonceSize())
+	h.seq = 0
+	return nil
+}
+
+// decrypt returns the application data carried by the encrypted records. The
+// encrypted handshake messages are kept aside, the compatibility
+// ChangeCipherSpec is skipped.
+func (h *halfConn) decrypt(records [][]byte) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	for _, r := range records {
+		if r[0] != recordApplicationData {
+			continue
+		}
+		plain, err := h.open(r)
+		if err != nil && !h.application {
+			h.application = true
+			err = h.setSecret(h.appSecret)
+			if err != nil {
+				return nil, err
+			}
+			plain, err = h.open(r)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot decrypt TLS record: %v", err)
+		}
+		// The inner plaintext ends with the content type followed by zero padding
+		end := len(plain) - 1
+		for end >= 0 && plain[end] == 0 {
+			end--
+		}
+		if end < 0 {
+			return nil, errors.New("TLS record without content type")
+		}
+		switch plain[end] {
+		case recordApplicationData:
+			buf.Write(plain[:end])
+		case recordHandshake:
+			h.handshake.Write(plain[:end])
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (h *halfConn) open(record []byte) ([]byte, error) {
+	nonce := make([]byte, len(h.iv))
+	copy(nonce, h.iv)
+	for i := 0; i < 8; i++ {
+		nonce[len(nonce)-1-i] ^= byte(h.seq >> (8 * i))
+	}
+	plain, err := h.aead.Open(nil, nonce, record[recordHeaderLen:], record[:recordHeaderLen])
+	if err != nil {
+		return nil, err
+	}
+	h.seq++
+	return plain, nil
+}
+
+// certificateDomain returns the common name of the first certificate sent in
+// the encrypted handshake, or an empty string if there is none.
+func (h *halfConn) certificateDomain() string {
+	data := h.handshake.Bytes()
+	for len(data) >= 4 {
+		n := int(data[1])<<16 | int(data[2])<<8 | int(data[3])
+		if 4+n > len(data) {
+			return ""
+		}
+		body := data[4 : 4+n]
+		if data[0] != handshakeCertificate {
+			data = data[4+n:]
+			continue
+		}
+		// Request context, then the certificate list with 3-byte lengths
+		if len(body) < 1 || len(body) < 1+int(body[0])+6 {
+			return ""
+		}
+		list := body[1+int(body[0])+3:]
+		certLen := int(list[0])<<16 | int(list[1])<<8 | int(list[2])
+		if 3+certLen > len(list) {
+			return ""
+		}
+		cert, err := x509.ParseCertificate(list[3 : 3+certLen])
+		if err != nil {
+			return ""
+		}
+		return cert.Subject.CommonName
+	}
+	return ""
+}
+
+// exportKeyingMaterial implements the TLS 1.3 exporter of RFC 8446 section 7.5
+// from a logged exporter secret.
+func exportKeyingMaterial(s *suite, exporterSecret []byte, label string, context []byte, length int) []byte {
+	empty := s.hash().Sum(nil)
+	secret := expandLabel(s.hash, exporterSecret, label, empty, len(empty))
+	h := s.hash()
+	h.Write(context)
+	return expandLabel(s.hash, secret, "exporter", h.Sum(nil), length)
+}
+
+// expandLabel implements HKDF-Expand-Label of RFC 8446 section 7.1.
+func expandLabel(hashFunc func() hash.Hash, secret []byte, label string, context []byte, length int) []byte {
+	fullLabel := "tls13 " + label
+	info := make([]byte, 0, 4+len(fullLabel)+len(context))
+	info = append(info, byte(length>>8), byte(length), byte(len(fullLabel)))
+	info = append(info, fullLabel...)
+	info = append(info, byte(len(context)))
+	info = append(info, context...)
+
+	res := make([]byte, 0, length)
+	var prev []byte
+	for counter := byte(1); len(res) < length; counter++ {
+		mac := hmac.New(hashFunc, secret)
+		mac.Write(prev)
+		mac.Write(info)
+		mac.Write([]byte{counter})
+		prev = mac.Sum(nil)
+		res = append(res, prev...)
+	}
+	return res[:length]
+}
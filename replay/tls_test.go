@@ -0,0 +1,133 @@
+package replay
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"io"
+	"math/big"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingConn struct {
+	net.Conn
+	mu      sync.Mutex
+	written *bytes.Buffer
+}
+
+func (c *recordingConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	c.written.Write(b)
+	c.mu.Unlock()
+	return c.Conn.Write(b)
+}
+
+func TestDecryptTLS13(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "nts.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientEnd, serverEnd := net.Pipe()
+	client := &recordingConn{Conn: clientEnd, written: new(bytes.Buffer)}
+	server := &recordingConn{Conn: serverEnd, written: new(bytes.Buffer)}
+	keyLogBuf := new(bytes.Buffer)
+	response := []byte{0x80, 0x01, 0x00, 0x02, 0x00, 0x00, 0x80, 0x04, 0x00, 0x02, 0x00, 0x0F, 0x80, 0x00, 0x00, 0x00}
+
+	go func() {
+		conn := tls.Server(server, &tls.Config{
+			Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+			MinVersion:   tls.VersionTLS13,
+			CipherSuites: []uint16{tls.TLS_AES_128_GCM_SHA256},
+		})
+		buf := make([]byte, 64)
+		_, _ = conn.Read(buf)
+		_, _ = conn.Write(response)
+		_ = conn.Close()
+	}()
+
+	conn := tls.Client(client, &tls.Config{
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS13,
+		KeyLogWriter:       keyLogBuf,
+	})
+	_, err = conn.Write([]byte{0x80, 0x00, 0x00, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(conn)
+	if err != nil && len(got) == 0 {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+
+	clientRecords := splitRecords(client.written.Bytes())
+	server.mu.Lock()
+	serverRecords := splitRecords(server.written.Bytes())
+	server.mu.Unlock()
+	clientRandom, _, err := helloRandom(clientRecords, handshakeClientHello)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, suiteID, err := helloRandom(serverRecords, handshakeServerHello)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := suiteByID(suiteID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets := parseKeyLog(keyLogBuf.Bytes())[hex.EncodeToString(clientRandom)]
+	h, err := newHalfConn(s, secrets[serverHandshakeLabel], secrets[serverTrafficLabel])
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := h.decrypt(serverRecords)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, response) {
+		t.Errorf("decrypted %X, want %X", data, response)
+	}
+	if domain := h.certificateDomain(); domain != "nts.example.com" {
+		t.Errorf("certificate domain = %q", domain)
+	}
+}
+
+func TestExpandLabel(t *testing.T) {
+	// Server handshake traffic keys of the simple 1-RTT handshake in RFC 8448
+	secret, _ := hex.DecodeString("b67b7d690cc16c4e75e54213cb2d37b4e9c912bcded9105d42befd59d391ad38")
+	var tests = []struct {
+		label  string
+		length int
+		want   string
+	}{
+		{"key", 16, "3fce516009c21727d0f2e4e86ee403bc"},
+		{"iv", 12, "5d313eb2671276ee13000b30"},
+	}
+	for _, test := range tests {
+		got := hex.EncodeToString(expandLabel(sha256.New, secret, test.label, nil, test.length))
+		if got != test.want {
+			t.Errorf("expandLabel(%s) = %s", test.label, got)
+		}
+	}
+}
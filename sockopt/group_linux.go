@@ -0,0 +1,38 @@
+package sockopt
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+// JoinGroup makes conn join one more multicast group on ifi, or on the
+// interface chosen by the kernel when ifi is nil.
+func JoinGroup(conn *net.UDPConn, ifi *net.Interface, group net.IP) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	index := 0
+	if ifi != nil {
+		index = ifi.Index
+	}
+	var optErr error
+	err = raw.Control(func(fd uintptr) {
+		if ip4 := group.To4(); ip4 != nil {
+			mreq := &syscall.IPMreqn{Ifindex: int32(index)}
+			copy(mreq.Multiaddr[:], ip4)
+			optErr = syscall.SetsockoptIPMreqn(int(fd), syscall.IPPROTO_IP, syscall.IP_ADD_MEMBERSHIP, mreq)
+		} else if ip6 := group.To16(); ip6 != nil {
+			mreq := &syscall.IPv6Mreq{Interface: uint32(index)}
+			copy(mreq.Multiaddr[:], ip6)
+			optErr = syscall.SetsockoptIPv6Mreq(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_JOIN_GROUP, mreq)
+		} else {
+			optErr = errors.New("invalid multicast group " + group.String())
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return optErr
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package sockopt
+
+import "net"
+
+// JoinGroup is a no-op outside Linux, only the group conn was opened with is joined.
+func JoinGroup(_ *net.UDPConn, _ *net.Interface, _ net.IP) error {
+	return nil
+}
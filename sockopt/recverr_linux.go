@@ -0,0 +1,88 @@
+package sockopt
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	// sizeof(struct sock_extended_err)
+	extendedErrLength = 16
+)
+
+// EnableRecvErr asks the kernel to queue ICMP errors of conn, so that port
+// unreachable messages can be picked up by ReadErrQueue.
+func EnableRecvErr(conn *net.UDPConn) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var optErr error
+	err = raw.Control(func(fd uintptr) {
+		optErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_RECVERR, 1)
+		// The socket may be dual-stack, in which case IPv4 errors arrive through IPv6
+		_ = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_RECVERR, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return optErr
+}
+
+// ReadErrQueue drains the error queue of conn without blocking and returns the
+// destinations that answered with ICMP port unreachable.
+func ReadErrQueue(conn *net.UDPConn) ([]net.IP, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]net.IP, 0)
+	buf := make([]byte, 128)
+	oob := make([]byte, 512)
+	err = raw.Read(func(fd uintptr) bool {
+		for {
+			_, oobn, _, from, err := syscall.Recvmsg(int(fd), buf, oob, syscall.MSG_ERRQUEUE|syscall.MSG_DONTWAIT)
+			if err != nil {
+				// EAGAIN means the queue is empty
+				return true
+			}
+			if !isConnRefused(oob[:oobn]) {
+				continue
+			}
+			switch sa := from.(type) {
+			case *syscall.SockaddrInet4:
+				res = append(res, net.IPv4(sa.Addr[0], sa.Addr[1], sa.Addr[2], sa.Addr[3]))
+			case *syscall.SockaddrInet6:
+				ip := make(net.IP, net.IPv6len)
+				copy(ip, sa.Addr[:])
+				res = append(res, ip)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func isConnRefused(oob []byte) bool {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return false
+	}
+	for _, msg := range msgs {
+		isRecvErr := (msg.Header.Level == syscall.IPPROTO_IP && msg.Header.Type == syscall.IP_RECVERR) ||
+			(msg.Header.Level == syscall.IPPROTO_IPV6 && msg.Header.Type == syscall.IPV6_RECVERR)
+		if !isRecvErr || len(msg.Data) < extendedErrLength {
+			continue
+		}
+		// ee_errno is the first field, in host byte order
+		errno := syscall.Errno(*(*uint32)(unsafe.Pointer(&msg.Data[0])))
+		if errors.Is(errno, syscall.ECONNREFUSED) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package sockopt
+
+import "net"
+
+// EnableRecvErr is a no-op outside Linux, closed ports then look filtered.
+func EnableRecvErr(_ *net.UDPConn) error {
+	return nil
+}
+
+// ReadErrQueue always returns an empty list outside Linux.
+func ReadErrQueue(_ *net.UDPConn) ([]net.IP, error) {
+	return nil, nil
+}
@@ -0,0 +1,6 @@
+package sockopt
+
+const (
+	// OOBSize is large enough for the control messages enabled in this package
+	OOBSize = 128
+)
@@ -0,0 +1,46 @@
+package sockopt
+
+import (
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+// EnableRecvTTL makes every datagram read from conn carry the TTL (IPv4) or
+// hop limit (IPv6) it arrived with as a control message.
+func EnableRecvTTL(conn *net.UDPConn) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var optErr error
+	err = raw.Control(func(fd uintptr) {
+		optErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_RECVTTL, 1)
+		err6 := syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_RECVHOPLIMIT, 1)
+		if optErr != nil {
+			// IPv6-only sockets refuse IP_RECVTTL
+			optErr = err6
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return optErr
+}
+
+// ParseTTL returns the TTL or hop limit found in oob, or 0 if there is none.
+func ParseTTL(oob []byte) int {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return 0
+	}
+	for _, msg := range msgs {
+		isTTL := (msg.Header.Level == syscall.IPPROTO_IP && msg.Header.Type == syscall.IP_TTL) ||
+			(msg.Header.Level == syscall.IPPROTO_IPV6 && msg.Header.Type == syscall.IPV6_HOPLIMIT)
+		if isTTL && len(msg.Data) >= 4 {
+			// The value is a C int in host byte order
+			return int(*(*int32)(unsafe.Pointer(&msg.Data[0])))
+		}
+	}
+	return 0
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package sockopt
+
+import "net"
+
+// EnableRecvTTL is a no-op outside Linux, the TTL of replies stays unknown.
+func EnableRecvTTL(_ *net.UDPConn) error {
+	return nil
+}
+
+// ParseTTL always returns 0 outside Linux.
+func ParseTTL(_ []byte) int {
+	return 0
+}
@@ -0,0 +1,116 @@
+package udpdetect
+
+import (
+	"active/datastruct"
+	"active/pcap"
+	"active/utils"
+	"encoding/binary"
+	"errors"
+	"net"
+	"time"
+)
+
+// CharacterizeRateLimit sends bursts of 1, 2, 4, ... requests to host, each
+// burst spread over the same interval and followed by a cooldown, until a
+// burst is partly dropped or kissed or maxBurst is reached.
+func CharacterizeRateLimit(host string, maxBurst int, interval time.Duration) *datastruct.RateReport {
+	report := &datastruct.RateReport{Host: host}
+	for size := 1; size <= maxBurst; size *= 2 {
+		if size > 1 {
+			time.Sleep(cooldown)
+		}
+		b, err := burst(host, size, interval)
+		if err != nil {
+			report.Err = err
+			return report
+		}
+		if size == 1 && b.Replies+b.KoD == 0 {
+			report.Err = errors.New("no reply to a single request")
+			return report
+		}
+		report.Bursts = append(report.Bursts, b)
+		if b.Degraded() {
+			break
+		}
+	}
+	return report
+}
+
+// burst sends size requests evenly spread over interval and counts the
+// replies, each request carrying its own transmit timestamp so that the
+// origin timestamp of a reply tells which request it answers.
+func burst(host string, size int, interval time.Duration) (datastruct.BurstResult, error) {
+	res := datastruct.BurstResult{Size: size, Interval: interval}
+	udpAddr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(host, "123"))
+	if err != nil {
+		return res, err
+	}
+	conn, err := net.DialUDP("udp", nil, udpAddr)
+	if err != nil {
+		return res, err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	localAddr, _ := conn.LocalAddr().(*net.UDPAddr)
+
+	base := uint64(time.Now().UnixNano()) &^ 0xFFFF
+	err = conn.SetReadDeadline(time.Now().Add(interval + timeout))
+	if err != nil {
+		return res, err
+	}
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		answered := make(map[uint64]struct{})
+		for len(answered) < size {
+			buf := make([]byte, maxDatagram)
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+			if n < 48 {
+				continue
+			}
+			origin := binary.BigEndian.Uint64(buf[24:32])
+			if _, ok := answered[origin]; ok || origin < base || origin >= base+uint64(size) {
+				continue
+			}
+			answered[origin] = struct{}{}
+			pcap.WriteUDP(udpAddr, localAddr, 0, buf[:n], time.Now())
+			res.RcvLen += n
+			if _, ok := utils.KissCode(buf[:n]); ok {
+				res.KoD++
+			} else {
+				res.Replies++
+			}
+		}
+	}()
+
+	spacing := interval / time.Duration(size)
+	sendLen := 0
+	for i := 0; i < size; i++ {
+		req := utils.NewPacketBuilder().TransmitTimestamp(base + uint64(i)).Build()
+		_, err = conn.Write(req)
+		if err == nil {
+			sendLen += len(req)
+			pcap.WriteUDP(localAddr, udpAddr, 0, req, time.Now())
+		}
+		if i < size-1 {
+			time.Sleep(spacing)
+		}
+	}
+	<-doneCh
+	res.SendLen = sendLen
+	if res.Replies+res.KoD > 0 {
+		datastruct.RecordAmplification(&datastruct.Amplification{
+			IP:          host,
+			Probe:       datastruct.ProbeBurst,
+			SendPackets: size,
+			SendBytes:   sendLen,
+			RcvPackets:  res.Replies + res.KoD,
+			RcvBytes:    res.RcvLen,
+		})
+	}
+	return res, nil
+}
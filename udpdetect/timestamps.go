@@ -0,0 +1,165 @@
+package udpdetect
+
+import (
+	"active/datastruct"
+	"active/kod"
+	"active/pcap"
+	"active/utils"
+	"crypto/rand"
+	"encoding/binary"
+	"net"
+	"time"
+)
+
+// ProbeTimestamps runs three exchanges with host. The first, a basic request
+// with a random transmit timestamp, tells whether the origin of the reply
+// echoes it. The second, on the same socket, asks for interleaved mode as
+// chrony does, with the receive timestamp of the first reply as origin and
+// our own receive time of it as receive timestamp. The third comes from a
+// second socket, standing for another client, and checks that the reply does
+// not carry any timestamp the first client sent.
+func ProbeTimestamps(host string) *datastruct.TimestampReport {
+	r := &datastruct.TimestampReport{Host: host, Echo: datastruct.OriginNone, Interleaved: datastruct.OriginNone}
+	udpAddr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(host, "123"))
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	first, err := net.DialUDP("udp", nil, udpAddr)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	defer func() {
+		_ = first.Close()
+	}()
+
+	xmt1 := randomTimestamp()
+	reply1, rcvTime, err := exchangeTimestamps(first, udpAddr, utils.NewPacketBuilder().Version(4).
+		TransmitTimestamp(xmt1).Build())
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	if r.KissCode = kissOf(host, reply1); r.KissCode != "" {
+		return r
+	}
+	r.Echo = originOf(reply1, xmt1, 0)
+
+	rcv1 := utils.NTPTimestamp(rcvTime)
+	xmt2 := randomTimestamp()
+	reply2, _, err := exchangeTimestamps(first, udpAddr, utils.NewPacketBuilder().Version(4).
+		OriginTimestamp(binary.BigEndian.Uint64(reply1[32:40])).ReceiveTimestamp(rcv1).
+		TransmitTimestamp(xmt2).Build())
+	if err == nil {
+		switch originOf(reply2, xmt2, rcv1) {
+		case datastruct.OriginInterleaved:
+			r.Interleaved = datastruct.OriginInterleaved
+		case datastruct.OriginEcho:
+			r.Interleaved = datastruct.OriginBasic
+		default:
+			r.Interleaved = datastruct.OriginOther
+		}
+	}
+
+	second, err := net.DialUDP("udp", nil, udpAddr)
+	if err != nil {
+		return r
+	}
+	defer func() {
+		_ = second.Close()
+	}()
+	xmt3 := randomTimestamp()
+	reply3, _, err := exchangeTimestamps(second, udpAddr, utils.NewPacketBuilder().Version(4).
+		TransmitTimestamp(xmt3).Build())
+	if err != nil {
+		return r
+	}
+	for _, sent := range []struct {
+		field string
+		ts    uint64
+	}{
+		{"transmit timestamp", xmt1},
+		{"transmit timestamp", xmt2},
+		{"receive timestamp", rcv1},
+	} {
+		for _, offset := range []int{16, 24, 32, 40} {
+			if binary.BigEndian.Uint64(reply3[offset:offset+8]) == sent.ts {
+				r.Leak = true
+				r.LeakedField = sent.field
+			}
+		}
+	}
+	return r
+}
+
+// exchangeTimestamps sends req on conn and returns the first reply of a full
+// header with the time it was received.
+func exchangeTimestamps(conn *net.UDPConn, udpAddr *net.UDPAddr, req []byte) ([]byte, time.Time, error) {
+	localAddr, _ := conn.LocalAddr().(*net.UDPAddr)
+	if err := kod.Wait(udpAddr.IP.String()); err != nil {
+		return nil, time.Time{}, err
+	}
+	_, err := conn.Write(req)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	pcap.WriteUDP(localAddr, udpAddr, 0, req, time.Now())
+	err = conn.SetReadDeadline(time.Now().Add(timeout))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	for {
+		buf := make([]byte, maxDatagram)
+		n, err := conn.Read(buf)
+		rcvTime := time.Now()
+		if err != nil {
+			return nil, rcvTime, err
+		}
+		pcap.WriteUDP(udpAddr, localAddr, 0, buf[:n], rcvTime)
+		if n >= 48 {
+			datastruct.RecordAmplification(&datastruct.Amplification{
+				IP:          udpAddr.IP.String(),
+				Probe:       datastruct.ProbeInterleaved,
+				SendPackets: 1,
+				SendBytes:   len(req),
+				RcvPackets:  1,
+				RcvBytes:    n,
+			})
+			return buf[:n], rcvTime, nil
+		}
+	}
+}
+
+// originOf compares the origin timestamp of a reply with the transmit
+// timestamp of the request and with the receive timestamp it carried.
+func originOf(reply []byte, xmt, rcv uint64) string {
+	origin := binary.BigEndian.Uint64(reply[24:32])
+	switch {
+	case origin == xmt:
+		return datastruct.OriginEcho
+	case rcv != 0 && origin == rcv:
+		return datastruct.OriginInterleaved
+	case origin == 0:
+		return datastruct.OriginZero
+	default:
+		return datastruct.OriginOther
+	}
+}
+
+func kissOf(host string, reply []byte) string {
+	code, ok := utils.KissCode(reply)
+	if !ok {
+		return ""
+	}
+	kod.Record(host, code)
+	return code
+}
+
+func randomTimestamp() uint64 {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return uint64(time.Now().UnixNano())
+	}
+	return binary.BigEndian.Uint64(buf)
+}
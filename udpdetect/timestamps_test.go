@@ -0,0 +1,33 @@
+package udpdetect
+
+import (
+	"active/datastruct"
+	"encoding/binary"
+	"testing"
+)
+
+func TestOriginOf(t *testing.T) {
+	const xmt, rcv = 0xE8000001_12345678, 0xE8000001_00ABCDEF
+	var tests = []struct {
+		name   string
+		origin uint64
+		rcv    uint64
+		want   string
+	}{
+		{"echo", xmt, 0, datastruct.OriginEcho},
+		{"echo of interleaved request", xmt, rcv, datastruct.OriginEcho},
+		{"interleaved", rcv, rcv, datastruct.OriginInterleaved},
+		{"zero", 0, 0, datastruct.OriginZero},
+		{"zero to interleaved request", 0, rcv, datastruct.OriginZero},
+		{"other", 0xE8000000_00000001, 0, datastruct.OriginOther},
+		{"receive timestamp of basic request", rcv, 0, datastruct.OriginOther},
+	}
+	for _, tt := range tests {
+		reply := make([]byte, 48)
+		reply[0] = 4<<3 | 4
+		binary.BigEndian.PutUint64(reply[24:32], tt.origin)
+		if got := originOf(reply, xmt, tt.rcv); got != tt.want {
+			t.Errorf("%s: originOf() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -3,11 +3,16 @@ package udpdetect
 import (
 	"active/addr"
 	"active/datastruct"
+	"active/kod"
+	"active/pcap"
+	"active/sockopt"
 	"active/utils"
+	"errors"
 	"fmt"
 	"github.com/spf13/viper"
 	"net"
 	"sync"
+	"syscall"
 	"time"
 )
 
@@ -15,20 +20,47 @@ const (
 	configPath       = "../resource/"
 	timeoutKey       = "detection.rcv_header.timeout"
 	batchSizeKey     = "detection.send_udp.batch_size"
+	packetGapKey     = "detection.rcv_header.packet_gap"
+	maxBurstKey      = "detection.rate_limit.max_burst"
+	burstIntervalKey = "detection.rate_limit.interval"
+	cooldownKey      = "detection.rate_limit.cooldown"
 	defaultTimeout   = 3000
 	defaultBatchSize = 256
+	defaultPacketGap = 1000
+	defaultMaxBurst  = 32
+	defaultInterval  = 1000
+	defaultCooldown  = 8000
+	maxDatagram      = 1500
 )
 
 var (
-	timeout time.Duration
+	timeout       time.Duration
+	packetGap     time.Duration
+	MaxBurst      int
+	BurstInterval time.Duration
+	cooldown      time.Duration
 )
 
+// Probe describes what is sent to each host. The requests are tried in order
+// until one gets a reply, and a multi-packet probe keeps reading until no
+// datagram arrives for the packet gap.
+type Probe struct {
+	Requests [][]byte
+	Multi    bool
+	// Type is the probe type recorded in amplification results, ProbeMode3 if empty
+	Type string
+}
+
 func init() {
 	viper.AddConfigPath(configPath)
 	viper.SetConfigType("yaml")
 	viper.SetConfigName("properties")
 	viper.SetDefault(timeoutKey, defaultTimeout)
 	viper.SetDefault(batchSizeKey, defaultBatchSize)
+	viper.SetDefault(packetGapKey, defaultPacketGap)
+	viper.SetDefault(maxBurstKey, defaultMaxBurst)
+	viper.SetDefault(burstIntervalKey, defaultInterval)
+	viper.SetDefault(cooldownKey, defaultCooldown)
 	err := viper.ReadInConfig()
 	if err != nil {
 		fmt.Printf("error reading resource file: %v", err)
@@ -39,9 +71,25 @@ func init() {
 		milli = defaultTimeout
 	}
 	timeout = time.Millisecond * milli
+	milli = time.Duration(viper.GetInt64(packetGapKey))
+	if milli == 0 {
+		milli = defaultPacketGap
+	}
+	packetGap = time.Millisecond * milli
+	MaxBurst = viper.GetInt(maxBurstKey)
+	BurstInterval = time.Millisecond * time.Duration(viper.GetInt64(burstIntervalKey))
+	cooldown = time.Millisecond * time.Duration(viper.GetInt64(cooldownKey))
 }
 
 func DialNetworkNTPWithBatchSize(cidr string, batchSize int) <-chan *datastruct.RcvPayload {
+	return DialNetworkWithBatchSize(cidr, batchSize, Probe{Requests: [][]byte{utils.FixedData()}})
+}
+
+func DialNetwork(cidr string, probe Probe) <-chan *datastruct.RcvPayload {
+	return DialNetworkWithBatchSize(cidr, viper.GetInt(batchSizeKey), probe)
+}
+
+func DialNetworkWithBatchSize(cidr string, batchSize int, probe Probe) <-chan *datastruct.RcvPayload {
 	generator, err := addr.NewModuloGenerator(cidr)
 	if err != nil {
 		return nil
@@ -59,13 +107,13 @@ func DialNetworkNTPWithBatchSize(cidr string, batchSize int) <-chan *datastruct.
 	for i := 0; i < batchNum; i++ {
 		for j := 0; j < batchSize; j++ {
 			hostStr := generator.NextHost()
-			go writeToAddr(hostStr+":123", dataCh, wg)
+			go writeToAddr(hostStr, probe, dataCh, wg)
 		}
 		time.Sleep(timeout)
 	}
 	for generator.HasNext() {
 		hostStr := generator.NextHost()
-		go writeToAddr(hostStr+":123", dataCh, wg)
+		go writeToAddr(hostStr, probe, dataCh, wg)
 	}
 	go func() {
 		wg.Wait()
@@ -78,44 +126,122 @@ func DialNetworkNTP(cidr string) <-chan *datastruct.RcvPayload {
 	return DialNetworkNTPWithBatchSize(cidr, viper.GetInt(batchSizeKey))
 }
 
-func writeToAddr(addr string, ch chan<- *datastruct.RcvPayload, wg *sync.WaitGroup) {
+func writeToAddr(host string, probe Probe, ch chan<- *datastruct.RcvPayload, wg *sync.WaitGroup) {
 	defer wg.Done()
-	payload := &datastruct.RcvPayload{Host: addr[:len(addr)-4], Port: 123}
-	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	ch <- DialHost(host, probe)
+}
+
+// DialHost sends the probe to port 123 of a single host and waits for its reply.
+func DialHost(host string, probe Probe) *datastruct.RcvPayload {
+	payload := &datastruct.RcvPayload{Host: host, Port: 123}
+	udpAddr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(host, "123"))
 	if err != nil {
 		payload.Err = err
-		ch <- payload
-		return
+		return payload
 	}
-	// fmt.Println(udpAddr.Print())
 	conn, err := net.DialUDP("udp", nil, udpAddr)
 	if err != nil {
 		payload.Err = err
-		ch <- payload
-		return
+		return payload
 	}
 	defer func() {
 		_ = conn.Close()
 	}()
-	payload.SendTime = time.Now()
-	_, err = conn.Write(utils.FixedData())
+	err = sockopt.EnableRecvTTL(conn)
 	if err != nil {
 		payload.Err = err
-		ch <- payload
-		return
+		return payload
 	}
-	buf := make([]byte, 128)
-	err = conn.SetDeadline(time.Now().Add(timeout))
+	localAddr, _ := conn.LocalAddr().(*net.UDPAddr)
+	sent := 0
+	for _, req := range probe.Requests {
+		err = kod.Wait(host)
+		if err != nil {
+			payload.Err = err
+			return payload
+		}
+		payload.SendTime = time.Now()
+		payload.SendData = req
+		payload.SendLen += len(req)
+		_, err = conn.Write(req)
+		if err != nil {
+			payload.Err = err
+			return payload
+		}
+		pcap.WriteUDP(localAddr, udpAddr, 0, req, payload.SendTime)
+		sent++
+		err = readReply(conn, udpAddr, payload, probe.Multi)
+		if err == nil {
+			a := datastruct.NewAmplification(probe.probeType(), payload)
+			a.SendPackets = sent
+			datastruct.RecordAmplification(a)
+			if !probe.Multi && payload.CheckKoD() {
+				kod.Record(host, payload.KissCode)
+			}
+			return payload
+		}
+		payload.Status = classify(err)
+		if payload.Status == datastruct.StatusClosed {
+			break
+		}
+	}
+	return payload
+}
+
+func (p Probe) probeType() string {
+	if p.Type == "" {
+		return datastruct.ProbeMode3
+	}
+	return p.Type
+}
+
+// readReply reads the reply to the request just sent, with every datagram of
+// it when multi is set.
+func readReply(conn *net.UDPConn, udpAddr *net.UDPAddr, payload *datastruct.RcvPayload, multi bool) error {
+	localAddr, _ := conn.LocalAddr().(*net.UDPAddr)
+	err := conn.SetReadDeadline(time.Now().Add(timeout))
 	if err != nil {
-		payload.Err = err
-		ch <- payload
-		return
+		return err
 	}
-	n, _, err := conn.ReadFromUDP(buf)
-	if err == nil && n > 0 {
+	oob := make([]byte, sockopt.OOBSize)
+	for {
+		buf := make([]byte, maxDatagram)
+		n, oobn, _, _, err := conn.ReadMsgUDP(buf, oob)
+		if err != nil || n == 0 {
+			if payload.Packets() > 0 {
+				return nil
+			}
+			if err == nil {
+				err = errors.New("empty datagram")
+			}
+			return err
+		}
+		payload.Status = datastruct.StatusOpen
 		payload.RcvTime = time.Now()
-		payload.Len = n
-		payload.RcvData = buf[:n]
-		ch <- payload
+		payload.Len += n
+		if payload.RcvData == nil {
+			payload.RcvData = buf[:n]
+			payload.SetTTL(sockopt.ParseTTL(oob[:oobn]))
+		}
+		if multi {
+			payload.Datagrams = append(payload.Datagrams, buf[:n])
+		}
+		pcap.WriteUDP(udpAddr, localAddr, payload.TTL, buf[:n], payload.RcvTime)
+		if !multi {
+			return nil
+		}
+		err = conn.SetReadDeadline(time.Now().Add(packetGap))
+		if err != nil {
+			return nil
+		}
+	}
+}
+
+// classify tells a closed port, reported by ICMP port unreachable on the
+// connected socket, from a host that never replied.
+func classify(err error) datastruct.Status {
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return datastruct.StatusClosed
 	}
+	return datastruct.StatusFiltered
 }
@@ -0,0 +1,85 @@
+package udpdetect
+
+import (
+	"active/datastruct"
+	"errors"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestClassify(t *testing.T) {
+	var tests = []struct {
+		name string
+		err  error
+		want datastruct.Status
+	}{
+		{"port unreachable", &net.OpError{Op: "read", Net: "udp", Err: os.NewSyscallError("recvmsg", syscall.ECONNREFUSED)},
+			datastruct.StatusClosed},
+		{"bare port unreachable", syscall.ECONNREFUSED, datastruct.StatusClosed},
+		{"timeout", &net.OpError{Op: "read", Net: "udp", Err: os.ErrDeadlineExceeded}, datastruct.StatusFiltered},
+		{"host unreachable", &net.OpError{Op: "read", Net: "udp", Err: os.NewSyscallError("recvmsg", syscall.EHOSTUNREACH)},
+			datastruct.StatusFiltered},
+		{"empty datagram", errors.New("empty datagram"), datastruct.StatusFiltered},
+	}
+	for _, tt := range tests {
+		if got := classify(tt.err); got != tt.want {
+			t.Errorf("%s: classify() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReadReplyStatus(t *testing.T) {
+	server, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skipf("cannot listen on loopback: %v", err)
+	}
+	defer func() { _ = server.Close() }()
+	serverAddr := server.LocalAddr().(*net.UDPAddr)
+	go func() {
+		buf := make([]byte, maxDatagram)
+		n, addr, err := server.ReadFromUDP(buf)
+		if err == nil {
+			_, _ = server.WriteToUDP(buf[:n], addr)
+		}
+	}()
+	// A port that was just released answers with ICMP port unreachable
+	closed, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := closed.LocalAddr().(*net.UDPAddr)
+	_ = closed.Close()
+
+	savedTimeout := timeout
+	defer func() { timeout = savedTimeout }()
+	timeout = 500 * time.Millisecond
+
+	var tests = []struct {
+		name string
+		addr *net.UDPAddr
+		want datastruct.Status
+	}{
+		{"reply", serverAddr, datastruct.StatusOpen},
+		{"closed port", closedAddr, datastruct.StatusClosed},
+	}
+	for _, tt := range tests {
+		conn, err := net.DialUDP("udp", nil, tt.addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		payload := &datastruct.RcvPayload{Host: tt.addr.IP.String(), Port: tt.addr.Port}
+		if _, err = conn.Write(make([]byte, 48)); err != nil {
+			t.Fatal(err)
+		}
+		if err = readReply(conn, tt.addr, payload, false); err != nil {
+			payload.Status = classify(err)
+		}
+		_ = conn.Close()
+		if payload.Status != tt.want {
+			t.Errorf("%s: status %s, want %s (error %v)", tt.name, payload.Status, tt.want, err)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"errors"
+	"net"
 )
 
 var (
@@ -72,3 +73,16 @@ func FindSeed(root int) int {
 	}
 	return seed
 }
+
+// NetworkOf returns the /24 of an IPv4 host, or the /48 of an IPv6 one. Any
+// other string is returned as is.
+func NetworkOf(host string) string {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return host
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(24, 32)).String() + "/24"
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String() + "/48"
+}
@@ -12,3 +12,19 @@ func TestFindSeed(t *testing.T) {
 		fmt.Printf("i=%d, total=%d, n=%d, m=%d\n", i, 1<<i, n, m)
 	}
 }
+
+func TestNetworkOf(t *testing.T) {
+	var tests = []struct {
+		host    string
+		network string
+	}{
+		{"192.0.2.17", "192.0.2.0/24"},
+		{"2001:db8:1:2::3", "2001:db8:1::/48"},
+		{"ntp.example", "ntp.example"},
+	}
+	for _, tt := range tests {
+		if got := NetworkOf(tt.host); got != tt.network {
+			t.Errorf("NetworkOf(%s) = %s, want %s", tt.host, got, tt.network)
+		}
+	}
+}
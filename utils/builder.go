@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"active/packet"
+)
+
+// PacketBuilder crafts NTP requests starting from the fixed client request,
+// each setter changing one field so that probes only spell out what they
+// alter.
+type PacketBuilder struct {
+	packet   *packet.Packet
+	truncate int
+}
+
+func NewPacketBuilder() *PacketBuilder {
+	return &PacketBuilder{packet: FixedPacket(), truncate: -1}
+}
+
+func (b *PacketBuilder) Leap(leap int) *PacketBuilder {
+	b.packet.Leap = uint8(leap & 0b11)
+	return b
+}
+
+func (b *PacketBuilder) Version(version int) *PacketBuilder {
+	b.packet.Version = uint8(version & 0b111)
+	return b
+}
+
+func (b *PacketBuilder) Mode(mode int) *PacketBuilder {
+	b.packet.Mode = uint8(mode & 0b111)
+	return b
+}
+
+func (b *PacketBuilder) Stratum(stratum int) *PacketBuilder {
+	b.packet.Stratum = uint8(stratum)
+	return b
+}
+
+func (b *PacketBuilder) Poll(poll int8) *PacketBuilder {
+	b.packet.Poll = poll
+	return b
+}
+
+func (b *PacketBuilder) Precision(precision int8) *PacketBuilder {
+	b.packet.Precision = precision
+	return b
+}
+
+func (b *PacketBuilder) OriginTimestamp(ts uint64) *PacketBuilder {
+	b.packet.OriginTime = packet.Timestamp(ts)
+	return b
+}
+
+func (b *PacketBuilder) ReceiveTimestamp(ts uint64) *PacketBuilder {
+	b.packet.ReceiveTime = packet.Timestamp(ts)
+	return b
+}
+
+func (b *PacketBuilder) TransmitTimestamp(ts uint64) *PacketBuilder {
+	b.packet.TransmitTime = packet.Timestamp(ts)
+	return b
+}
+
+// Extension appends an RFC 7822 extension field, padding the value so that
+// the field is at least minLength long.
+func (b *PacketBuilder) Extension(typ uint16, value []byte, minLength int) *PacketBuilder {
+	if pad := minLength - 4 - len(value); pad > 0 {
+		value = append(append([]byte(nil), value...), make([]byte, pad)...)
+	}
+	b.packet.Extensions = append(b.packet.Extensions, packet.Extension{Type: typ, Value: value})
+	return b
+}
+
+// Sign closes the packet with a MAC computed with key.
+func (b *PacketBuilder) Sign(key *Key) *PacketBuilder {
+	b.packet.MAC = &packet.MAC{KeyID: key.ID, Digest: key.Digest(b.packet.MarshalUnsigned())}
+	return b
+}
+
+// Append adds raw bytes after what was built so far.
+func (b *PacketBuilder) Append(data []byte) *PacketBuilder {
+	b.packet.Trailer = append(b.packet.Trailer, data...)
+	return b
+}
+
+// Truncate cuts the packet down to length bytes.
+func (b *PacketBuilder) Truncate(length int) *PacketBuilder {
+	b.truncate = length
+	return b
+}
+
+// Build encodes the packet into a new buffer, the builder staying usable.
+func (b *PacketBuilder) Build() []byte {
+	data := b.packet.Marshal()
+	if b.truncate >= 0 && b.truncate < len(data) {
+		data = data[:b.truncate]
+	}
+	return data
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestPacketBuilder(t *testing.T) {
+	var tests = []struct {
+		name   string
+		packet []byte
+		length int
+		first  byte
+	}{
+		{"fixed", NewPacketBuilder().Build(), 48, 0xDB},
+		{"leap", NewPacketBuilder().Leap(0).Build(), 48, 0x1B},
+		{"version", NewPacketBuilder().Version(4).Build(), 48, 0xE3},
+		{"mode", NewPacketBuilder().Mode(1).Build(), 48, 0xD9},
+		{"truncated", NewPacketBuilder().Truncate(47).Build(), 47, 0xDB},
+		{"extension", NewPacketBuilder().Extension(0xF123, []byte{1, 2, 3, 4, 5}, 16).Build(), 64, 0xDB},
+		{"padded", NewPacketBuilder().Extension(0xF123, []byte{1, 2, 3, 4, 5}, 0).Build(), 60, 0xDB},
+	}
+	for _, tt := range tests {
+		if len(tt.packet) != tt.length || tt.packet[0] != tt.first {
+			t.Errorf("%s: %d bytes starting with 0x%02X, want %d and 0x%02X", tt.name, len(tt.packet),
+				tt.packet[0], tt.length, tt.first)
+		}
+		if tt.length > 48 && binary.BigEndian.Uint16(tt.packet[50:52]) != uint16(tt.length-48) {
+			t.Errorf("%s: extension length %d, want %d", tt.name, binary.BigEndian.Uint16(tt.packet[50:52]), tt.length-48)
+		}
+	}
+	fixed := []byte{
+		0xDB, 0x00, 0x04, 0xFA, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x00,
+	}
+	if !bytes.Equal(FixedData(), fixed) {
+		t.Errorf("FixedData() = % X, want % X", FixedData(), fixed)
+	}
+	if v := VariableData(); !bytes.Equal(v[:40], fixed[:40]) || &v[0] == &VariableData()[0] {
+		t.Error("VariableData differs from the fixed request or shares its buffer")
+	}
+	if !bytes.Equal(FixedData(), NewPacketBuilder().Build()) {
+		t.Error("builder does not start from the fixed request")
+	}
+	b := NewPacketBuilder()
+	first := b.TransmitTimestamp(0).Build()
+	first[0] = 0
+	if b.Build()[0] != 0xDB || binary.BigEndian.Uint64(b.Build()[40:48]) != 0 {
+		t.Error("Build does not return a copy")
+	}
+}
@@ -0,0 +1,182 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	KeyMD5     = "MD5"
+	KeySHA1    = "SHA1"
+	KeyAESCMAC = "AES128CMAC"
+
+	maxASCIIKeyLength = 20
+	aesBlockSize      = 16
+)
+
+// Key is one symmetric key of an ntp.keys file.
+type Key struct {
+	ID     uint32
+	Type   string
+	Secret []byte
+}
+
+var keyTypes = map[string]string{
+	"M":            KeyMD5,
+	"MD5":          KeyMD5,
+	"SHA":          KeySHA1,
+	"SHA1":         KeySHA1,
+	"AES128":       KeyAESCMAC,
+	"AES128CMAC":   KeyAESCMAC,
+	"AES-128-CMAC": KeyAESCMAC,
+	"CMAC":         KeyAESCMAC,
+}
+
+// LoadKeys reads an ntp.keys file, where each line holds a key ID, a key
+// type and the key, either as up to 20 ASCII characters or in hex.
+func LoadKeys(path string) (map[uint32]*Key, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening key file %s: %v", path, err)
+	}
+	defer func() { _ = file.Close() }()
+
+	keys := make(map[uint32]*Key)
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("%s:%d: expecting key ID, type and key", path, lineNum)
+		}
+		key, err := parseKey(fields[0], fields[1], fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNum, err)
+		}
+		keys[key.ID] = key
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading key file %s: %v", path, err)
+	}
+	return keys, nil
+}
+
+func parseKey(idStr, typeStr, secretStr string) (*Key, error) {
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil || id == 0 {
+		return nil, fmt.Errorf("invalid key ID %q", idStr)
+	}
+	typ, ok := keyTypes[strings.ToUpper(typeStr)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %q", typeStr)
+	}
+	secret := []byte(secretStr)
+	if len(secretStr) > maxASCIIKeyLength {
+		secret, err = hex.DecodeString(secretStr)
+		if err != nil {
+			return nil, fmt.Errorf("key %d is longer than %d characters but not hex", id, maxASCIIKeyLength)
+		}
+	}
+	if typ == KeyAESCMAC && len(secret) != aesBlockSize {
+		return nil, fmt.Errorf("AES-128-CMAC key %d has %d bytes, expecting %d", id, len(secret), aesBlockSize)
+	}
+	return &Key{ID: uint32(id), Type: typ, Secret: secret}, nil
+}
+
+// Digest computes the digest of the packet with the key: MD5 and SHA1 over
+// the key followed by the packet as in RFC 5905, CMAC as in RFC 8573.
+func (k *Key) Digest(packet []byte) []byte {
+	switch k.Type {
+	case KeySHA1:
+		sum := sha1.Sum(append(append([]byte(nil), k.Secret...), packet...))
+		return sum[:]
+	case KeyAESCMAC:
+		return cmac(k.Secret, packet)
+	default:
+		sum := md5.Sum(append(append([]byte(nil), k.Secret...), packet...))
+		return sum[:]
+	}
+}
+
+// Verify tells whether the digest matches the packet.
+func (k *Key) Verify(packet, digest []byte) bool {
+	return subtle.ConstantTimeCompare(k.Digest(packet), digest) == 1
+}
+
+// FixedDataWithKey returns a copy of the fixed NTPv4 client request followed
+// by the key ID and the digest.
+func FixedDataWithKey(key *Key) []byte {
+	return NewPacketBuilder().Version(4).Sign(key).Build()
+}
+
+// cmac is AES-CMAC from RFC 4493.
+func cmac(key, msg []byte) []byte {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil
+	}
+	l := make([]byte, aesBlockSize)
+	block.Encrypt(l, l)
+	k1 := cmacDouble(l)
+	k2 := cmacDouble(k1)
+
+	n := (len(msg) + aesBlockSize - 1) / aesBlockSize
+	complete := n > 0 && len(msg)%aesBlockSize == 0
+	if n == 0 {
+		n = 1
+	}
+	last := make([]byte, aesBlockSize)
+	if complete {
+		copy(last, msg[(n-1)*aesBlockSize:])
+		xorBlock(last, last, k1)
+	} else {
+		rest := msg[(n-1)*aesBlockSize:]
+		copy(last, rest)
+		last[len(rest)] = 0x80
+		xorBlock(last, last, k2)
+	}
+
+	x := make([]byte, aesBlockSize)
+	for i := 0; i < n-1; i++ {
+		xorBlock(x, x, msg[i*aesBlockSize:(i+1)*aesBlockSize])
+		block.Encrypt(x, x)
+	}
+	xorBlock(x, x, last)
+	block.Encrypt(x, x)
+	return x
+}
+
+func cmacDouble(b []byte) []byte {
+	res := make([]byte, len(b))
+	carry := byte(0)
+	for i := len(b) - 1; i >= 0; i-- {
+		res[i] = b[i]<<1 | carry
+		carry = b[i] >> 7
+	}
+	if b[0]&0x80 != 0 {
+		res[len(res)-1] ^= 0x87
+	}
+	return res
+}
+
+func xorBlock(dst, x, y []byte) {
+	for i := range dst {
+		dst[i] = x[i] ^ y[i]
+	}
+}
@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCMAC(t *testing.T) {
+	key, _ := hex.DecodeString("2b7e151628aed2a6abf7158809cf4f3c")
+	msg, _ := hex.DecodeString("6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e51" +
+		"30c81c46a35ce411e5fbc1191a0a52eff69f2445df4f9b17ad2b417be66c3710")
+	var tests = []struct {
+		length int
+		want   string
+	}{
+		{0, "bb1d6929e95937287fa37d129b756746"},
+		{16, "070a16b46b4d4144f79bdd9dd04a287c"},
+		{40, "dfa66747de9ae63030ca32611497c827"},
+		{64, "51f0bebf7e3b9d92fc49741779363cfe"},
+	}
+	for _, tt := range tests {
+		got := hex.EncodeToString(cmac(key, msg[:tt.length]))
+		if got != tt.want {
+			t.Errorf("cmac(%d bytes) = %s, want %s", tt.length, got, tt.want)
+		}
+	}
+}
+
+func TestLoadKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ntp.keys")
+	content := "# comment\n1 M secret\n2 SHA1 0123456789abcdef0123456789abcdef01234567  # hex\n" +
+		"3 AES128CMAC 2b7e151628aed2a6abf7158809cf4f3c\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := LoadKeys(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		id     uint32
+		typ    string
+		length int
+		macLen int
+	}{
+		{1, KeyMD5, 6, 20},
+		{2, KeySHA1, 20, 24},
+		{3, KeyAESCMAC, 16, 20},
+	}
+	for _, tt := range tests {
+		key, ok := keys[tt.id]
+		if !ok {
+			t.Errorf("key %d missing", tt.id)
+			continue
+		}
+		if key.Type != tt.typ || len(key.Secret) != tt.length {
+			t.Errorf("key %d = %s with %d bytes, want %s with %d", tt.id, key.Type, len(key.Secret), tt.typ, tt.length)
+		}
+		data := FixedDataWithKey(key)
+		if len(data) != 48+tt.macLen {
+			t.Errorf("key %d request has %d bytes, want %d", tt.id, len(data), 48+tt.macLen)
+		}
+		if !key.Verify(data[:48], data[52:]) {
+			t.Errorf("key %d request does not verify", tt.id)
+		}
+	}
+
+	if err = os.WriteFile(path, []byte("4 AES128CMAC short\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = LoadKeys(path); err == nil {
+		t.Error("short AES key accepted")
+	}
+}
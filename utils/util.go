@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"active/packet"
 	"bytes"
 	"encoding/binary"
 	"encoding/json"
@@ -27,24 +28,28 @@ const (
 )
 
 var (
-	startingPoint = time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
 	searcher      *xdb.Searcher
-	fixedData     []byte
-	variableData  []byte
+	initialTTLs   = []int{64, 128, 255}
+	kissCodes     = map[string]string{
+		"ACST": "The association belongs to a unicast server",
+		"AUTH": "Server authentication failed",
+		"AUTO": "Autokey sequence failed",
+		"BCST": "The association belongs to a broadcast server",
+		"CRYP": "Cryptographic authentication or identification failed",
+		"DENY": "Access denied by remote server",
+		"DROP": "Lost peer in symmetric mode",
+		"RSTR": "Access denied due to local policy",
+		"INIT": "The association has not yet synchronized for the first time",
+		"MCST": "The association belongs to a dynamically discovered server",
+		"NKEY": "No key found",
+		"NTSN": "Network Time Security (NTS) negative-acknowledgment (NAK)",
+		"RATE": "Rate exceeded",
+		"RMOT": "Alteration of association from a remote host running ntpdc",
+		"STEP": "A step change in system time has occurred",
+	}
 )
 
 func init() {
-	fixedData = []byte{
-		0xDB, 0x00, 0x04, 0xFA, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x00,
-		// 0x01, 0x02, 0x00, 0x04,
-	}
-	variableData = []byte{
-		0xDB, 0x00, 0x04, 0xFA, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x00,
-	}
 	viper.AddConfigPath(configPath)
 	viper.SetConfigType("yaml")
 	viper.SetConfigName("properties")
@@ -61,8 +66,36 @@ func init() {
 	searcher, err = xdb.NewWithBuffer(buf)
 }
 
+// FixedPacket returns the client request every scan sends: NTPv3, leap
+// indicator 3, poll 4, precision -6 and a constant transmit timestamp.
+func FixedPacket() *packet.Packet {
+	return &packet.Packet{
+		Leap:         3,
+		Version:      3,
+		Mode:         3,
+		Poll:         4,
+		Precision:    -6,
+		RootDelay:    1 << 16,
+		RootDisp:     1 << 16,
+		TransmitTime: 0xFFFFFFFFFFFFFF00,
+	}
+}
+
+// FixedData returns the encoded fixed request, a new buffer on every call.
 func FixedData() []byte {
-	return fixedData
+	return FixedPacket().Marshal()
+}
+
+// FixedDataWithVersion returns a copy of the fixed client request carrying
+// the given version number instead of 3.
+func FixedDataWithVersion(version int) []byte {
+	return FixedDataWithMode(version, 3)
+}
+
+// FixedDataWithMode returns a copy of the fixed request with the given
+// version and mode numbers.
+func FixedDataWithMode(version, mode int) []byte {
+	return NewPacketBuilder().Version(version).Mode(mode).Build()
 }
 
 func FromInt8(i int8) string {
@@ -142,34 +175,45 @@ func CountryOf(ipStr string) string {
 }
 
 func CalculateDelay(timestamp []byte, another time.Time) time.Duration {
-	t := binary.BigEndian.Uint64(timestamp)
-	seconds := int64(t >> 32)
-	nano := (int64(t&0xFFFF_FFFF) * int64(time.Second)) >> 32
-	d := startingPoint.Add(time.Duration(seconds) * time.Second).Add(time.Duration(nano))
-	delay := d.Sub(another)
-	return delay
+	return ConvertTimestamp(timestamp).Sub(another)
 }
 
 func FormatTimestamp(timestamp []byte) string {
 	return ConvertTimestamp(timestamp).Format(preciseFormat)
 }
 
+// ConvertTimestamp reads an NTP timestamp in the era closest to the local
+// clock, so that timestamps past the 2036 rollover convert correctly.
 func ConvertTimestamp(timestamp []byte) time.Time {
-	intPart := binary.BigEndian.Uint32(timestamp[:4])
-	fracPart := binary.BigEndian.Uint32(timestamp[4:])
-	intTime := startingPoint.Add(time.Duration(intPart) * time.Second)
-	fracDuration := (time.Duration(fracPart) * time.Second) >> 32
-	return intTime.Add(fracDuration)
+	return packet.Timestamp(binary.BigEndian.Uint64(timestamp)).TimeNear(time.Now())
 }
 
+// VariableData returns the fixed request carrying the current time as
+// transmit timestamp.
 func VariableData() []byte {
-	d := time.Now().Sub(startingPoint)
-	seconds := d / time.Second
-	high32 := seconds << 32
-	nano := d - seconds*time.Second
-	low32 := (nano << 32) / time.Second
-	binary.BigEndian.PutUint64(variableData[40:], uint64(high32|low32))
-	return variableData
+	p := FixedPacket()
+	p.TransmitTime = packet.NewTimestamp(time.Now())
+	return p.Marshal()
+}
+
+// NTPTimestamp converts t to the 64-bit NTP timestamp format.
+func NTPTimestamp(t time.Time) uint64 {
+	return uint64(packet.NewTimestamp(t))
+}
+
+// InferInitialTTL guesses the initial TTL a reply was sent with, picking the
+// smallest common default (64, 128 or 255) that is not below the received
+// TTL, and returns it together with the number of hops in between.
+func InferInitialTTL(ttl int) (int, int) {
+	if ttl <= 0 {
+		return 0, 0
+	}
+	for _, initial := range initialTTLs {
+		if ttl <= initial {
+			return initial, initial - ttl
+		}
+	}
+	return 0, 0
 }
 
 func DurationToStr(t1, t2 time.Time) string {
@@ -311,3 +355,46 @@ func TranslateCountry(countries []string) []string {
 
 	return trRes.Target
 }
+
+// ISPOf returns the ISP field of ip2region, the closest to an ASN it offers.
+func ISPOf(ipStr string) string {
+	if ipStr == nullIP {
+		return nullFlag
+	}
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return unknownFlag
+	}
+	if ip.IsPrivate() {
+		return privateFlag
+	}
+	region, err := searcher.SearchByStr(ipStr)
+	if err != nil {
+		fmt.Println(err)
+		return unknownFlag
+	}
+	parts := strings.Split(region, "|")
+	if len(parts) < 5 || parts[4] == "0" {
+		return unknownFlag
+	}
+	return parts[4]
+}
+
+// KissCode returns the kiss code of a Kiss-o'-Death packet, that is a
+// stratum 0 reply whose reference ID holds four printable ASCII characters.
+func KissCode(data []byte) (string, bool) {
+	// Only modes 1 to 5 carry a header, mode 6 and 7 messages are not kisses
+	p, _ := packet.Unmarshal(data)
+	if p == nil {
+		return "", false
+	}
+	return p.KissCode()
+}
+
+// KissCodeMeaning explains a kiss code from the IANA registry.
+func KissCodeMeaning(code string) string {
+	if meaning, ok := kissCodes[code]; ok {
+		return meaning
+	}
+	return fmt.Sprintf("Unregistered kiss code %q", code)
+}
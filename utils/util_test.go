@@ -43,6 +43,31 @@ func TestVariableData(t *testing.T) {
 	}
 }
 
+func TestInferInitialTTL(t *testing.T) {
+	var tests = []struct {
+		ttl     int
+		initial int
+		hops    int
+	}{
+		{0, 0, 0},
+		{1, 64, 63},
+		{52, 64, 12},
+		{64, 64, 0},
+		{65, 128, 63},
+		{116, 128, 12},
+		{128, 128, 0},
+		{240, 255, 15},
+		{255, 255, 0},
+		{300, 0, 0},
+	}
+	for _, test := range tests {
+		initial, hops := InferInitialTTL(test.ttl)
+		if initial != test.initial || hops != test.hops {
+			t.Errorf("InferInitialTTL(%d) = %d, %d", test.ttl, initial, hops)
+		}
+	}
+}
+
 func TestSplitCIDR(t *testing.T) {
 	got := SplitCIDR("192.168.254.147/22", 32)
 	for _, s := range got {
@@ -55,3 +80,53 @@ func TestTranslateCountry(t *testing.T) {
 	result := TranslateCountry(countries)
 	fmt.Println(result)
 }
+
+func TestFixedDataWithVersion(t *testing.T) {
+	var tests = []struct {
+		input int
+		want  byte
+	}{
+		{1, 0xCB},
+		{2, 0xD3},
+		{3, 0xDB},
+		{4, 0xE3},
+	}
+	for _, test := range tests {
+		if got := FixedDataWithVersion(test.input)[0]; got != test.want {
+			t.Errorf("FixedDataWithVersion(%d)[0] = %02X, want %02X", test.input, got, test.want)
+		}
+	}
+	if FixedData()[0] != 0xDB {
+		t.Errorf("FixedDataWithVersion modified the fixed data")
+	}
+}
+
+func TestKissCode(t *testing.T) {
+	kiss := func(stratum byte, mode byte, refID string) []byte {
+		data := make([]byte, 48)
+		data[0] = 4<<3 | mode
+		data[1] = stratum
+		copy(data[12:16], refID)
+		return data
+	}
+	var tests = []struct {
+		name string
+		data []byte
+		code string
+	}{
+		{"RATE", kiss(0, 4, "RATE"), "RATE"},
+		{"synchronized server", kiss(2, 4, "RATE"), ""},
+		{"control message", kiss(0, 6, "RATE"), ""},
+		{"unprintable", kiss(0, 4, "\x01\x02\x03\x04"), ""},
+		{"truncated", kiss(0, 4, "RATE")[:12], ""},
+	}
+	for _, tt := range tests {
+		code, ok := KissCode(tt.data)
+		if code != tt.code || ok != (tt.code != "") {
+			t.Errorf("%s: KissCode() = %q, %v, want %q", tt.name, code, ok, tt.code)
+		}
+	}
+	if got := KissCodeMeaning("RATE"); got != "Rate exceeded" {
+		t.Errorf("KissCodeMeaning(RATE) = %q", got)
+	}
+}